@@ -0,0 +1,286 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"v8/fixlog"
+)
+
+// 文件名中的交易日，如oms_20240411.log
+var reFileDate = regexp.MustCompile(`(?:^|[^0-9])(20\d{6})(?:[^0-9]|$)`)
+
+// 推断日志的交易日：优先取文件名中的YYYYMMDD，否则取第一条带时间的日志行的日期
+func inferTradeDate(path string, loc *time.Location) (string, error) {
+	if matches := reFileDate.FindStringSubmatch(filepath.Base(path)); matches != nil {
+		if date, err := time.Parse("20060102", matches[1]); err == nil {
+			return date.Format(tradeDateLayout), nil
+		}
+	}
+
+	t, ok, err := firstLogTime(path, loc)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		return t.Format(tradeDateLayout), nil
+	}
+	return "", fmt.Errorf("no trade date in the file name or log lines of %s", path)
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error reading file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// 批处理索引，记录每个交易日的输入和输出，保存在输出目录下的index.json
+type batchIndex struct {
+	Days []batchDay `json:"days"`
+}
+
+type batchDay struct {
+	Date          string    `json:"date"`
+	Log           string    `json:"log"`
+	SHA256        string    `json:"sha256"`
+	Filter        string    `json:"filter,omitempty"` // 处理时的-filter
+	Store         string    `json:"store,omitempty"`  // 处理时的-store(绝对路径)，未保存到历史库时为空
+	Orders        int       `json:"orders"`
+	Confirmations int       `json:"confirmations"`
+	CSV           string    `json:"csv"`     // 相对输出目录
+	Summary       string    `json:"summary"` // 相对输出目录
+	ProcessedAt   time.Time `json:"processedAt"`
+}
+
+const batchIndexName = "index.json"
+
+func readBatchIndex(outputDir string) (map[string]batchDay, error) {
+	days := make(map[string]batchDay)
+	indexBytes, err := os.ReadFile(filepath.Join(outputDir, batchIndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return days, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading index: %v", err)
+	}
+	var index batchIndex
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return nil, fmt.Errorf("error parsing index: %v", err)
+	}
+	for _, day := range index.Days {
+		days[day.Date] = day
+	}
+	return days, nil
+}
+
+func writeBatchIndex(outputDir string, days map[string]batchDay) error {
+	var index batchIndex
+	for _, day := range days {
+		index.Days = append(index.Days, day)
+	}
+	sort.Slice(index.Days, func(i, j int) bool { return index.Days[i].Date < index.Days[j].Date })
+
+	indexBytes, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling to JSON: %v", err)
+	}
+	// 先写临时文件再改名，中断时不会留下损坏的索引
+	tmp := filepath.Join(outputDir, "."+batchIndexName+".tmp")
+	if err := os.WriteFile(tmp, append(indexBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing index: %v", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(outputDir, batchIndexName)); err != nil {
+		return fmt.Errorf("error writing index: %v", err)
+	}
+	return nil
+}
+
+// 一个待处理的交易日
+type batchJob struct {
+	date   string
+	log    string
+	sha256 string
+}
+
+type batchOptions struct {
+	outputDir string
+	storeDir  string
+	loc       *time.Location
+	filter    string
+}
+
+// 分析一个交易日，输出<date>.csv和<date>.json(汇总，可用于compare)
+func processDay(job batchJob, opts batchOptions) (batchDay, error) {
+	day := batchDay{Date: job.date, Log: job.log, SHA256: job.sha256, Filter: opts.filter, Store: opts.storeDir, CSV: job.date + ".csv", Summary: job.date + ".json"}
+	analyzerOpts := []fixlog.Option{fixlog.WithLocation(opts.loc)}
+	if orderFilter, err := parseOrderFilter(opts.filter, nil); err != nil {
+		return day, err
+	} else if orderFilter != nil {
+		analyzerOpts = append(analyzerOpts, fixlog.WithOrderFilter(orderFilter))
+	}
+
+	csvOut, err := newCsvConsumer(filepath.Join(opts.outputDir, day.CSV), fixlog.CoreStages, nil)
+	if err != nil {
+		return day, fmt.Errorf("error exporting to CSV: %v", err)
+	}
+	defer csvOut.file.Close()
+	analyzer, err := analyzeLatency(job.log, "", "", nil, append(analyzerOpts, fixlog.WithConsumer(csvOut))...)
+	if err != nil {
+		return day, err
+	}
+	if err := csvOut.Close(); err != nil {
+		return day, fmt.Errorf("error exporting to CSV: %v", err)
+	}
+
+	orders := analyzer.CompletedOrders()
+	if err := samplesFromOrders(orders, fixlog.CoreStages).writeSummary(filepath.Join(opts.outputDir, day.Summary)); err != nil {
+		return day, fmt.Errorf("error exporting summary: %v", err)
+	}
+	if opts.storeDir != "" {
+		if err := (latencyStore{opts.storeDir}).put(job.date, job.log, orders, fixlog.CoreStages); err != nil {
+			return day, fmt.Errorf("error saving to store: %v", err)
+		}
+	}
+
+	day.Orders = len(orders)
+	day.Confirmations = analyzer.Stats().ConfirmedMessages
+	day.ProcessedAt = time.Now().UTC().Truncate(time.Second)
+	return day, nil
+}
+
+func runBatch(args []string) error {
+	fs := newFlagSet("batch", "[options] <logDir> <outputDir>",
+		"Compute latency for every OMS log in a directory, one trade date per log. Writes <date>.csv, <date>.json (summary for compare) and index.json to the output directory. Logs unchanged since the last run are skipped.")
+	pattern := fs.String("pattern", "oms_*.log", "file name pattern of the OMS logs in <logDir>")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of logs processed in parallel")
+	force := fs.Bool("force", false, "process every log even if unchanged since the last run")
+	storePath := fs.String("store", "", "also save each trade date to this history store directory, for trend; logs not yet saved to this store are processed again")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to compare with UTC FIX timestamps")
+	filterValue := fs.String("filter", "", orderFilterUsage+"; logs processed with a different filter are processed again")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logDir> <outputDir>")
+	}
+	if *workers <= 0 {
+		return usageErrorf(fs, "-workers must be positive")
+	}
+	if _, err := parseOrderFilter(*filterValue, nil); err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+	logDir, outputDir := positional[0], positional[1]
+	storeDir := *storePath
+	if storeDir != "" {
+		if storeDir, err = filepath.Abs(storeDir); err != nil {
+			return fmt.Errorf("error resolving store directory: %v", err)
+		}
+	}
+
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+	logs, err := filepath.Glob(filepath.Join(logDir, *pattern))
+	if err != nil {
+		return usageErrorf(fs, "invalid -pattern: %v", err)
+	}
+	if len(logs) == 0 {
+		return fmt.Errorf("no logs matching %s in %s", *pattern, logDir)
+	}
+	sort.Strings(logs)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("error creating output directory: %v", err)
+	}
+	index, err := readBatchIndex(outputDir)
+	if err != nil {
+		return err
+	}
+
+	// 推断交易日并计算内容哈希，同一交易日只能有一个日志
+	var jobs []batchJob
+	logsByDate := make(map[string]string)
+	for _, log := range logs {
+		date, err := inferTradeDate(log, loc)
+		if err != nil {
+			return err
+		}
+		if other, ok := logsByDate[date]; ok {
+			return fmt.Errorf("both %s and %s are trade date %s", other, log, date)
+		}
+		logsByDate[date] = log
+
+		hash, err := hashFile(log)
+		if err != nil {
+			return err
+		}
+		// 上次未保存到本次的历史库时需重新处理，否则该交易日不会进入历史库
+		if previous, ok := index[date]; ok && !*force && previous.SHA256 == hash && previous.Filter == *filterValue && (storeDir == "" || previous.Store == storeDir) {
+			_, csvErr := os.Stat(filepath.Join(outputDir, previous.CSV))
+			_, summaryErr := os.Stat(filepath.Join(outputDir, previous.Summary))
+			if csvErr == nil && summaryErr == nil {
+				fmt.Printf("%s %s: unchanged, skipped\n", date, log)
+				continue
+			}
+		}
+		jobs = append(jobs, batchJob{date, log, hash})
+	}
+
+	opts := batchOptions{outputDir: outputDir, storeDir: storeDir, loc: loc, filter: *filterValue}
+	days := make([]batchDay, len(jobs))
+	errs := make([]error, len(jobs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < *workers && w < len(jobs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				days[i], errs[i] = processDay(jobs[i], opts)
+			}
+		}()
+	}
+	for i := range jobs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	for i, job := range jobs {
+		if errs[i] != nil {
+			failed += 1
+			fmt.Fprintf(os.Stderr, "Error: %s %s: %v\n", job.date, job.log, errs[i])
+			continue
+		}
+		index[job.date] = days[i]
+		fmt.Printf("%s %s: %d orders exported to %s\n", job.date, job.log, days[i].Orders, filepath.Join(outputDir, days[i].CSV))
+	}
+	if err := writeBatchIndex(outputDir, index); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d logs failed", failed, len(jobs))
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchStore(t *testing.T) {
+	dir := t.TempDir()
+	logDir, outputDir, storeDir := filepath.Join(dir, "logs"), filepath.Join(dir, "out"), filepath.Join(dir, "store")
+	if err := os.Mkdir(logDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(logDir, "oms_20240411.log"), []byte(strings.ReplaceAll(otelLog, "|", "\x01")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	processedAt := func() string {
+		data, err := os.ReadFile(filepath.Join(outputDir, batchIndexName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var index struct {
+			Days []map[string]any `json:"days"`
+		}
+		if err := json.Unmarshal(data, &index); err != nil {
+			t.Fatal(err)
+		}
+		return index.Days[0]["processedAt"].(string) + " " + index.Days[0]["sha256"].(string)
+	}
+
+	// 先不带-store处理，再带-store运行时未变化的日志也要保存到历史库
+	if err := runBatch([]string{"-logtz", "UTC", logDir, outputDir}); err != nil {
+		t.Fatal(err)
+	}
+	if err := runBatch([]string{"-logtz", "UTC", "-store", storeDir, logDir, outputDir}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "2024-04-11", "day.json")); err != nil {
+		t.Fatalf("trade date not saved to the store: %v", err)
+	}
+
+	// 已保存到同一历史库(相对路径也视为同一个)，或不带-store时跳过
+	os.RemoveAll(filepath.Join(storeDir, "2024-04-11"))
+	before := processedAt()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relativeStore, err := filepath.Rel(wd, storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{{"-store", relativeStore}, nil} {
+		if err := runBatch(append(append([]string{"-logtz", "UTC"}, args...), logDir, outputDir)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "2024-04-11")); err == nil || processedAt() != before {
+		t.Errorf("unchanged log processed again")
+	}
+}
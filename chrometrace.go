@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/fixdict"
+	"v8/internal/logline"
+)
+
+// Chrome Trace Event中的进程，分别放会话和订单的轨道
+const (
+	chromeSessionsPid = 1
+	chromeOrdersPid   = 2
+)
+
+// 一个Chrome Trace Event，ts为距起点的微秒数
+type chromeEvent struct {
+	Name  string         `json:"name"`
+	Cat   string         `json:"cat,omitempty"`
+	Ph    string         `json:"ph"`
+	Ts    json.Number    `json:"ts"`
+	Pid   int            `json:"pid"`
+	Tid   int            `json:"tid"`
+	ID    string         `json:"id,omitempty"`
+	Scope string         `json:"s,omitempty"`
+	Args  map[string]any `json:"args,omitempty"`
+}
+
+// 流式写出Chrome Trace Event JSON：报文写到所属会话的轨道，订单完成时写出各阶段的异步切片
+type chromeTraceWriter struct {
+	fixlog.NopConsumer
+	file     *os.File
+	w        *bufio.Writer
+	events   int
+	origin   time.Time
+	window   timeWindow
+	sessions map[string]int
+	stages   []fixlog.Stage
+	dict     *fixdict.Dictionary
+
+	Messages int // 写出的报文数
+	Orders   int // 写出的订单数
+	Skipped  int // 与上层切片部分重叠而未写出的阶段数
+}
+
+func newChromeTraceWriter(filename string, window timeWindow, stages []fixlog.Stage, dict *fixdict.Dictionary) (*chromeTraceWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	c := &chromeTraceWriter{file: file, w: bufio.NewWriter(file), window: window, sessions: make(map[string]int), stages: stages, dict: dict}
+	c.w.WriteString(`{"displayTimeUnit":"ns","traceEvents":[`)
+	c.metadata(chromeSessionsPid, 0, "process_name", "FIX sessions")
+	c.metadata(chromeOrdersPid, 0, "process_name", "Orders")
+	return c, nil
+}
+
+func (c *chromeTraceWriter) write(event chromeEvent) {
+	data, _ := json.Marshal(event)
+	if c.events > 0 {
+		c.w.WriteByte(',')
+	}
+	c.w.WriteByte('\n')
+	c.w.Write(data)
+	c.events += 1
+}
+
+func (c *chromeTraceWriter) metadata(pid int, tid int, name string, value string) {
+	c.write(chromeEvent{Name: name, Ph: "M", Ts: "0", Pid: pid, Tid: tid, Args: map[string]any{"name": value}})
+}
+
+// 距起点的微秒数，保留到纳秒
+func (c *chromeTraceWriter) ts(t time.Time) json.Number {
+	return json.Number(strconv.FormatFloat(float64(t.Sub(c.origin).Nanoseconds())/1e3, 'f', 3, 64))
+}
+
+// 是否在时间窗口内，第一次调用时确定起点：指定了-from时为-from，否则为第一条报文的时间
+func (c *chromeTraceWriter) inWindow(t time.Time) bool {
+	contains := c.window.contains(t)
+	if c.origin.IsZero() {
+		c.origin = t
+		if c.window.from.specified {
+			c.origin = c.window.from.value
+		}
+	}
+	return contains
+}
+
+// 会话由双方CompID确定，不区分方向
+func (c *chromeTraceWriter) session(sender string, target string) int {
+	if target < sender {
+		sender, target = target, sender
+	}
+	name := sender + " <-> " + target
+	tid, ok := c.sessions[name]
+	if !ok {
+		tid = len(c.sessions) + 1
+		c.sessions[name] = tid
+		c.metadata(chromeSessionsPid, tid, "thread_name", name)
+	}
+	return tid
+}
+
+// 一条报文作为会话轨道上的瞬时事件，先判断时间窗口再解析字段
+func (c *chromeTraceWriter) message(line string, lineNo int, loc *time.Location) {
+	t, ok := lineTime(line, loc)
+	if !ok || !c.inWindow(t) {
+		return
+	}
+	h, ok := parseHop(line, lineNo, loc, c.dict)
+	if !ok {
+		return
+	}
+	args := map[string]any{"direction": h.direction, "from": h.sender, "to": h.target, "line": h.lineNo}
+	for _, field := range h.fields {
+		key, value, _ := strings.Cut(field, "=")
+		args[key] = value
+	}
+	c.write(chromeEvent{Name: h.message, Cat: "message", Ph: "i", Ts: c.ts(h.time), Pid: chromeSessionsPid, Tid: c.session(h.sender, h.target), Scope: "t", Args: args})
+	c.Messages += 1
+}
+
+// 订单生命周期作为一组嵌套的异步切片：最外层为TotalCostTime，其中为各阶段
+func (c *chromeTraceWriter) OnOrderComplete(order *fixlog.Order) error {
+	recv, ok := order.Milestone(fixlog.RecvClient)
+	if !ok || !c.inWindow(recv.Time) {
+		return nil
+	}
+
+	type slice struct {
+		name     string
+		from, to time.Time
+		args     map[string]any
+	}
+	var slices []slice
+	for _, stage := range append([]fixlog.Stage{fixlog.TotalCostTime}, c.stages...) {
+		from, okFrom := order.Milestone(stage.From)
+		to, okTo := order.Milestone(stage.To)
+		if !okFrom || !okTo || to.Time.Before(from.Time) {
+			continue
+		}
+		s := slice{stage.Name, from.Time, to.Time, nil}
+		if stage == fixlog.TotalCostTime {
+			s.name = "order " + order.ClOrdID
+			s.args = map[string]any{"ClOrdID": order.ClOrdID, "Account": order.Account, "Symbol": order.Symbol,
+				"Side": describeValue(c.dict, "54", order.Side), "OrderQty": order.OrderQty, "ClientCompID": order.ClientCompID, "MatchClOrdID": order.MatchClOrdID}
+		}
+		slices = append(slices, s)
+	}
+	if len(slices) == 0 {
+		return nil
+	}
+	// 外层在前；同一订单的异步切片必须严格嵌套，与上层部分重叠的阶段(如时钟偏差导致)不写出
+	sort.SliceStable(slices, func(i, j int) bool {
+		if !slices[i].from.Equal(slices[j].from) {
+			return slices[i].from.Before(slices[j].from)
+		}
+		return slices[i].to.After(slices[j].to)
+	})
+
+	end := func(s slice) {
+		c.write(chromeEvent{Name: s.name, Cat: "order", Ph: "e", Ts: c.ts(s.to), Pid: chromeOrdersPid, ID: order.ClOrdID})
+	}
+	var stack []slice
+	for _, s := range slices {
+		for len(stack) > 0 && !stack[len(stack)-1].to.After(s.from) {
+			end(stack[len(stack)-1])
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 && s.to.After(stack[len(stack)-1].to) {
+			c.Skipped += 1
+			continue
+		}
+		c.write(chromeEvent{Name: s.name, Cat: "order", Ph: "b", Ts: c.ts(s.from), Pid: chromeOrdersPid, ID: order.ClOrdID, Args: s.args})
+		stack = append(stack, s)
+	}
+	for i := len(stack) - 1; i >= 0; i-- {
+		end(stack[i])
+	}
+	c.Orders += 1
+	return nil
+}
+
+func (c *chromeTraceWriter) Close() error {
+	defer c.file.Close()
+	origin := ""
+	if !c.origin.IsZero() {
+		origin = c.origin.Format(time.RFC3339Nano)
+	}
+	fmt.Fprintf(c.w, "\n],\"otherData\":{\"origin\":%q,\"version\":%q}}\n", origin, version)
+	if err := c.w.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return c.file.Close()
+}
+
+func runChromeTrace(args []string) error {
+	fs := newFlagSet("chrome-trace", "[options] <logFilePath> <output.json>",
+		"Write a time window of the OMS log as Chrome Trace Event JSON for Perfetto (ui.perfetto.dev) or chrome://tracing: one track per FIX session with every message as an instant event, and the stages of every completed order as nested async slices.")
+	fromValue := fs.String("from", "", "start of the window, HH:MM:SS[.ffffff] on the day of the log or YYYY-MM-DD HH:MM:SS[.ffffff] (default start of the log)")
+	toValue := fs.String("to", "", "end of the window, exclusive, same format as -from (default end of the log)")
+	meLogPath := fs.String("me", "", "matching engine log, adds the matching engine stages inside MatchCostTime")
+	filterValue := fs.String("filter", "", orderFilterUsage+"; messages on the session tracks are not filtered")
+	heartbeats := fs.Bool("heartbeats", false, "also write heartbeats (35=0) as instant events")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to compare with UTC FIX timestamps")
+	dictPaths := addDictFlag(fs)
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <output.json>")
+	}
+	logFilePath, outputPath := positional[0], positional[1]
+
+	dict, err := dictPaths.load()
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+	window, err := parseTimeWindow(*fromValue, *toValue, loc)
+	if err != nil {
+		return usageErrorf(fs, "%v", err)
+	}
+	if err := window.resolveDate(logFilePath, loc); err != nil {
+		return err
+	}
+	orderFilter, err := parseOrderFilter(*filterValue, nil)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+
+	// TotalCostTime为最外层，其余阶段按时间嵌套在其中
+	var stages []fixlog.Stage
+	for _, stage := range fixlog.CoreStages {
+		if stage != fixlog.TotalCostTime {
+			stages = append(stages, stage)
+		}
+	}
+	if *meLogPath != "" {
+		stages = append(stages, fixlog.MatchEngineStages...)
+	}
+
+	out, err := newChromeTraceWriter(outputPath, window, stages, dict)
+	if err != nil {
+		return fmt.Errorf("error exporting Chrome trace: %v", err)
+	}
+	defer out.file.Close()
+
+	opts := []fixlog.Option{fixlog.WithLocation(loc), fixlog.WithConsumer(out), fixlog.WithEvictCompleted()}
+	if orderFilter != nil {
+		opts = append(opts, fixlog.WithOrderFilter(orderFilter))
+	}
+	analyzer := fixlog.NewAnalyzer(opts...)
+	if *meLogPath != "" {
+		if err := openFile(*meLogPath, analyzer.LoadMatchEngine); err != nil {
+			return fmt.Errorf("error loading matching engine log: %v", err)
+		}
+	}
+	err = scanLog(logFilePath, func(line string, lineNo int) error {
+		if logline.IsFix(line) {
+			msgType, _ := logline.Tag(line, "35")
+			if msgType != "0" || *heartbeats {
+				out.message(line, lineNo, loc)
+			}
+		}
+		return analyzer.Feed(line, lineNo)
+	})
+	if err != nil {
+		return fmt.Errorf("error analyzing log: %v", err)
+	}
+	if err := analyzer.Flush(); err != nil {
+		return fmt.Errorf("error analyzing log: %v", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("error exporting Chrome trace: %v", err)
+	}
+
+	fmt.Printf("Sessions: %d, messages: %d, orders: %d\n", len(out.sessions), out.Messages, out.Orders)
+	if out.Skipped > 0 {
+		fmt.Printf("%d stages partially overlapping their enclosing stage were left out\n", out.Skipped)
+	}
+	fmt.Println("Chrome trace written to", outputPath)
+	return nil
+}
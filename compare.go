@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"v8/internal/stats"
+)
+
+// 比较的分组：全部订单，或某个账户/合约的订单
+type compareGroup struct {
+	label string
+	match func(key sampleKey) bool
+}
+
+func compareGroups(base, candidate latencySamples, stage string, by []string) []compareGroup {
+	groups := []compareGroup{{"all", func(key sampleKey) bool { return key.Stage == stage }}}
+	for _, dimension := range by {
+		values := make(map[string]bool)
+		for _, samples := range []latencySamples{base, candidate} {
+			for key := range samples {
+				if key.Stage != stage {
+					continue
+				}
+				switch dimension {
+				case "account":
+					values[key.Account] = true
+				case "symbol":
+					if key.Symbol != "" {
+						values[key.Symbol] = true
+					}
+				}
+			}
+		}
+
+		sorted := make([]string, 0, len(values))
+		for value := range values {
+			sorted = append(sorted, value)
+		}
+		sort.Strings(sorted)
+		for _, value := range sorted {
+			value := value
+			match := func(key sampleKey) bool { return key.Stage == stage && key.Account == value }
+			if dimension == "symbol" {
+				match = func(key sampleKey) bool { return key.Stage == stage && key.Symbol == value }
+			}
+			groups = append(groups, compareGroup{dimension + "=" + value, match})
+		}
+	}
+	return groups
+}
+
+type compareOptions struct {
+	tolerance  float64 // 百分比
+	alpha      float64
+	bootstrap  int
+	minOrders  int
+	confidence float64
+}
+
+// 一个分组的比较结果
+type comparison struct {
+	group                  string
+	baseCount, candCount   int
+	baseP50, candP50       time.Duration
+	baseP99, candP99       time.Duration
+	ciLow, ciHigh          time.Duration // p99差值的置信区间
+	mwuP, ksP              float64
+	tested                 bool
+	p50Regress, p99Regress bool
+}
+
+func compareSamples(group string, base, candidate []time.Duration, opts compareOptions, rng *rand.Rand) comparison {
+	c := comparison{group: group, baseCount: len(base), candCount: len(candidate), mwuP: 1, ksP: 1}
+	c.baseP50, c.candP50 = stats.Percentile(base, 50), stats.Percentile(candidate, 50)
+	c.baseP99, c.candP99 = stats.Percentile(base, 99), stats.Percentile(candidate, 99)
+	if len(base) < opts.minOrders || len(candidate) < opts.minOrders {
+		return c
+	}
+
+	c.tested = true
+	_, c.mwuP = stats.MannWhitneyU(base, candidate)
+	_, c.ksP = stats.KolmogorovSmirnov(base, candidate)
+	c.ciLow, c.ciHigh = stats.BootstrapPercentileDelta(base, candidate, 99, opts.confidence, opts.bootstrap, rng)
+
+	// 中位数回退需Mann-Whitney显著，p99回退需置信区间整体大于0
+	c.p50Regress = exceedsTolerance(c.baseP50, c.candP50, opts.tolerance) && c.mwuP < opts.alpha
+	c.p99Regress = exceedsTolerance(c.baseP99, c.candP99, opts.tolerance) && c.ciLow > 0
+	return c
+}
+
+func exceedsTolerance(base, candidate time.Duration, tolerance float64) bool {
+	if base <= 0 {
+		return false
+	}
+	return float64(candidate-base)/float64(base)*100 > tolerance
+}
+
+func formatDelta(base, candidate time.Duration) string {
+	if base <= 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", float64(candidate-base)/float64(base)*100)
+}
+
+func ms(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
+func writeComparisonHeader(w io.Writer, stage string) {
+	fmt.Fprintf(w, "=== %s (ms) ===\n", stage)
+	fmt.Fprintf(w, "%-20s %7s %7s %9s %9s %7s %9s %9s %7s %21s %7s %7s\n",
+		"Group", "N base", "N cand", "p50 base", "p50 cand", "Δp50", "p99 base", "p99 cand", "Δp99", "Δp99 CI", "MWU p", "KS p")
+}
+
+func writeComparison(w io.Writer, c comparison) {
+	ci, mwu, ks := "-", "-", "-"
+	if c.tested {
+		ci = fmt.Sprintf("[%+.3f, %+.3f]", ms(c.ciLow), ms(c.ciHigh))
+		mwu = fmt.Sprintf("%.4f", c.mwuP)
+		ks = fmt.Sprintf("%.4f", c.ksP)
+	}
+	var flags []string
+	if c.p50Regress {
+		flags = append(flags, "p50")
+	}
+	if c.p99Regress {
+		flags = append(flags, "p99")
+	}
+	flag := ""
+	if len(flags) > 0 {
+		flag = "REGRESSION " + strings.Join(flags, ",")
+	}
+	fmt.Fprintf(w, "%-20s %7d %7d %9.3f %9.3f %7s %9.3f %9.3f %7s %21s %7s %7s %s\n",
+		c.group, c.baseCount, c.candCount,
+		ms(c.baseP50), ms(c.candP50), formatDelta(c.baseP50, c.candP50),
+		ms(c.baseP99), ms(c.candP99), formatDelta(c.baseP99, c.candP99),
+		ci, mwu, ks, flag)
+}
+
+func runCompare(args []string) error {
+	fs := newFlagSet("compare", "[options] <baseline> <candidate>",
+		"Compare per-stage latency of two runs. Each run is an OMS log, a CSV exported by latency, or a summary written by latency -summary (.json).")
+	by := fs.String("by", "account,symbol", "comma separated breakdowns besides the overall comparison: account, symbol (empty for overall only; symbol is skipped when either run is a latency CSV)")
+	tolerance := fs.Float64("tolerance", 10, "flag a regression when p50 or p99 grows by more than this percentage and the change is significant")
+	alpha := fs.Float64("alpha", 0.05, "significance level of the Mann-Whitney test for p50 regressions")
+	confidence := fs.Float64("confidence", 0.95, "confidence level of the bootstrap interval of the p99 delta")
+	bootstrap := fs.Int("bootstrap", 2000, "bootstrap iterations")
+	minOrders := fs.Int("min-orders", 20, "groups with fewer orders in either run are listed but not tested")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to compare with UTC FIX timestamps")
+	filterValue := fs.String("filter", "", orderFilterUsage+"; applied to both runs, which must be OMS logs")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <baseline> <candidate>")
+	}
+	var dimensions []string
+	for _, dimension := range strings.Split(*by, ",") {
+		switch dimension = strings.TrimSpace(dimension); dimension {
+		case "":
+		case "account", "symbol":
+			dimensions = append(dimensions, dimension)
+		default:
+			return usageErrorf(fs, "unknown breakdown %q", dimension)
+		}
+	}
+	if *confidence <= 0 || *confidence >= 1 {
+		return usageErrorf(fs, "-confidence must be between 0 and 1")
+	}
+	orderFilter, err := parseOrderFilter(*filterValue, nil)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+	base, err := loadSamples(positional[0], loc, orderFilter)
+	if err != nil {
+		return fmt.Errorf("error loading baseline: %v", err)
+	}
+	candidate, err := loadSamples(positional[1], loc, orderFilter)
+	if err != nil {
+		return fmt.Errorf("error loading candidate: %v", err)
+	}
+
+	// 任一方没有合约时按合约比较只会得到单边的分组，跳过
+	if !base.hasSymbols() || !candidate.hasSymbols() {
+		kept := dimensions[:0]
+		for _, dimension := range dimensions {
+			if dimension == "symbol" {
+				fmt.Fprintln(os.Stderr, "Skipping the symbol breakdown: a latency CSV has no symbols")
+				continue
+			}
+			kept = append(kept, dimension)
+		}
+		dimensions = kept
+	}
+
+	opts := compareOptions{tolerance: *tolerance, alpha: *alpha, bootstrap: *bootstrap, minOrders: *minOrders, confidence: *confidence}
+	// 固定随机种子，同样的输入得到同样的置信区间
+	rng := rand.New(rand.NewSource(1))
+
+	candidateStages := make(map[string]bool)
+	for _, stage := range candidate.stages() {
+		candidateStages[stage] = true
+	}
+	regressions := 0
+	for _, stage := range base.stages() {
+		if !candidateStages[stage] {
+			continue
+		}
+		writeComparisonHeader(os.Stdout, stage)
+		for _, group := range compareGroups(base, candidate, stage, dimensions) {
+			c := compareSamples(group.label, base.merge(group.match), candidate.merge(group.match), opts, rng)
+			writeComparison(os.Stdout, c)
+			if c.p50Regress || c.p99Regress {
+				regressions += 1
+			}
+		}
+		fmt.Println()
+	}
+
+	if regressions > 0 {
+		return checkError{fmt.Sprintf("%d regressions above %.1f%% tolerance", regressions, *tolerance)}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// n个订单，耗时为start起每个订单递增1ms
+func latencyRange(n int, start time.Duration) []time.Duration {
+	values := make([]time.Duration, n)
+	for i := range values {
+		values[i] = start + time.Duration(i)*time.Millisecond
+	}
+	return values
+}
+
+func TestCompareSamples(t *testing.T) {
+	opts := compareOptions{tolerance: 10, alpha: 0.05, bootstrap: 500, minOrders: 20, confidence: 0.95}
+	tests := []struct {
+		name                   string
+		base, candidate        []time.Duration
+		tested                 bool
+		p50Regress, p99Regress bool
+	}{
+		{"unchanged", latencyRange(100, 100*time.Millisecond), latencyRange(100, 100*time.Millisecond), true, false, false},
+		{"slower", latencyRange(100, 100*time.Millisecond), latencyRange(100, 150*time.Millisecond), true, true, true},
+		// 变慢5%，未超过容忍度
+		{"within tolerance", latencyRange(100, 100*time.Millisecond), latencyRange(100, 107*time.Millisecond), true, false, false},
+		{"faster", latencyRange(100, 150*time.Millisecond), latencyRange(100, 100*time.Millisecond), true, false, false},
+		// 订单数不足时只列出分位数，不判断回退
+		{"too few orders", latencyRange(10, 100*time.Millisecond), latencyRange(10, 200*time.Millisecond), false, false, false},
+	}
+	for _, tt := range tests {
+		c := compareSamples(tt.name, tt.base, tt.candidate, opts, rand.New(rand.NewSource(1)))
+		if c.tested != tt.tested || c.p50Regress != tt.p50Regress || c.p99Regress != tt.p99Regress {
+			t.Errorf("%s: tested %v, p50 regression %v, p99 regression %v, want %v, %v, %v (MWU p %.4f, CI [%v, %v])",
+				tt.name, c.tested, c.p50Regress, c.p99Regress, tt.tested, tt.p50Regress, tt.p99Regress, c.mwuP, c.ciLow, c.ciHigh)
+		}
+	}
+}
+
+// 写一个latency导出的CSV，每个订单的OmsCostTime1耗时取自values
+func writeLatencyCsv(t *testing.T, path string, values []time.Duration) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("Account,ClientOrderID,OmsCostTime1\n")
+	for i, v := range values {
+		fmt.Fprintf(&b, "ACC1,C%d,%.3f\n", i, ms(v))
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompareExitCode(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.csv")
+	same := filepath.Join(dir, "same.csv")
+	slower := filepath.Join(dir, "slower.csv")
+	writeLatencyCsv(t, base, latencyRange(100, 100*time.Millisecond))
+	writeLatencyCsv(t, same, latencyRange(100, 100*time.Millisecond))
+	writeLatencyCsv(t, slower, latencyRange(100, 150*time.Millisecond))
+
+	if err := runCompare([]string{"-by", "", base, slower}); err == nil {
+		t.Errorf("no error for a regression")
+	} else if _, ok := err.(checkError); !ok {
+		t.Errorf("error %v is not a check failure", err)
+	}
+
+	tests := []struct {
+		args []string
+		code int
+	}{
+		{[]string{"compare", base, same}, exitOK},
+		{[]string{"compare", base, slower}, exitCheck},
+		{[]string{"compare", "-tolerance", "60", base, slower}, exitOK},
+		{[]string{"compare", base}, exitUsage},
+		{[]string{"compare", base, filepath.Join(dir, "missing.csv")}, exitError},
+	}
+	for _, tt := range tests {
+		if code := run(tt.args); code != tt.code {
+			t.Errorf("%q: exit code %d, want %d", tt.args, code, tt.code)
+		}
+	}
+}
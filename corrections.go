@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"v8/internal/logline"
+)
+
+// grep "150=G" matching_engine_20240414.log | grep "send" | grep -e "56=FT" -e "56=HRT" > 150G.log
+
+// 撮合引擎发给FT/HRT的JNET更正回报(原v10)
+type CorrectionOrder struct {
+	LogSendTime string `fix:"time"`
+	OrderType   string `fix:"35"`
+	ClOrderId   string `fix:"11"`
+	Account     string `fix:"1"`
+	Symbol      string `fix:"55"`
+	ExecID      string `fix:"17"`
+
+	Decoded   map[string]string `json:",omitempty"` // -decode时附加的报文类型名
+	Reference map[string]string `json:",omitempty"` // -accounts、-symbols时附加的参考数据属性
+}
+
+// 发给FT/HRT会话的JNET更正
+const correctionFilter = "dir=send and 150=G and 56 in (FT*,HRT*)"
+
+func runCorrections(args []string) error {
+	fs := newFlagSet("corrections", "[options] <logFilePath> <outputJsonlPath>", "Export JNET corrections (150=G) sent by the matching engine to FT/HRT sessions to JSONL, sorted by log time.")
+	parquetPath := fs.String("parquet", "", "also export the records to this Parquet file, with the log time as int64 nanoseconds")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to convert it to UTC in Parquet")
+	decode := fs.Bool("decode", false, "add a Decoded object with the message type name from the data dictionary to each JSONL record")
+	filterValue := fs.String("filter", "", lineFilterUsage+", in addition to the FT/HRT correction selection; Account./Symbol. attributes of -accounts/-symbols can be used too")
+	by := fs.String("by", "", "comma separated Account, Symbol or reference attributes, print the record count per value of each, e.g. Symbol.market")
+	dictPaths := addDictFlag(fs)
+	refFlags := addReferenceFlags(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <outputJsonlPath>")
+	}
+	logFilePath := positional[0]
+	outputJsonlPath := positional[1]
+	dict, err := dictPaths.load()
+	if err != nil {
+		return err
+	}
+	ref, err := refFlags.load()
+	if err != nil {
+		return err
+	}
+	groups := splitList(*by)
+	if err := checkRecordGroups(ref, groups); err != nil {
+		return usageErrorf(fs, "-by: %v", err)
+	}
+	filter, err := newLineFilter(dict, ref, correctionFilter, *filterValue)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+
+	orders, err := scanOrders(logFilePath, filter, func(order CorrectionOrder) string { return order.ClOrderId })
+	if err != nil {
+		return fmt.Errorf("error getting orders: %v", err)
+	}
+
+	ordersSlice := sortByLogTime(orders, func(order CorrectionOrder) string { return order.LogSendTime })
+	for i := range ordersSlice {
+		if *decode {
+			ordersSlice[i].Decoded = decodeValues(dict, nil, "", "35", ordersSlice[i].OrderType)
+		}
+		ordersSlice[i].OrderType = orderTypeName(ordersSlice[i].OrderType)
+		ordersSlice[i].Reference = ref.values(ordersSlice[i].Account, ordersSlice[i].Symbol)
+	}
+	if err := logline.WriteJsonl(outputJsonlPath, ordersSlice); err != nil {
+		return fmt.Errorf("error exporting to JSONL: %v", err)
+	}
+	if *parquetPath != "" {
+		loc, err := time.LoadLocation(*logTimeZone)
+		if err != nil {
+			return fmt.Errorf("error loading time zone: %v", err)
+		}
+		if err := exportCorrectionsParquet(ordersSlice, *parquetPath, loc, ref.attributes()); err != nil {
+			return fmt.Errorf("error exporting to Parquet: %v", err)
+		}
+	}
+
+	writeGroupCounts(os.Stdout, groups, len(ordersSlice), func(i int, name string) string {
+		return recordGroup(ref, name, ordersSlice[i].Account, ordersSlice[i].Symbol)
+	})
+	fmt.Println("Orders exported successfully to", outputJsonlPath)
+	return nil
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/logline"
+)
+
+func topSlowOrders(orders []*fixlog.Order, stage fixlog.Stage, n int) []*fixlog.Order {
+	type costOrder struct {
+		order *fixlog.Order
+		cost  time.Duration
+	}
+
+	costOrders := make([]costOrder, 0, len(orders))
+	for _, order := range orders {
+		// 缺少时间点的订单不参与排序
+		if cost, ok := stage.Cost(order); ok {
+			costOrders = append(costOrders, costOrder{order, cost})
+		}
+	}
+
+	// 耗时相同时按ClOrdID排序，保证输出稳定
+	sort.Slice(costOrders, func(i, j int) bool {
+		if costOrders[i].cost != costOrders[j].cost {
+			return costOrders[i].cost > costOrders[j].cost
+		}
+		return costOrders[i].order.ClOrdID < costOrders[j].order.ClOrdID
+	})
+
+	if n > len(costOrders) {
+		n = len(costOrders)
+	}
+	slowest := make([]*fixlog.Order, 0, n)
+	for _, co := range costOrders[:n] {
+		slowest = append(slowest, co.order)
+	}
+	return slowest
+}
+
+// 订单在OMS日志中的各时间点，缺失的时间点时间为零值、行号为0
+func orderMilestones(order *fixlog.Order) []fixlog.Milestone {
+	milestones := make([]fixlog.Milestone, 0, len(fixlog.LogMilestones))
+	for _, kind := range fixlog.LogMilestones {
+		m, _ := order.Milestone(kind)
+		m.Kind = kind
+		milestones = append(milestones, m)
+	}
+	return milestones
+}
+
+func formatMilestoneTime(m fixlog.Milestone) string {
+	if m.Time.IsZero() {
+		return ""
+	}
+	return m.Time.Format(logline.TimeLayout)
+}
+
+// 读取日志中指定行号前后contextLines行（含非FIX的应用日志），返回行号到内容的映射
+func collectLogContext(filename string, lineNos []int, contextLines int) (map[int]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	wanted := make(map[int]bool)
+	for _, lineNo := range lineNos {
+		if lineNo <= 0 {
+			continue
+		}
+		for i := lineNo - contextLines; i <= lineNo+contextLines; i++ {
+			wanted[i] = true
+		}
+	}
+
+	lines := make(map[int]string)
+	scanner := logline.NewScanner(file)
+	for scanner.Scan() {
+		if wanted[scanner.LineNo()] {
+			lines[scanner.LineNo()] = scanner.Text()
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	return lines, nil
+}
+
+func writeExemplar(w io.Writer, rank int, stage fixlog.Stage, order *fixlog.Order, lines map[int]string, contextLines int) error {
+	fmt.Fprintf(w, "#%d ClientOrderID=%s Account=%s %s=%sms\n", rank, order.ClOrdID, order.Account, stage.Name, formatCost(stage, order))
+
+	milestones := orderMilestones(order)
+	marked := make(map[int]bool)
+	for _, m := range milestones {
+		fmt.Fprintf(w, "  %-22s %s  (line %d)\n", m.Kind, formatMilestoneTime(m), m.LineNo)
+		marked[m.LineNo] = true
+	}
+
+	// 原始FIX报文
+	fmt.Fprintln(w, "  raw:")
+	for _, m := range milestones {
+		fmt.Fprintf(w, "    %-22s %s\n", m.Kind, lines[m.LineNo])
+	}
+
+	if contextLines <= 0 {
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+
+	// 合并各时间点的上下文区间，重叠部分只输出一次
+	var lineNos []int
+	seen := make(map[int]bool)
+	for _, m := range milestones {
+		for i := m.LineNo - contextLines; i <= m.LineNo+contextLines; i++ {
+			if _, ok := lines[i]; ok && !seen[i] {
+				seen[i] = true
+				lineNos = append(lineNos, i)
+			}
+		}
+	}
+	sort.Ints(lineNos)
+
+	fmt.Fprintln(w, "  context:")
+	for i, lineNo := range lineNos {
+		if i > 0 && lineNo != lineNos[i-1]+1 {
+			fmt.Fprintln(w, "    ...")
+		}
+		mark := " "
+		if marked[lineNo] {
+			mark = ">"
+		}
+		fmt.Fprintf(w, "  %s %6d: %s\n", mark, lineNo, lines[lineNo])
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// 按阶段输出最慢的topN笔订单，附带各时间点、原始FIX报文及日志上下文
+func exportExemplars(orders []*fixlog.Order, logFilename string, exemplarFilename string, topN int, contextLines int) error {
+	var w io.Writer = os.Stdout
+	if exemplarFilename != "" {
+		file, err := os.Create(exemplarFilename)
+		if err != nil {
+			return fmt.Errorf("error creating exemplar file: %v", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	slowestByStage := make([][]*fixlog.Order, len(fixlog.CoreStages))
+	var lineNos []int
+	for i, stage := range fixlog.CoreStages {
+		slowest := topSlowOrders(orders, stage, topN)
+		slowestByStage[i] = slowest
+		for _, order := range slowest {
+			for _, m := range orderMilestones(order) {
+				lineNos = append(lineNos, m.LineNo)
+			}
+		}
+	}
+
+	lines, err := collectLogContext(logFilename, lineNos, contextLines)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for i, stage := range fixlog.CoreStages {
+		fmt.Fprintf(bw, "=== %s top %d ===\n", stage.Name, len(slowestByStage[i]))
+		for rank, order := range slowestByStage[i] {
+			if err := writeExemplar(bw, rank+1, stage, order, lines, contextLines); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("error writing exemplars: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"v8/internal/fixdict"
+	"v8/internal/logline"
+	"v8/internal/parquet"
+)
+
+// 取不到的值ok为false：CSV中留空，JSONL中省略，Parquet中为null
+type extractRow struct {
+	values []string
+	ok     []bool
+}
+
+type rowWriter interface {
+	write(row extractRow) error
+	Close() error
+}
+
+type csvRowWriter struct {
+	file   *os.File // 输出到标准输出时为nil
+	writer *csv.Writer
+}
+
+func (w *csvRowWriter) write(row extractRow) error {
+	if err := w.writer.Write(row.values); err != nil {
+		return fmt.Errorf("error writing record to CSV file: %v", err)
+	}
+	return nil
+}
+
+func (w *csvRowWriter) Close() error {
+	w.writer.Flush()
+	if err := w.writer.Error(); err != nil {
+		if w.file != nil {
+			w.file.Close()
+		}
+		return fmt.Errorf("error flushing data to CSV file: %v", err)
+	}
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
+
+type jsonlRowWriter struct {
+	file    *os.File
+	writer  *bufio.Writer
+	columns []string
+}
+
+func (w *jsonlRowWriter) write(row extractRow) error {
+	var record jsonObject
+	for i, column := range w.columns {
+		if row.ok[i] {
+			record.add(column, row.values[i])
+		}
+	}
+	jsonBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error marshalling to JSON: %v", err)
+	}
+	if _, err := w.writer.Write(append(jsonBytes, '\n')); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
+
+func (w *jsonlRowWriter) Close() error {
+	defer w.file.Close()
+	if err := w.writer.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return w.file.Close()
+}
+
+// time为TIMESTAMP，line为INT64，其余为可空字符串
+type parquetRowWriter struct {
+	*parquetFile
+	columns []string
+	loc     *time.Location
+}
+
+func (w *parquetRowWriter) write(row extractRow) error {
+	values := make([]any, len(w.columns))
+	for i, column := range w.columns {
+		switch {
+		case !row.ok[i]:
+			values[i] = nil
+		case column == fieldTime:
+			values[i] = logTimeNanos(row.values[i], w.loc)
+		case column == fieldLine:
+			values[i] = optionalInt(row.values[i])
+		default:
+			values[i] = row.values[i]
+		}
+	}
+	return w.writer.Write(values...)
+}
+
+// 枚举字段及账户、合约、CompID取值较少，使用字典编码
+func parquetColumnType(dict *fixdict.Dictionary, tag string) parquet.Type {
+	switch tag {
+	case fieldTime:
+		return parquet.Timestamp
+	case fieldLine:
+		return parquet.Int64
+	case fieldDir, "1", "49", "55", "56":
+		return parquet.DictString
+	}
+	if isReferenceAttribute(tag) {
+		return parquet.DictString
+	}
+	if field, ok := dict.Field(tag); ok && len(field.Values) > 0 {
+		return parquet.DictString
+	}
+	return parquet.String
+}
+
+// 输出格式为扩展名，不支持的格式返回空串
+func outputFormat(outputPath string) string {
+	switch ext := strings.ToLower(filepath.Ext(outputPath)); ext {
+	case ".csv", ".jsonl", ".json", ".parquet":
+		return ext
+	}
+	return ""
+}
+
+// 按输出文件的扩展名选择格式，-为输出CSV到标准输出
+func newRowWriter(outputPath string, columns []string, fields map[string]string, dict *fixdict.Dictionary, loc *time.Location) (rowWriter, error) {
+	if outputPath == "-" {
+		w := csv.NewWriter(os.Stdout)
+		if err := w.Write(columns); err != nil {
+			return nil, fmt.Errorf("error writing header to CSV file: %v", err)
+		}
+		return &csvRowWriter{writer: w}, nil
+	}
+	ext := outputFormat(outputPath)
+	switch ext {
+	case ".csv", ".jsonl", ".json":
+	case ".parquet":
+		var parquetColumns []parquet.Column
+		for _, column := range columns {
+			parquetColumns = append(parquetColumns, parquet.Column{Name: column, Type: parquetColumnType(dict, fields[column]), Optional: true})
+		}
+		file, err := createParquet(outputPath, parquetColumns)
+		if err != nil {
+			return nil, err
+		}
+		return &parquetRowWriter{parquetFile: file, columns: columns, loc: loc}, nil
+	}
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	if ext == ".csv" {
+		w := csv.NewWriter(file)
+		if err := w.Write(columns); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("error writing header to CSV file: %v", err)
+		}
+		return &csvRowWriter{file: file, writer: w}, nil
+	}
+	return &jsonlRowWriter{file: file, writer: bufio.NewWriter(file), columns: columns}, nil
+}
+
+func runExtract(args []string) error {
+	fs := newFlagSet("extract", "[options] <logFilePath> <outputPath>",
+		"Extract the messages matching a filter expression to CSV, JSONL or Parquet (by the output extension, - for CSV to standard output), one row per message with the given columns.")
+	filterValue := fs.String("filter", "", `filter expression over tags and log metadata, e.g. "35=D and 49=HRT* and dir=recv" (default all FIX messages); Account./Symbol. attributes of -accounts/-symbols can be used too`)
+	columnsValue := fs.String("columns", "time,dir,35,49,56,11", "comma separated columns: tag numbers, field names from the data dictionary, time (log time), dir (send/recv), line (line number) or reference attributes such as Account.desk")
+	unique := fs.String("unique", "", "keep only the last message for each value of this column, in the order of those last messages")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to convert it to UTC in Parquet")
+	dictPaths := addDictFlag(fs)
+	refFlags := addReferenceFlags(fs)
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <outputPath>")
+	}
+	logFilePath, outputPath := positional[0], positional[1]
+	if outputPath != "-" && outputFormat(outputPath) == "" {
+		return usageErrorf(fs, "unsupported output format %q, expected .csv, .jsonl or .parquet", filepath.Ext(outputPath))
+	}
+
+	dict, err := dictPaths.load()
+	if err != nil {
+		return err
+	}
+	ref, err := refFlags.load()
+	if err != nil {
+		return err
+	}
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+
+	filter, err := newLineFilter(dict, ref, *filterValue)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+	columns := splitList(*columnsValue)
+	if len(columns) == 0 {
+		return usageErrorf(fs, "-columns: no column given")
+	}
+	uniqueIndex := -1
+	for i, column := range columns {
+		if column == *unique {
+			uniqueIndex = i
+		}
+	}
+	if *unique != "" && uniqueIndex < 0 {
+		return usageErrorf(fs, "-unique: %s is not one of the columns", *unique)
+	}
+	fields, err := resolveLineFields(dict, ref, columns)
+	if err != nil {
+		return usageErrorf(fs, "%v", err)
+	}
+
+	out, err := newRowWriter(outputPath, columns, fields, dict, loc)
+	if err != nil {
+		return fmt.Errorf("error exporting records: %v", err)
+	}
+
+	// -unique时先按键保留最后一条，读完后按这些报文在日志中的顺序写出
+	var rows []extractRow
+	lastRow := make(map[string]int)
+	count := 0
+	err = scanLog(logFilePath, func(line string, lineNo int) error {
+		if !logline.IsFix(line) {
+			return nil
+		}
+		if !filter.match(line, lineNo) {
+			return nil
+		}
+		env := lineEnv{line: line, lineNo: lineNo, fields: fields, ref: ref}
+		count += 1
+		row := extractRow{make([]string, len(columns)), make([]bool, len(columns))}
+		for i, column := range columns {
+			row.values[i], row.ok[i] = env.Lookup(column)
+		}
+		if uniqueIndex < 0 {
+			return out.write(row)
+		}
+		key := row.values[uniqueIndex]
+		if i, ok := lastRow[key]; ok {
+			rows[i].values = nil
+		}
+		lastRow[key] = len(rows)
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		out.Close()
+		return fmt.Errorf("error exporting records: %v", err)
+	}
+	written := count
+	if uniqueIndex >= 0 {
+		written = len(lastRow)
+		for _, row := range rows {
+			if row.values == nil {
+				continue
+			}
+			if err := out.write(row); err != nil {
+				out.Close()
+				return fmt.Errorf("error exporting records: %v", err)
+			}
+		}
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("error exporting records: %v", err)
+	}
+
+	if outputPath != "-" {
+		fmt.Println("Matched Message Count: ", count)
+		fmt.Printf("%d records exported successfully to %s\n", written, outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/expr"
+	"v8/internal/fixdict"
+	"v8/internal/logline"
+)
+
+const (
+	lineFilterUsage  = `only keep messages matching this filter expression over tags, data dictionary field names, time, dir and line, e.g. "54=1 and 38>1000"`
+	orderFilterUsage = `only keep orders matching this filter expression over order fields, tags, stages and milestones, e.g. "Account in (A1,A2) and 55 ~ ^7203" or "TotalCostTime > 5ms"`
+)
+
+// 日志行的元数据字段，其余名称为标签号或字典中的字段名
+const (
+	fieldTime = "time" // 日志前缀时间
+	fieldDir  = "dir"  // 收发方向，send或recv
+	fieldLine = "line" // 行号
+)
+
+func isTagNumber(name string) bool {
+	_, err := strconv.ParseUint(name, 10, 32)
+	return err == nil
+}
+
+// 将过滤条件和列中的名称解析为标签号，元数据字段和参考数据属性保持不变
+func resolveLineFields(dict *fixdict.Dictionary, ref *referenceData, names []string) (map[string]string, error) {
+	resolved := make(map[string]string)
+	for _, name := range names {
+		switch {
+		case name == fieldTime || name == fieldDir || name == fieldLine:
+			resolved[name] = name
+		case ref.hasAttribute(name):
+			resolved[name] = name
+		case isTagNumber(name):
+			resolved[name] = name
+		default:
+			tag, ok := dict.Tag(name)
+			if !ok {
+				if err := ref.check(name); err != nil {
+					return nil, err
+				}
+				return nil, fmt.Errorf("unknown field %q, expected a tag number, a field name from the data dictionary, %s, %s or %s", name, fieldTime, fieldDir, fieldLine)
+			}
+			resolved[name] = tag
+		}
+	}
+	return resolved, nil
+}
+
+// 一条日志行作为表达式的求值环境
+type lineEnv struct {
+	line   string
+	lineNo int
+	fields map[string]string // 名称 -> 标签号、元数据字段或参考数据属性
+	ref    *referenceData
+}
+
+func (e lineEnv) Lookup(name string) (string, bool) {
+	switch tag := e.fields[name]; tag {
+	case fieldTime:
+		return logline.Time(e.line)
+	case fieldDir:
+		direction := logline.Direction(e.line)
+		return direction, direction != ""
+	case fieldLine:
+		return strconv.Itoa(e.lineNo), true
+	case "":
+		return "", false
+	default:
+		if e.ref.hasAttribute(tag) {
+			account, _ := logline.Tag(e.line, "1")
+			symbol, _ := logline.Tag(e.line, "55")
+			return e.ref.lookup(tag, account, symbol)
+		}
+		return logline.Tag(e.line, tag)
+	}
+}
+
+// 日志行过滤条件，各表达式均满足时匹配；nil匹配全部
+type lineFilter struct {
+	exprs  []expr.Expr
+	fields map[string]string
+	ref    *referenceData
+}
+
+// 解析日志行的过滤表达式，忽略空的表达式，全部为空时返回nil
+func newLineFilter(dict *fixdict.Dictionary, ref *referenceData, sources ...string) (*lineFilter, error) {
+	f := &lineFilter{ref: ref}
+	var names []string
+	for _, src := range sources {
+		if strings.TrimSpace(src) == "" {
+			continue
+		}
+		e, err := expr.Parse(src)
+		if err != nil {
+			return nil, err
+		}
+		f.exprs = append(f.exprs, e)
+		names = append(names, expr.Names(e)...)
+	}
+	if len(f.exprs) == 0 {
+		return nil, nil
+	}
+	fields, err := resolveLineFields(dict, ref, names)
+	if err != nil {
+		return nil, err
+	}
+	f.fields = fields
+	return f, nil
+}
+
+func (f *lineFilter) match(line string, lineNo int) bool {
+	if f == nil {
+		return true
+	}
+	env := lineEnv{line: line, lineNo: lineNo, fields: f.fields, ref: f.ref}
+	for _, e := range f.exprs {
+		if !e.Eval(env) {
+			return false
+		}
+	}
+	return true
+}
+
+// 取订单上的一个字段
+type orderField func(order *fixlog.Order) (string, bool)
+
+// 订单字段对应的标签号，过滤条件中可用标签号或字典中的字段名代替
+var orderFieldTags = map[string]string{
+	"1":  "Account",
+	"11": "ClOrdID",
+	"38": "OrderQty",
+	"49": "ClientCompID",
+	"54": "Side",
+	"55": "Symbol",
+}
+
+// 执行回报字段对应的标签号，用于Fill.、Correction.、Final.之后
+var executionFieldTags = map[string]string{
+	"17":  "ExecID",
+	"19":  "ExecRefID",
+	"37":  "OrderID",
+	"150": "ExecType",
+	"39":  "OrdStatus",
+	"20":  "ExecTransType",
+	"32":  "LastQty",
+	"31":  "LastPx",
+	"49":  "SenderCompID",
+	"56":  "TargetCompID",
+}
+
+func nonEmpty(value string) (string, bool) {
+	return value, value != ""
+}
+
+// 标签号或字典中的字段名
+func fieldTag(dict *fixdict.Dictionary, name string) string {
+	if isTagNumber(name) {
+		return name
+	}
+	tag, _ := dict.Tag(name)
+	return tag
+}
+
+// 订单字段、阶段耗时(如5.2ms)、时间点(RFC3339)；time为收到客户端订单的时间
+func orderFieldByName(name string) (orderField, bool) {
+	switch name {
+	case "ClOrdID":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.ClOrdID) }, true
+	case "Account":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.Account) }, true
+	case "Symbol":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.Symbol) }, true
+	case "Side":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.Side) }, true
+	case "OrderQty":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.OrderQty) }, true
+	case "ClientCompID":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.ClientCompID) }, true
+	case "MatchClOrdID":
+		return func(o *fixlog.Order) (string, bool) { return nonEmpty(o.MatchClOrdID) }, true
+	case fieldTime:
+		name = fixlog.RecvClient.String()
+	}
+	if stage, ok := fixlog.StageByName(name); ok {
+		return func(o *fixlog.Order) (string, bool) {
+			cost, ok := stage.Cost(o)
+			if !ok {
+				return "", false
+			}
+			return cost.String(), true
+		}, true
+	}
+	var kind fixlog.MilestoneKind
+	if err := kind.UnmarshalText([]byte(name)); err == nil {
+		return func(o *fixlog.Order) (string, bool) {
+			m, ok := o.Milestone(kind)
+			if !ok {
+				return "", false
+			}
+			return m.Time.Format(time.RFC3339Nano), true
+		}, true
+	}
+	return nil, false
+}
+
+// Fill.LastPx、Final.150等执行回报字段
+func executionFieldByName(dict *fixdict.Dictionary, name string) (orderField, bool) {
+	prefix, field, ok := strings.Cut(name, ".")
+	if !ok {
+		return nil, false
+	}
+	var execution func(o *fixlog.Order) *fixlog.Execution
+	switch prefix {
+	case "Fill":
+		execution = func(o *fixlog.Order) *fixlog.Execution { return o.Fill }
+	case "Correction":
+		execution = func(o *fixlog.Order) *fixlog.Execution { return o.Correction }
+	case "Final":
+		execution = func(o *fixlog.Order) *fixlog.Execution { return o.Final }
+	default:
+		return nil, false
+	}
+	if named, ok := executionFieldTags[fieldTag(dict, field)]; ok {
+		field = named
+	}
+	var value func(e *fixlog.Execution) string
+	switch field {
+	case "ExecID":
+		value = func(e *fixlog.Execution) string { return e.ExecID }
+	case "ExecRefID":
+		value = func(e *fixlog.Execution) string { return e.ExecRefID }
+	case "OrderID":
+		value = func(e *fixlog.Execution) string { return e.OrderID }
+	case "ExecType":
+		value = func(e *fixlog.Execution) string { return e.ExecType }
+	case "OrdStatus":
+		value = func(e *fixlog.Execution) string { return e.OrdStatus }
+	case "ExecTransType":
+		value = func(e *fixlog.Execution) string { return e.ExecTransType }
+	case "LastQty":
+		value = func(e *fixlog.Execution) string { return e.LastQty }
+	case "LastPx":
+		value = func(e *fixlog.Execution) string { return e.LastPx }
+	case "SenderCompID":
+		value = func(e *fixlog.Execution) string { return e.SenderCompID }
+	case "TargetCompID":
+		value = func(e *fixlog.Execution) string { return e.TargetCompID }
+	default:
+		return nil, false
+	}
+	return func(o *fixlog.Order) (string, bool) {
+		e := execution(o)
+		if e == nil {
+			return "", false
+		}
+		return nonEmpty(value(e))
+	}, true
+}
+
+// 参考数据中按订单的账户和合约取的属性
+func referenceField(ref *referenceData, name string) (orderField, bool) {
+	if !ref.hasAttribute(name) {
+		return nil, false
+	}
+	return func(o *fixlog.Order) (string, bool) { return ref.lookup(name, o.Account, o.Symbol) }, true
+}
+
+// 将过滤条件和分组中的名称解析为订单上的字段
+func resolveOrderFields(dict *fixdict.Dictionary, ref *referenceData, names []string) (map[string]orderField, error) {
+	resolved := make(map[string]orderField)
+	for _, name := range names {
+		if err := ref.check(name); err != nil {
+			return nil, err
+		}
+		field, ok := referenceField(ref, name)
+		if !ok {
+			field, ok = orderFieldByName(name)
+		}
+		if !ok {
+			field, ok = executionFieldByName(dict, name)
+		}
+		if !ok {
+			if named, isOrderTag := orderFieldTags[fieldTag(dict, name)]; isOrderTag {
+				field, ok = orderFieldByName(named)
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown order field %q, expected Account, Symbol, Side, OrderQty, ClOrdID, ClientCompID, MatchClOrdID or their tag, a stage, a milestone, time, or Fill./Correction./Final. followed by an execution report field", name)
+		}
+		resolved[name] = field
+	}
+	return resolved, nil
+}
+
+// 一笔订单作为表达式的求值环境
+type orderEnv struct {
+	order  *fixlog.Order
+	fields map[string]orderField
+}
+
+func (e orderEnv) Lookup(name string) (string, bool) {
+	field, ok := e.fields[name]
+	if !ok {
+		return "", false
+	}
+	return field(e.order)
+}
+
+// 解析订单过滤表达式，用于fixlog.WithOrderFilter；表达式为空时返回nil。ref为nil时不能引用参考数据属性
+func parseOrderFilter(src string, ref *referenceData) (func(order *fixlog.Order) bool, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	e, err := expr.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := resolveOrderFields(fixdict.Default(), ref, expr.Names(e))
+	if err != nil {
+		return nil, err
+	}
+	return func(order *fixlog.Order) bool {
+		return e.Eval(orderEnv{order: order, fields: fields})
+	}, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/fixdict"
+	"v8/internal/refdata"
+)
+
+func testReferenceData(t *testing.T) *referenceData {
+	t.Helper()
+	accounts, err := refdata.ReadCSV(strings.NewReader("Account,desk\nACC1,Equity\n"), "Account")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &referenceData{accounts: accounts}
+}
+
+func TestResolveLineFields(t *testing.T) {
+	dict := fixdict.Default()
+	ref := testReferenceData(t)
+	tests := []struct {
+		name string
+		ref  *referenceData
+		want string // 解析结果，为空时应报错
+		err  string
+	}{
+		{"time", nil, "time", ""},
+		{"dir", nil, "dir", ""},
+		{"line", nil, "line", ""},
+		{"54", nil, "54", ""},
+		{"9999", nil, "9999", ""},
+		{"Side", nil, "54", ""},
+		{"ExecType", nil, "150", ""},
+		{"Account.desk", ref, "Account.desk", ""},
+		{"NoSuchField", nil, "", `unknown field "NoSuchField"`},
+		{"-1", nil, "", `unknown field "-1"`},
+		{"Account.desk", nil, "", `unknown field "Account.desk"`},
+		{"Account.desk", &referenceData{}, "", "Account.desk needs -accounts"},
+		{"Symbol.market", ref, "", "Symbol.market needs -symbols"},
+		{"Account.region", ref, "", `unknown reference attribute "Account.region", Account has desk`},
+	}
+	for _, tt := range tests {
+		resolved, err := resolveLineFields(dict, tt.ref, []string{tt.name})
+		if tt.want == "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("resolveLineFields(%q) error = %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolveLineFields(%q) error = %v", tt.name, err)
+			continue
+		}
+		if got := resolved[tt.name]; got != tt.want {
+			t.Errorf("resolveLineFields(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLineFilter(t *testing.T) {
+	line := "D0411 04/11/2024 09:00:00.600000 1234 session.cpp:88] recv 8=FIX.4.2|9=95|35=D|49=HRT01|56=OMS|34=1|11=C0|1=ACC1|55=7203|54=1|38=100|44=1000|10=000|"
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"35=D and Side=1 and OrderQty>=100", true},
+		{"dir=recv and time>=09:00 and time<09:00:01", true},
+		{"dir=send", false},
+		{"line=7", true},
+		{"line>7", false},
+		{"Account.desk=Equity", true},
+		{"Account.desk!=Equity", false},
+		{"55 in (7203,6758) and 49=HRT*", true},
+		{"150=G", false},
+		{"150!=G", true},
+	}
+	for _, tt := range tests {
+		f, err := newLineFilter(fixdict.Default(), testReferenceData(t), tt.filter)
+		if err != nil {
+			t.Errorf("newLineFilter(%q) error = %v", tt.filter, err)
+			continue
+		}
+		if got := f.match(line, 7); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+
+	f, err := newLineFilter(fixdict.Default(), nil, "", " ")
+	if err != nil || f != nil {
+		t.Errorf("newLineFilter of empty filters = %v, %v, want nil", f, err)
+	}
+	if !f.match(line, 1) {
+		t.Errorf("nil filter should match every line")
+	}
+}
+
+func TestResolveOrderFields(t *testing.T) {
+	at := func(ms int) fixlog.Milestone {
+		return fixlog.Milestone{Time: time.Date(2024, 4, 11, 9, 0, 0, 0, time.UTC).Add(time.Duration(ms) * time.Millisecond)}
+	}
+	order := &fixlog.Order{
+		ClOrdID:      "C1",
+		Account:      "ACC1",
+		Symbol:       "7203",
+		Side:         "1",
+		OrderQty:     "100",
+		ClientCompID: "HRT01",
+		MatchClOrdID: "R1",
+		Fill:         &fixlog.Execution{ExecID: "E1", ExecType: "2", LastQty: "100", LastPx: "1000"},
+		Final:        &fixlog.Execution{ExecType: "G", OrdStatus: "2"},
+		Milestones: map[fixlog.MilestoneKind]fixlog.Milestone{
+			fixlog.RecvClient:    at(0),
+			fixlog.SendMatch:     at(5),
+			fixlog.RecvMatchFill: at(7),
+		},
+	}
+	dict := fixdict.Default()
+	ref := testReferenceData(t)
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"ClOrdID", "C1", true},
+		{"11", "C1", true},
+		{"Account", "ACC1", true},
+		{"1", "ACC1", true},
+		{"Symbol", "7203", true},
+		{"55", "7203", true},
+		{"Side", "1", true},
+		{"54", "1", true},
+		{"OrderQty", "100", true},
+		{"38", "100", true},
+		{"ClientCompID", "HRT01", true},
+		{"49", "HRT01", true},
+		{"MatchClOrdID", "R1", true},
+		{"OmsCostTime1", "5ms", true},
+		{"MatchCostTime", "2ms", true},
+		{"TotalCostTime", "", false},
+		{"RecvClientTime", "2024-04-11T09:00:00Z", true},
+		{"time", "2024-04-11T09:00:00Z", true},
+		{"FinalReturnTime", "", false},
+		{"Fill.ExecID", "E1", true},
+		{"Fill.17", "E1", true},
+		{"Fill.LastPx", "1000", true},
+		{"Fill.31", "1000", true},
+		{"Fill.OrdStatus", "", false},
+		{"Final.150", "G", true},
+		{"Final.ExecType", "G", true},
+		{"Correction.ExecType", "", false},
+		{"Account.desk", "Equity", true},
+	}
+	for _, tt := range tests {
+		resolved, err := resolveOrderFields(dict, ref, []string{tt.name})
+		if err != nil {
+			t.Errorf("resolveOrderFields(%q) error = %v", tt.name, err)
+			continue
+		}
+		value, ok := resolved[tt.name](order)
+		if value != tt.value || ok != tt.ok {
+			t.Errorf("%s = %q, %v, want %q, %v", tt.name, value, ok, tt.value, tt.ok)
+		}
+	}
+
+	for name, want := range map[string]string{
+		"NoSuchField":    `unknown order field "NoSuchField"`,
+		"Fill.NoSuch":    `unknown order field "Fill.NoSuch"`,
+		"Other.ExecID":   `unknown order field "Other.ExecID"`,
+		"150":            `unknown order field "150"`,
+		"Symbol.market":  "Symbol.market needs -symbols",
+		"Account.region": `unknown reference attribute "Account.region"`,
+	} {
+		if _, err := resolveOrderFields(dict, ref, []string{name}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("resolveOrderFields(%q) error = %v, want %q", name, err, want)
+		}
+	}
+	if _, err := resolveOrderFields(dict, nil, []string{"Account.desk"}); err == nil {
+		t.Errorf("resolveOrderFields(Account.desk) without reference data should fail")
+	}
+}
+
+func TestParseOrderFilter(t *testing.T) {
+	order := &fixlog.Order{
+		ClOrdID: "C1",
+		Account: "ACC1",
+		Symbol:  "7203",
+		Milestones: map[fixlog.MilestoneKind]fixlog.Milestone{
+			fixlog.RecvClient: {Time: time.Date(2024, 4, 11, 9, 30, 0, 0, time.UTC)},
+			fixlog.SendMatch:  {Time: time.Date(2024, 4, 11, 9, 30, 0, int(300*time.Microsecond), time.UTC)},
+		},
+	}
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{"Account in (ACC1,ACC2) and 55 ~ ^72", true},
+		{"OmsCostTime1 > 250us and OmsCostTime1 < 1ms", true},
+		{"time >= 09:30 and time < 10:00", true},
+		{"time > \"2024-04-11 09:30:00\"", false},
+		{"TotalCostTime > 5ms", false},
+		{"TotalCostTime < 5ms", false},
+		{"not TotalCostTime > 5ms", true},
+		{"Account.desk = Equity and Side != 2", true},
+	}
+	for _, tt := range tests {
+		filter, err := parseOrderFilter(tt.filter, testReferenceData(t))
+		if err != nil {
+			t.Errorf("parseOrderFilter(%q) error = %v", tt.filter, err)
+			continue
+		}
+		if got := filter(order); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+	if filter, err := parseOrderFilter(" ", nil); filter != nil || err != nil {
+		t.Errorf("parseOrderFilter of an empty filter = %v, want nil", err)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"v8/internal/fixdict"
+	"v8/internal/logline"
+)
+
+// 按插入顺序输出的JSON对象，使字段顺序与报文一致
+type jsonObject struct {
+	keys   []string
+	values map[string]any
+}
+
+func (o *jsonObject) add(key string, value string) {
+	if o.values == nil {
+		o.values = make(map[string]any)
+	}
+	// 同一字段重复出现(如字典中没有定义的重复组)时取值合并为数组
+	switch existing := o.values[key].(type) {
+	case nil:
+		o.keys = append(o.keys, key)
+		o.values[key] = value
+	case string:
+		o.values[key] = []string{existing, value}
+	case []string:
+		o.values[key] = append(existing, value)
+	}
+}
+
+// 重复组以NumInGroup字段名为键，取值为各条目的对象数组
+func (o *jsonObject) addGroup(key string, entries []jsonObject) {
+	if o.values == nil {
+		o.values = make(map[string]any)
+	}
+	if existing, ok := o.values[key].([]jsonObject); ok {
+		o.values[key] = append(existing, entries...)
+		return
+	}
+	if _, exists := o.values[key]; !exists {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = entries
+}
+
+func (o jsonObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// FIX Trading Community的JSON编码：标准头、消息体、标准尾，字段以字典中的名称为键，取值均为字符串
+type fixJsonMessage struct {
+	Header  jsonObject
+	Body    jsonObject
+	Trailer jsonObject
+}
+
+// 一行输出：日志中的元数据和报文
+type fixJsonRecord struct {
+	LogTime   string
+	Direction string `json:",omitempty"`
+	LineNo    int
+	Message   fixJsonMessage
+}
+
+func newFixJsonRecord(line string, lineNo int, dict *fixdict.Dictionary) fixJsonRecord {
+	record := fixJsonRecord{Direction: logline.Direction(line), LineNo: lineNo}
+	record.LogTime, _ = logline.Time(line)
+	fields := logline.Fields(line)
+	for i := 0; i < len(fields); {
+		field := fields[i]
+		section := &record.Message.Body
+		if dict.IsHeader(field.Tag) {
+			section = &record.Message.Header
+		} else if dict.IsTrailer(field.Tag) {
+			section = &record.Message.Trailer
+		}
+		i = addJsonField(section, fields, i, dict)
+	}
+	return record
+}
+
+// 将fields[i]加入object，字典中定义了重复组的NumInGroup字段连同其后的条目一起加入，返回下一个字段的位置
+func addJsonField(object *jsonObject, fields []logline.Field, i int, dict *fixdict.Dictionary) int {
+	field := fields[i]
+	members, isGroup := dict.Group(field.Tag)
+	if !isGroup {
+		object.add(dict.FieldName(field.Tag), field.Value)
+		return i + 1
+	}
+
+	// 每个条目以分隔字段开始，遇到不属于该组的字段时结束
+	var entries []jsonObject
+	next := i + 1
+	for next < len(fields) {
+		tag := fields[next].Tag
+		if tag == members[0] {
+			entries = append(entries, jsonObject{})
+		} else if len(entries) == 0 || !containsTag(members, tag) {
+			break
+		}
+		next = addJsonField(&entries[len(entries)-1], fields, next, dict)
+	}
+	if len(entries) == 0 {
+		// 条目数为0或报文与字典不符时保留原值
+		object.add(dict.FieldName(field.Tag), field.Value)
+		return i + 1
+	}
+	object.addGroup(dict.FieldName(field.Tag), entries)
+	return next
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// 报文过滤条件，为空的条件不限
+type fixJsonFilter struct {
+	msgTypes map[string]bool
+	sessions []string // SenderCompID或TargetCompID的通配模式
+	window   timeWindow
+	loc      *time.Location
+	expr     *lineFilter
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (f *fixJsonFilter) match(line string, lineNo int) bool {
+	if len(f.msgTypes) > 0 {
+		msgType, _ := logline.Tag(line, "35")
+		if !f.msgTypes[msgType] {
+			return false
+		}
+	}
+	if len(f.sessions) > 0 {
+		sender, _ := logline.Tag(line, "49")
+		target, _ := logline.Tag(line, "56")
+		matched := false
+		for _, pattern := range f.sessions {
+			if ok, _ := path.Match(pattern, sender); ok {
+				matched = true
+			} else if ok, _ := path.Match(pattern, target); ok {
+				matched = true
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.window.from.specified || f.window.to.specified {
+		t, ok := lineTime(line, f.loc)
+		if !ok || !f.window.contains(t) {
+			return false
+		}
+	}
+	return f.expr.match(line, lineNo)
+}
+
+// 日志前缀时间
+func lineTime(line string, loc *time.Location) (time.Time, bool) {
+	value, ok := logline.Time(line)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(logline.TimeLayout, value, loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+func runFixJson(args []string) error {
+	fs := newFlagSet("fixjson", "[options] <logFilePath> <outputJsonlPath>",
+		"Convert the FIX messages of a log to JSONL in the FIX Trading Community JSON encoding (Header/Body/Trailer with named fields), keeping the log time, direction and line number. Repeating groups defined in the dictionary become arrays of objects; other repeated tags become arrays of values. Use - as the output to write to standard output.")
+	msgTypes := fs.String("msgtype", "", "comma separated MsgType(35) values to keep, e.g. D,8 (default all)")
+	sessions := fs.String("session", "", "comma separated CompID patterns, a message is kept when its SenderCompID(49) or TargetCompID(56) matches one, e.g. HRT*,exch_sim (default all)")
+	fromValue := fs.String("from", "", "keep messages from this log time, HH:MM:SS[.ffffff] on the day of the log or YYYY-MM-DD HH:MM:SS[.ffffff]")
+	toValue := fs.String("to", "", "keep messages before this log time, same format as -from")
+	logTimeZone := fs.String("logtz", "Local", "time zone of -from and -to, the same as the log line prefix time")
+	filterValue := fs.String("filter", "", lineFilterUsage+", in addition to the other filters")
+	dictPaths := addDictFlag(fs)
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <outputJsonlPath>")
+	}
+	logFilePath, outputPath := positional[0], positional[1]
+
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+	window, err := parseTimeWindow(*fromValue, *toValue, loc)
+	if err != nil {
+		return usageErrorf(fs, "%v", err)
+	}
+	if err := window.resolveDate(logFilePath, loc); err != nil {
+		return err
+	}
+	for _, pattern := range splitList(*sessions) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return usageErrorf(fs, "-session: invalid pattern %q", pattern)
+		}
+	}
+	dict, err := dictPaths.load()
+	if err != nil {
+		return err
+	}
+	expr, err := newLineFilter(dict, nil, *filterValue)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+	filter := fixJsonFilter{msgTypes: make(map[string]bool), sessions: splitList(*sessions), window: window, loc: loc, expr: expr}
+	for _, msgType := range splitList(*msgTypes) {
+		filter.msgTypes[msgType] = true
+	}
+
+	var out io.Writer = os.Stdout
+	if outputPath != "-" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("error creating file: %v", err)
+		}
+		defer file.Close()
+		out = file
+	}
+	w := bufio.NewWriter(out)
+
+	count := 0
+	err = scanLog(logFilePath, func(line string, lineNo int) error {
+		if !logline.IsFix(line) || !filter.match(line, lineNo) {
+			return nil
+		}
+		jsonBytes, err := json.Marshal(newFixJsonRecord(line, lineNo, dict))
+		if err != nil {
+			return fmt.Errorf("error marshalling to JSON: %v", err)
+		}
+		if _, err := w.Write(append(jsonBytes, '\n')); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+		count += 1
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	if outputPath != "-" {
+		fmt.Println("Message Count: ", count)
+		fmt.Println("Messages exported successfully to", outputPath)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"v8/internal/fixdict"
+)
+
+// 内置字典之上叠加的重复组定义
+const fixJsonGroupsXML = `<fix type="FIX" major="4" minor="2">
+  <messages>
+    <message name="NewOrderSingle" msgtype="D" msgcat="app">
+      <group name="NoPartyIDs" required="N">
+        <field name="PartyID" required="N"/>
+        <field name="PartyRole" required="N"/>
+        <group name="NoPartySubIDs" required="N">
+          <field name="PartySubID" required="N"/>
+        </group>
+      </group>
+    </message>
+  </messages>
+  <fields>
+    <field number="448" name="PartyID" type="STRING"/>
+    <field number="452" name="PartyRole" type="INT"/>
+    <field number="453" name="NoPartyIDs" type="NUMINGROUP"/>
+    <field number="523" name="PartySubID" type="STRING"/>
+    <field number="802" name="NoPartySubIDs" type="NUMINGROUP"/>
+  </fields>
+</fix>`
+
+func TestFixJsonRecord(t *testing.T) {
+	dict := fixdict.Default()
+	if err := dict.Load(strings.NewReader(fixJsonGroupsXML)); err != nil {
+		t.Fatal(err)
+	}
+	prefix := "D0411 04/11/2024 09:00:00.600000 1234 session.cpp:88] recv "
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{
+			"8=FIX.4.2|9=10|35=D|49=HRT01|11=C1|55=7203|10=000|",
+			`{"Header":{"BeginString":"FIX.4.2","BodyLength":"10","MsgType":"D","SenderCompID":"HRT01"},"Body":{"ClOrdID":"C1","Symbol":"7203"},"Trailer":{"CheckSum":"000"}}`,
+		},
+		{
+			// 两个条目，第二个条目带嵌套的重复组，组后的字段回到消息体
+			"8=FIX.4.2|35=D|11=C1|453=2|448=BRK1|452=1|448=DESK2|452=12|802=2|523=S1|523=S2|55=7203|10=000|",
+			`{"Header":{"BeginString":"FIX.4.2","MsgType":"D"},"Body":{"ClOrdID":"C1","NoPartyIDs":[{"PartyID":"BRK1","PartyRole":"1"},{"PartyID":"DESK2","PartyRole":"12","NoPartySubIDs":[{"PartySubID":"S1"},{"PartySubID":"S2"}]}],"Symbol":"7203"},"Trailer":{"CheckSum":"000"}}`,
+		},
+		{
+			// 条目数为0，或其后不是分隔字段时保留原值
+			"8=FIX.4.2|35=D|453=0|55=7203|10=000|",
+			`{"Header":{"BeginString":"FIX.4.2","MsgType":"D"},"Body":{"NoPartyIDs":"0","Symbol":"7203"},"Trailer":{"CheckSum":"000"}}`,
+		},
+		{
+			"8=FIX.4.2|35=D|453=1|452=1|448=BRK1|10=000|",
+			`{"Header":{"BeginString":"FIX.4.2","MsgType":"D"},"Body":{"NoPartyIDs":"1","PartyRole":"1","PartyID":"BRK1"},"Trailer":{"CheckSum":"000"}}`,
+		},
+		{
+			// 字典中没有定义的重复组，重复的字段合并为数组
+			"8=FIX.4.2|35=D|78=2|79=A1|80=100|79=A2|80=200|10=000|",
+			`{"Header":{"BeginString":"FIX.4.2","MsgType":"D"},"Body":{"78":"2","79":["A1","A2"],"80":["100","200"]},"Trailer":{"CheckSum":"000"}}`,
+		},
+	}
+	for _, tt := range tests {
+		record := newFixJsonRecord(prefix+tt.message, 3, dict)
+		if record.LogTime != "04/11/2024 09:00:00.600000" || record.Direction != "recv" || record.LineNo != 3 {
+			t.Errorf("%s: metadata %s %s %d", tt.message, record.LogTime, record.Direction, record.LineNo)
+		}
+		got, err := json.Marshal(record.Message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+// Package fixlog 从OMS日志中还原JNET更正订单的生命周期并计算各阶段耗时，
+// 可选关联撮合引擎日志和抓包，并估计撮合主机的时钟偏移。
+//
+// 日志只需顺序读取一遍：订单在收到客户端35=D时开始跟踪，向客户端返回JNET确认时完成。
+// 分析过程中的事件按日志顺序推送给WithConsumer注册的Consumer。
+//
+//	analyzer := fixlog.NewAnalyzer(fixlog.WithLocation(loc))
+//	if err := analyzer.Analyze(file); err != nil {
+//		return err
+//	}
+//	if err := analyzer.Flush(); err != nil {
+//		return err
+//	}
+//	for _, order := range analyzer.CompletedOrders() {
+//		cost, ok := fixlog.TotalCostTime.Cost(order)
+//		...
+//	}
+package fixlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"v8/internal/logline"
+)
+
+type options struct {
+	location  *time.Location
+	rawLines  bool
+	consumers []Consumer
+	skew      *ClockSkew
+	filter    func(*Order) bool
+
+	orderTimeout   time.Duration
+	evictCompleted bool
+}
+
+// Option Analyzer的可选配置
+type Option func(*options)
+
+// WithLocation 日志前缀时间所在的时区，默认time.Local；FIX报文里的52/60为UTC，按该时区与日志时间对齐
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.location = loc
+	}
+}
+
+// WithRawLines 在Milestone.Line中保留原始日志行，便于回溯报文，代价是内存占用
+func WithRawLines() Option {
+	return func(o *options) {
+		o.rawLines = true
+	}
+}
+
+// WithConsumer 注册事件的接收方，可注册多个，按注册顺序调用
+func WithConsumer(c Consumer) Option {
+	return func(o *options) {
+		o.consumers = append(o.consumers, c)
+	}
+}
+
+// WithOrderFilter 只保留filter返回true的订单。在订单完成或作为孤儿订单上报前判断，此时已补充外部时间点；
+// 被排除的订单不推送OnOrderComplete/OnOrphan，也不出现在Orders/CompletedOrders中。OnMilestone不受影响
+func WithOrderFilter(filter func(*Order) bool) Option {
+	return func(o *options) {
+		o.filter = filter
+	}
+}
+
+// Stats 分析过程中的计数
+type Stats struct {
+	Lines             int // OMS日志行数
+	FixMessages       int // 含FIX报文的行数
+	ConfirmedMessages int // 35=8、20=2、39=2的报文数，含重复及撮合引擎发来的同类报文，同原v8的JNET Correction Order Count
+	ParseErrors       int // 可识别为时间点但缺少前缀时间或关键标签的行数
+	SessionEvents     int // 会话层管理报文数
+	Orphans           int // 收到JNET更正但未返回客户端的订单数
+	Evicted           int // 超时未完成而不再跟踪的订单数
+	Filtered          int // 被WithOrderFilter排除的已完成及孤儿订单数
+
+	MatchEngineLinked int // 关联到撮合引擎日志的订单数
+	WireMessages      int // 抓包中还原出的FIX报文数
+	WireLinked        int // 关联到抓包报文的订单数
+}
+
+// Analyzer 跟踪订单生命周期，非并发安全
+type Analyzer struct {
+	opts   options
+	orders map[string]*Order
+	stats  Stats
+
+	// LoadMatchEngine/LoadCapture预先读入的外部时间点，订单完成时补充
+	matchEngine *matchEngineIndex
+	wire        map[string]map[MilestoneKind]Milestone
+
+	// 按开始跟踪的先后排列，用于超时淘汰
+	pending   []*Order
+	now       time.Time // 已读到的最新日志时间
+	lastSweep time.Time
+
+	// WithEvictCompleted淘汰的已完成订单，按淘汰的先后排列，用于忽略之后重复的JNET确认
+	evicted      map[string]time.Time
+	evictedOrder []evictedID
+}
+
+func NewAnalyzer(opts ...Option) *Analyzer {
+	a := &Analyzer{
+		opts:    options{location: time.Local},
+		orders:  make(map[string]*Order),
+		evicted: make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(&a.opts)
+	}
+	return a
+}
+
+// Analyze 顺序读取OMS日志；日志按天或按大小切分时可对各文件依次调用，行号在每个文件内计数。
+// 全部输入读完后调用Flush。
+func (a *Analyzer) Analyze(r io.Reader) error {
+	scanner := logline.NewScanner(r)
+	for scanner.Scan() {
+		if err := a.processLine(scanner.Text(), scanner.LineNo()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading log: %v", err)
+	}
+	return nil
+}
+
+// Flush 输入结束：为未完成的订单补充外部时间点，并将收到JNET更正但未返回客户端的订单作为孤儿订单上报
+func (a *Analyzer) Flush() error {
+	for _, order := range a.Orders() {
+		if order.Complete() || order.resolved {
+			continue
+		}
+		if err := a.abandon(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 不再等待未完成的订单：补充外部时间点，收到过JNET更正的作为孤儿订单上报
+func (a *Analyzer) abandon(order *Order) error {
+	if err := a.resolve(order); err != nil {
+		return err
+	}
+	if _, corrected := order.Milestones[RecvMatchCorrect]; !corrected {
+		return nil
+	}
+	if !a.include(order) {
+		return nil
+	}
+	a.stats.Orphans += 1
+	for _, c := range a.opts.consumers {
+		if err := c.OnOrphan(order); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 按WithOrderFilter判断是否保留订单，排除的订单做标记并计数
+func (a *Analyzer) include(order *Order) bool {
+	if a.opts.filter == nil || a.opts.filter(order) {
+		return true
+	}
+	order.excluded = true
+	a.stats.Filtered += 1
+	return false
+}
+
+// Orders 返回跟踪到的全部订单(不含被WithOrderFilter排除的)，按收到客户端订单的时间排序
+func (a *Analyzer) Orders() []*Order {
+	orders := make([]*Order, 0, len(a.orders))
+	for _, order := range a.orders {
+		if !order.excluded {
+			orders = append(orders, order)
+		}
+	}
+	SortByRecvClientTime(orders)
+	return orders
+}
+
+// CompletedOrders 返回已向客户端返回JNET确认的订单，按收到客户端订单的时间排序
+func (a *Analyzer) CompletedOrders() []*Order {
+	var orders []*Order
+	for _, order := range a.orders {
+		if order.Complete() && !order.excluded {
+			orders = append(orders, order)
+		}
+	}
+	SortByRecvClientTime(orders)
+	return orders
+}
+
+// Order 按客户端ClOrdID(11)查找订单
+func (a *Analyzer) Order(clOrdID string) (*Order, bool) {
+	order, ok := a.orders[clOrdID]
+	return order, ok
+}
+
+func (a *Analyzer) Stats() Stats {
+	return a.stats
+}
+
+func isJnetConfirmed(line string) bool {
+	return strings.Contains(line, "|35=8|") && strings.Contains(line, "|20=2|") && strings.Contains(line, "|39=2|") && strings.Contains(line, "8=FIX")
+}
+
+// 判断报文属于哪个时间点，并返回用于关联订单的ClOrdID(缺失时为空串)；日志行和抓包还原的报文共用
+func classify(line string) (MilestoneKind, string, bool) {
+	var kind MilestoneKind
+	var keyTag string
+	switch {
+	case strings.Contains(line, "|35=D|") && strings.Contains(line, "|49=router_branch|") && strings.Contains(line, "|56=exch_sim|"):
+		kind, keyTag = SendMatch, "198"
+	case strings.Contains(line, "|35=D|") && strings.Contains(line, "8=FIX"):
+		kind, keyTag = RecvClient, "11"
+	case strings.Contains(line, "|150=2|") && strings.Contains(line, "|49=exch_sim|") && strings.Contains(line, "|56=router_branch|"):
+		kind, keyTag = RecvMatchFill, "198"
+	case strings.Contains(line, "|150=G|") && strings.Contains(line, "|49=exch_sim|") && strings.Contains(line, "|56=router_branch|"):
+		kind, keyTag = RecvMatchCorrect, "198"
+	case isJnetConfirmed(line):
+		kind, keyTag = FinalReturn, "11"
+	default:
+		return 0, "", false
+	}
+
+	key, _ := logline.Tag(line, keyTag)
+	return kind, key, true
+}
+
+func (a *Analyzer) order(key string, logTime time.Time) *Order {
+	order, exists := a.orders[key]
+	if !exists {
+		order = &Order{ClOrdID: key, firstSeen: logTime}
+		a.orders[key] = order
+		if a.opts.orderTimeout > 0 {
+			a.pending = append(a.pending, order)
+		}
+	}
+	return order
+}
+
+// 解析日志前缀时间
+func (a *Analyzer) logTime(line string) (time.Time, bool) {
+	value, ok := logline.Time(line)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(logline.TimeLayout, value, a.opts.location)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// 取UTC的FIX时间戳标签并换算到日志时区，缺失或格式错误时ok为false
+func (a *Analyzer) fixTime(line string, tag string) (time.Time, bool) {
+	value, ok := logline.Tag(line, tag)
+	if !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(logline.FixTimestampLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.In(a.opts.location), true
+}
+
+func (a *Analyzer) processLine(line string, lineNo int) error {
+	a.stats.Lines += 1
+	if !logline.IsFix(line) {
+		return nil
+	}
+	a.stats.FixMessages += 1
+	if isJnetConfirmed(line) {
+		a.stats.ConfirmedMessages += 1
+	}
+
+	if event, ok := a.sessionEvent(line, lineNo); ok {
+		a.stats.SessionEvents += 1
+		for _, c := range a.opts.consumers {
+			if err := c.OnSessionEvent(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	kind, key, ok := classify(line)
+	if !ok {
+		return nil
+	}
+	logTime, ok := a.logTime(line)
+	if key == "" || !ok {
+		a.stats.ParseErrors += 1
+		return nil
+	}
+	if err := a.advance(logTime); err != nil {
+		return err
+	}
+	// 已完成并被淘汰的订单重复返回的JNET确认，不再作为新订单完成
+	if _, evicted := a.evicted[key]; evicted && kind == FinalReturn {
+		return nil
+	}
+
+	order := a.order(key, logTime)
+	m := Milestone{Kind: kind, Time: logTime, LineNo: lineNo}
+	if a.opts.rawLines {
+		m.Line = line
+	}
+	// 只取每个时间点的第一条报文，重复的JNET确认除外
+	if _, exists := order.Milestones[kind]; exists {
+		if kind == FinalReturn {
+			replaceFinalReturn(order, m, line)
+		}
+		return nil
+	}
+
+	// 先填充订单字段，再推送时间点事件
+	var fixTimes []Milestone
+	switch kind {
+	case RecvClient:
+		order.Account, _ = logline.Tag(line, "1")
+		order.Symbol, _ = logline.Tag(line, "55")
+		order.Side, _ = logline.Tag(line, "54")
+		order.OrderQty, _ = logline.Tag(line, "38")
+		order.ClientCompID, _ = logline.Tag(line, "49")
+		fixTimes = a.fixTimes(line, fixTimeTag{ClientSending, "52"})
+	case SendMatch:
+		order.MatchClOrdID, _ = logline.Tag(line, "11")
+	case RecvMatchFill:
+		order.Fill = parseExecution(line)
+		fixTimes = a.fixTimes(line, fixTimeTag{MatchTransact, "60"}, fixTimeTag{MatchSending, "52"})
+	case RecvMatchCorrect:
+		order.Correction = parseExecution(line)
+	case FinalReturn:
+		order.Final = parseExecution(line)
+		// 以返回客户端的报文为准
+		if account, ok := logline.Tag(line, "1"); ok {
+			order.Account = account
+		}
+	}
+
+	for _, m := range append([]Milestone{m}, fixTimes...) {
+		if err := a.addMilestone(order, m); err != nil {
+			return err
+		}
+	}
+	if kind != FinalReturn {
+		return nil
+	}
+
+	if err := a.resolve(order); err != nil {
+		return err
+	}
+	if a.include(order) {
+		for _, c := range a.opts.consumers {
+			if err := c.OnOrderComplete(order); err != nil {
+				return err
+			}
+		}
+	}
+	if a.opts.evictCompleted {
+		a.evict(order, logTime)
+	}
+	return nil
+}
+
+// 重复返回客户端的JNET确认以最后一条为准(同原v8)：更新时间点、Final和账户，不再推送事件。
+// 推送OnOrderComplete时看到的是第一条，分析结束后再读取订单(如CompletedOrders)看到的是最后一条
+func replaceFinalReturn(order *Order, m Milestone, line string) {
+	order.Milestones[FinalReturn] = m
+	order.Final = parseExecution(line)
+	if account, ok := logline.Tag(line, "1"); ok {
+		order.Account = account
+	}
+}
+
+type fixTimeTag struct {
+	kind MilestoneKind
+	tag  string
+}
+
+func (a *Analyzer) fixTimes(line string, tags ...fixTimeTag) []Milestone {
+	var milestones []Milestone
+	for _, t := range tags {
+		if fixTime, ok := a.fixTime(line, t.tag); ok {
+			milestones = append(milestones, Milestone{Kind: t.kind, Time: fixTime})
+		}
+	}
+	return milestones
+}
+
+// 记录时间点(撮合主机时钟下的时间点按WithClockSkew校正)并推送事件，已存在时忽略
+func (a *Analyzer) addMilestone(order *Order, m Milestone) error {
+	if a.opts.skew != nil && isMatchClock(m.Kind) {
+		m.Time = a.opts.skew.Correct(m.Time)
+	}
+	if !order.setMilestone(m) {
+		return nil
+	}
+	for _, c := range a.opts.consumers {
+		if err := c.OnMilestone(order, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 补充撮合引擎日志和抓包中的时间点，每笔订单只做一次
+func (a *Analyzer) resolve(order *Order) error {
+	if order.resolved {
+		return nil
+	}
+	order.resolved = true
+
+	var external []Milestone
+	if a.matchEngine != nil {
+		if milestones := a.matchEngine.lookup(order); len(milestones) > 0 {
+			a.stats.MatchEngineLinked += 1
+			external = append(external, milestones...)
+		}
+	}
+	if wire, ok := a.wire[order.ClOrdID]; ok {
+		a.stats.WireLinked += 1
+		for _, kind := range LogMilestones {
+			wireKind, _ := kind.Wire()
+			if m, ok := wire[wireKind]; ok {
+				external = append(external, m)
+			}
+		}
+	}
+	for _, m := range external {
+		if err := a.addMilestone(order, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseExecution(line string) *Execution {
+	e := &Execution{}
+	fields := []struct {
+		tag   string
+		value *string
+	}{
+		{"17", &e.ExecID}, {"19", &e.ExecRefID}, {"37", &e.OrderID}, {"150", &e.ExecType},
+		{"39", &e.OrdStatus}, {"20", &e.ExecTransType}, {"32", &e.LastQty}, {"31", &e.LastPx},
+		{"49", &e.SenderCompID}, {"56", &e.TargetCompID},
+	}
+	for _, f := range fields {
+		*f.value, _ = logline.Tag(line, f.tag)
+	}
+	return e
+}
@@ -0,0 +1,309 @@
+package fixlog
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const linePrefix = "D0411 04/11/2024 "
+
+// 订单C0的完整生命周期，最后重复返回一次JNET确认
+const evictLog = `D0411 04/11/2024 09:00:00.600000 1234 session.cpp:88] recv 8=FIX.4.2|9=95|35=D|49=HRT01|56=OMS|34=1|52=20240411-00:00:00.599905|11=C0|1=ACC3|55=7203|54=1|38=100|44=1000|10=000|
+D0411 04/11/2024 09:00:00.605100 1234 session.cpp:88] send 8=FIX.4.2|9=74|35=D|49=router_branch|56=exch_sim|11=R0|198=C0|1=ACC3|55=7203|54=1|38=100|10=000|
+D0411 04/11/2024 09:00:00.605598 1234 session.cpp:88] recv 8=FIX.4.2|9=146|35=8|49=exch_sim|56=router_branch|52=20240411-00:00:00.605590|60=20240411-00:00:00.605196|11=R0|198=C0|17=E0|37=O0|150=2|39=2|20=0|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.621642 1234 session.cpp:88] recv 8=FIX.4.2|9=120|35=8|49=exch_sim|56=router_branch|52=20240411-00:00:00.621638|11=R0|198=C0|17=E0c|19=E0|37=O0|150=G|39=2|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.625075 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|52=20240411-00:00:00.625075|11=C0|1=ACC3|55=7203|17=X0c|19=X0|37=O0|150=G|39=2|20=2|10=000|
+D0411 04/11/2024 09:00:01.900000 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|52=20240411-00:00:01.900000|11=C0|1=ACC3|55=7203|17=X0d|19=X0|37=O0|150=G|39=2|20=2|10=000|
+`
+
+// 按顺序记录收到的事件
+type recorder struct {
+	events []string
+}
+
+func (r *recorder) OnMilestone(order *Order, m Milestone) error {
+	r.events = append(r.events, fmt.Sprintf("milestone %s %s", order.ClOrdID, m.Kind))
+	return nil
+}
+
+func (r *recorder) OnOrderComplete(order *Order) error {
+	r.events = append(r.events, "complete "+order.ClOrdID)
+	return nil
+}
+
+func (r *recorder) OnOrphan(order *Order) error {
+	r.events = append(r.events, "orphan "+order.ClOrdID)
+	return nil
+}
+
+func (r *recorder) OnSessionEvent(event SessionEvent) error {
+	r.events = append(r.events, "session "+event.Name)
+	return nil
+}
+
+// 逐行Feed日志后Flush
+func feed(t *testing.T, a *Analyzer, log string) {
+	t.Helper()
+	for i, line := range strings.Split(strings.TrimSuffix(log, "\n"), "\n") {
+		if err := a.Feed(strings.ReplaceAll(line, "|", "\x01"), i+1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 返回各类事件的次数
+func (r *recorder) count(prefix string) int {
+	n := 0
+	for _, event := range r.events {
+		if strings.HasPrefix(event, prefix) {
+			n += 1
+		}
+	}
+	return n
+}
+
+func TestEvictCompletedIgnoresRepeatedConfirmation(t *testing.T) {
+	for _, timeout := range []time.Duration{0, time.Minute} {
+		r := &recorder{}
+		a := NewAnalyzer(WithLocation(time.UTC), WithConsumer(r), WithEvictCompleted(), WithOrderTimeout(timeout))
+		feed(t, a, evictLog)
+		if got := r.count("complete "); got != 1 {
+			t.Errorf("timeout %v: OnOrderComplete called %d times, want 1: %q", timeout, got, r.events)
+		}
+		if got := len(a.Orders()); got != 0 {
+			t.Errorf("timeout %v: %d orders left after eviction", timeout, got)
+		}
+		if stats := a.Stats(); stats.ConfirmedMessages != 2 || stats.Orphans != 0 {
+			t.Errorf("timeout %v: stats %+v", timeout, stats)
+		}
+	}
+}
+
+func TestEvictCompletedForgetsAfterTimeout(t *testing.T) {
+	// 重复的确认在超时之后才到，此时已不再记住C0，按新订单处理
+	log := evictLog + linePrefix + "09:00:05.000000 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|11=C0|17=X0e|19=X0|37=O0|150=G|39=2|20=2|10=000|\n"
+	r := &recorder{}
+	a := NewAnalyzer(WithLocation(time.UTC), WithConsumer(r), WithEvictCompleted(), WithOrderTimeout(2*time.Second))
+	feed(t, a, log)
+	want := []string{"complete C0", "complete C0"}
+	var got []string
+	for _, event := range r.events {
+		if strings.HasPrefix(event, "complete ") {
+			got = append(got, event)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("completions = %q, want %q", got, want)
+	}
+	if len(a.evicted) != 1 || len(a.evictedOrder) != 1 {
+		t.Errorf("evicted set has %d entries, queue %d, want 1", len(a.evicted), len(a.evictedOrder))
+	}
+}
+
+// C0完整并重复返回一次JNET确认(账户改为ACC9)；C1收到JNET更正但未返回客户端；C2只收到客户端订单
+const lifecycleLog = `D0411 04/11/2024 09:00:00.500000 1234 session.cpp:88] recv 8=FIX.4.2|9=60|35=A|49=HRT01|56=OMS|34=1|98=0|108=30|10=000|
+D0411 04/11/2024 09:00:00.600000 1234 session.cpp:88] recv 8=FIX.4.2|9=95|35=D|49=HRT01|56=OMS|34=2|52=20240411-00:00:00.599905|11=C0|1=ACC3|55=7203|54=1|38=100|44=1000|10=000|
+I0411 04/11/2024 09:00:00.600003 1234 risk.cpp:42] risk check ok for C0
+D0411 04/11/2024 09:00:00.605100 1234 session.cpp:88] send 8=FIX.4.2|9=74|35=D|49=router_branch|56=exch_sim|11=R0|198=C0|1=ACC3|55=7203|54=1|38=100|10=000|
+D0411 04/11/2024 09:00:00.605598 1234 session.cpp:88] recv 8=FIX.4.2|9=146|35=8|49=exch_sim|56=router_branch|52=20240411-00:00:00.605590|60=20240411-00:00:00.605196|11=R0|198=C0|17=E0|37=O0|150=2|39=2|20=0|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.621642 1234 session.cpp:88] recv 8=FIX.4.2|9=120|35=8|49=exch_sim|56=router_branch|52=20240411-00:00:00.621638|11=R0|198=C0|17=E0c|19=E0|37=O0|150=G|39=2|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.625075 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|52=20240411-00:00:00.625075|11=C0|1=ACC3|55=7203|17=X0c|19=X0|37=O0|150=G|39=2|20=2|10=000|
+D0411 04/11/2024 09:00:01.000000 1234 session.cpp:88] recv 8=FIX.4.2|9=80|35=D|49=HRT01|56=OMS|34=3|11=C1|1=ACC1|55=6758|54=2|38=200|10=000|
+D0411 04/11/2024 09:00:01.004000 1234 session.cpp:88] send 8=FIX.4.2|9=74|35=D|49=router_branch|56=exch_sim|11=R1|198=C1|1=ACC1|55=6758|54=2|38=200|10=000|
+D0411 04/11/2024 09:00:01.005000 1234 session.cpp:88] recv 8=FIX.4.2|9=100|35=8|49=exch_sim|56=router_branch|11=R1|198=C1|17=E1|37=O1|150=2|39=2|20=0|1=ACC1|55=6758|10=000|
+D0411 04/11/2024 09:00:01.020000 1234 session.cpp:88] recv 8=FIX.4.2|9=100|35=8|49=exch_sim|56=router_branch|11=R1|198=C1|17=E1c|19=E1|37=O1|150=G|39=2|1=ACC1|55=6758|10=000|
+D0411 04/11/2024 09:00:01.500000 1234 session.cpp:88] recv 8=FIX.4.2|9=80|35=D|49=HRT01|56=OMS|34=4|11=C2|1=ACC1|55=9984|54=1|38=300|10=000|
+D0411 04/11/2024 09:00:01.900000 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|52=20240411-00:00:01.900000|11=C0|1=ACC9|55=7203|17=X0d|19=X0|37=O0|150=G|39=2|20=2|10=000|
+`
+
+func TestAnalyze(t *testing.T) {
+	r := &recorder{}
+	a := NewAnalyzer(WithLocation(time.UTC), WithConsumer(r))
+	if err := a.Analyze(strings.NewReader(strings.ReplaceAll(lifecycleLog, "|", "\x01"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 时间点按日志顺序推送，重复的JNET确认不再推送，孤儿订单在Flush时上报
+	wantEvents := []string{
+		"session Logon",
+		"milestone C0 RecvClientTime", "milestone C0 ClientSendingTime",
+		"milestone C0 SendMatchTime",
+		"milestone C0 RecvMatchFillTime", "milestone C0 MatchTransactTime", "milestone C0 MatchSendingTime",
+		"milestone C0 RecvMatchCorrectTime",
+		"milestone C0 FinalReturnTime",
+		"complete C0",
+		"milestone C1 RecvClientTime", "milestone C1 SendMatchTime", "milestone C1 RecvMatchFillTime", "milestone C1 RecvMatchCorrectTime",
+		"milestone C2 RecvClientTime",
+		"orphan C1",
+	}
+	if !reflect.DeepEqual(r.events, wantEvents) {
+		t.Errorf("events:\n got %q\nwant %q", r.events, wantEvents)
+	}
+
+	wantStats := Stats{Lines: 13, FixMessages: 12, ConfirmedMessages: 2, SessionEvents: 1, Orphans: 1}
+	if stats := a.Stats(); stats != wantStats {
+		t.Errorf("stats = %+v, want %+v", stats, wantStats)
+	}
+
+	var ids []string
+	for _, order := range a.Orders() {
+		ids = append(ids, order.ClOrdID)
+	}
+	if want := []string{"C0", "C1", "C2"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("orders = %q, want %q", ids, want)
+	}
+	completed := a.CompletedOrders()
+	if len(completed) != 1 || completed[0].ClOrdID != "C0" {
+		t.Fatalf("completed orders = %v", completed)
+	}
+
+	order := completed[0]
+	if order.Symbol != "7203" || order.Side != "1" || order.OrderQty != "100" || order.ClientCompID != "HRT01" || order.MatchClOrdID != "R0" {
+		t.Errorf("order fields = %+v", order)
+	}
+	if order.Fill == nil || order.Fill.ExecID != "E0" || order.Correction == nil || order.Correction.ExecRefID != "E0" {
+		t.Errorf("fill = %+v, correction = %+v", order.Fill, order.Correction)
+	}
+	// 重复的JNET确认以最后一条为准
+	if m, _ := order.Milestone(FinalReturn); m.LineNo != 13 || order.Account != "ACC9" || order.Final.ExecID != "X0d" {
+		t.Errorf("final return at line %d, account %s, final %+v, want the last confirmation", m.LineNo, order.Account, order.Final)
+	}
+
+	for _, tt := range []struct {
+		kind   MilestoneKind
+		time   string
+		lineNo int
+	}{
+		{RecvClient, "09:00:00.600000", 2},
+		{ClientSending, "00:00:00.599905", 0},
+		{SendMatch, "09:00:00.605100", 4},
+		{RecvMatchFill, "09:00:00.605598", 5},
+		{MatchTransact, "00:00:00.605196", 0},
+		{MatchSending, "00:00:00.605590", 0},
+		{RecvMatchCorrect, "09:00:00.621642", 6},
+	} {
+		m, ok := order.Milestone(tt.kind)
+		if !ok || m.Time.Format("15:04:05.000000") != tt.time || m.LineNo != tt.lineNo {
+			t.Errorf("%s = %v line %d, %v, want %s line %d", tt.kind, m.Time, m.LineNo, ok, tt.time, tt.lineNo)
+		}
+	}
+
+	for _, tt := range []struct {
+		stage Stage
+		cost  time.Duration
+	}{
+		{OmsCostTime1, 5100 * time.Microsecond},
+		{MatchCostTime, 498 * time.Microsecond},
+		{JnetCostTime, 16044 * time.Microsecond},
+		{OmsCostTime2, 1278358 * time.Microsecond},
+		{TotalCostTime, 1300 * time.Millisecond},
+	} {
+		if cost, ok := tt.stage.Cost(order); !ok || cost != tt.cost {
+			t.Errorf("%s = %v, %v, want %v", tt.stage.Name, cost, ok, tt.cost)
+		}
+	}
+	if c1, _ := a.Order("C1"); c1 == nil || c1.Complete() {
+		t.Errorf("C1 = %+v, want an incomplete order", c1)
+	} else if _, ok := OmsCostTime2.Cost(c1); ok {
+		t.Errorf("OmsCostTime2 of an order without FinalReturn")
+	}
+}
+
+// Feed逐行输入与Analyze读取整个日志得到相同的事件
+func TestFeed(t *testing.T) {
+	want := &recorder{}
+	a := NewAnalyzer(WithLocation(time.UTC), WithConsumer(want))
+	if err := a.Analyze(strings.NewReader(strings.ReplaceAll(lifecycleLog, "|", "\x01"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &recorder{}
+	feed(t, NewAnalyzer(WithLocation(time.UTC), WithConsumer(got)), lifecycleLog)
+	if !reflect.DeepEqual(got.events, want.events) {
+		t.Errorf("Feed events:\n got %q\nwant %q", got.events, want.events)
+	}
+}
+
+func TestOrderFilterAndEviction(t *testing.T) {
+	account := func(account string) Option {
+		return WithOrderFilter(func(o *Order) bool { return o.Account == account })
+	}
+	tests := []struct {
+		name      string
+		opts      []Option
+		orders    []string
+		completed []string
+		events    []string // complete和orphan事件
+		filtered  int
+	}{
+		{
+			name:      "no filter",
+			orders:    []string{"C0", "C1", "C2"},
+			completed: []string{"C0"},
+			events:    []string{"complete C0", "orphan C1"},
+		},
+		{
+			// 被排除的订单不推送事件；C2未完成也未成为孤儿订单，不经过过滤
+			name:     "filter ACC1",
+			opts:     []Option{account("ACC1")},
+			orders:   []string{"C1", "C2"},
+			events:   []string{"orphan C1"},
+			filtered: 1,
+		},
+		{
+			name:      "filter ACC3",
+			opts:      []Option{account("ACC3")},
+			orders:    []string{"C0", "C2"},
+			completed: []string{"C0"},
+			events:    []string{"complete C0"},
+			filtered:  1,
+		},
+		{
+			name:   "evict completed",
+			opts:   []Option{WithEvictCompleted()},
+			orders: []string{"C1", "C2"},
+			events: []string{"complete C0", "orphan C1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &recorder{}
+			a := NewAnalyzer(append([]Option{WithLocation(time.UTC), WithConsumer(r)}, tt.opts...)...)
+			feed(t, a, lifecycleLog)
+
+			var orders, completed, events []string
+			for _, order := range a.Orders() {
+				orders = append(orders, order.ClOrdID)
+			}
+			for _, order := range a.CompletedOrders() {
+				completed = append(completed, order.ClOrdID)
+			}
+			for _, event := range r.events {
+				if strings.HasPrefix(event, "complete ") || strings.HasPrefix(event, "orphan ") {
+					events = append(events, event)
+				}
+			}
+			if !reflect.DeepEqual(orders, tt.orders) || !reflect.DeepEqual(completed, tt.completed) {
+				t.Errorf("orders = %q, completed = %q, want %q, %q", orders, completed, tt.orders, tt.completed)
+			}
+			if !reflect.DeepEqual(events, tt.events) {
+				t.Errorf("events = %q, want %q", events, tt.events)
+			}
+			if stats := a.Stats(); stats.Filtered != tt.filtered {
+				t.Errorf("filtered = %d, want %d", stats.Filtered, tt.filtered)
+			}
+		})
+	}
+}
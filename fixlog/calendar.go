@@ -0,0 +1,147 @@
+package fixlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// TradingCalendar 交易时段配置，JSON格式(东证现货)：
+//
+//	{"sessions": [
+//	  {"name": "morning", "start": "09:00", "end": "11:30", "warmup": "1m"},
+//	  {"name": "afternoon", "start": "12:30", "end": "15:25", "warmup": "1m"},
+//	  {"name": "closing-auction", "start": "15:25", "end": "15:30"}
+//	]}
+//
+// 时刻按日志时区的时钟读数比较
+type TradingCalendar struct {
+	Sessions []TradingSession `json:"sessions"`
+}
+
+// TradingSession 一个交易时段[start, end)
+type TradingSession struct {
+	Name   string    `json:"name"`
+	Start  ClockTime `json:"start"`
+	End    ClockTime `json:"end"`
+	Warmup Duration  `json:"warmup,omitempty"` // 开盘后的预热期，SLA规则可用excludeWarmup排除其中的订单
+}
+
+// ClockTime 当日时刻，JSON中为"09:00"、"09:00:00"或"09:00:00.5"
+type ClockTime time.Duration
+
+var clockTimeLayouts = []string{"15:04:05", "15:04"}
+
+func (c ClockTime) String() string {
+	return time.Time{}.Add(time.Duration(c)).Format("15:04:05.999999999")
+}
+
+func (c ClockTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *ClockTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("time of day must be a string like \"09:00\": %v", err)
+	}
+	for _, layout := range clockTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			*c = clockTimeOf(t)
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid time of day %q, expected HH:MM[:SS[.ffffff]]", value)
+}
+
+// 按t所在时区的时钟读数取当日时刻
+func clockTimeOf(t time.Time) ClockTime {
+	return ClockTime(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond()))
+}
+
+// Contains t是否在时段内
+func (s TradingSession) Contains(t time.Time) bool {
+	clock := clockTimeOf(t)
+	return clock >= s.Start && clock < s.End
+}
+
+// InWarmup t是否在时段开始后的预热期内
+func (s TradingSession) InWarmup(t time.Time) bool {
+	clock := clockTimeOf(t)
+	return clock >= s.Start && clock < s.Start+ClockTime(s.Warmup)
+}
+
+// LoadTradingCalendar 读取并校验交易时段配置，时段按开始时刻排序
+func LoadTradingCalendar(r io.Reader) (*TradingCalendar, error) {
+	var calendar TradingCalendar
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&calendar); err != nil {
+		return nil, fmt.Errorf("error parsing trading calendar: %v", err)
+	}
+	if len(calendar.Sessions) == 0 {
+		return nil, fmt.Errorf("error parsing trading calendar: no sessions")
+	}
+	names := make(map[string]bool)
+	for i, s := range calendar.Sessions {
+		switch {
+		case s.Name == "":
+			return nil, fmt.Errorf("session %d: name is required", i+1)
+		case names[s.Name]:
+			return nil, fmt.Errorf("session %d: duplicate name %q", i+1, s.Name)
+		case s.End <= s.Start:
+			return nil, fmt.Errorf("session %s: end must be after start", s.Name)
+		case s.Warmup < 0 || ClockTime(s.Warmup) > s.End-s.Start:
+			return nil, fmt.Errorf("session %s: warmup must be between 0 and the session length", s.Name)
+		}
+		names[s.Name] = true
+	}
+	sort.SliceStable(calendar.Sessions, func(i, j int) bool { return calendar.Sessions[i].Start < calendar.Sessions[j].Start })
+	for i := 1; i < len(calendar.Sessions); i++ {
+		if previous, s := calendar.Sessions[i-1], calendar.Sessions[i]; s.Start < previous.End {
+			return nil, fmt.Errorf("session %s overlaps %s", s.Name, previous.Name)
+		}
+	}
+	return &calendar, nil
+}
+
+// Session 按名称查找时段
+func (c *TradingCalendar) Session(name string) (TradingSession, bool) {
+	for _, s := range c.Sessions {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return TradingSession{}, false
+}
+
+// SessionAt 返回t所在的时段，不在任何时段内时ok为false
+func (c *TradingCalendar) SessionAt(t time.Time) (TradingSession, bool) {
+	for _, s := range c.Sessions {
+		if s.Contains(t) {
+			return s, true
+		}
+	}
+	return TradingSession{}, false
+}
+
+// OrderSession 订单所属的时段，以收到客户端订单的时间为准
+func (c *TradingCalendar) OrderSession(order *Order) (TradingSession, bool) {
+	m, ok := order.Milestone(RecvClient)
+	if !ok {
+		return TradingSession{}, false
+	}
+	return c.SessionAt(m.Time)
+}
+
+// InWarmup 订单是否在所属时段的预热期内收到
+func (c *TradingCalendar) InWarmup(order *Order) bool {
+	m, ok := order.Milestone(RecvClient)
+	if !ok {
+		return false
+	}
+	s, ok := c.SessionAt(m.Time)
+	return ok && s.InWarmup(m.Time)
+}
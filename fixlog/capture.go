@@ -0,0 +1,51 @@
+package fixlog
+
+import (
+	"fmt"
+	"io"
+
+	"v8/internal/pcap"
+)
+
+// LoadCapture 从抓包(pcap/pcapng)中重组ports上的TCP流(为空时不限端口)，按与日志相同的规则识别FIX报文，
+// 订单完成时补充Wire*时间点；需在Analyze之前调用
+func (a *Analyzer) LoadCapture(r io.Reader, ports []uint16) error {
+	reader, err := pcap.NewReader(r)
+	if err != nil {
+		return err
+	}
+
+	if a.wire == nil {
+		a.wire = make(map[string]map[MilestoneKind]Milestone)
+	}
+	reassembler := pcap.NewReassembler(ports)
+	emit := func(message pcap.Message) {
+		a.stats.WireMessages += 1
+		kind, key, ok := classify(message.Line)
+		if !ok || key == "" {
+			return
+		}
+		wire, _ := kind.Wire()
+		milestones, exists := a.wire[key]
+		if !exists {
+			milestones = make(map[MilestoneKind]Milestone)
+			a.wire[key] = milestones
+		}
+		// 只取每个时间点的第一条报文
+		if _, exists := milestones[wire]; !exists {
+			milestones[wire] = Milestone{Kind: wire, Time: message.Time.In(a.opts.location)}
+		}
+	}
+
+	for {
+		packet, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error reading capture: %v", err)
+		}
+		reassembler.Add(packet, emit)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package fixlog
+
+import (
+	"time"
+
+	"v8/internal/logline"
+)
+
+// Consumer 接收分析过程中的事件，按日志顺序同步调用；返回错误时分析中止并返回该错误。
+// 传入的Order在之后仍可能被更新，需异步使用时应自行复制。
+type Consumer interface {
+	// OnMilestone 订单新增一个时间点。撮合引擎日志和抓包中的时间点在订单完成(或Flush)时补充
+	OnMilestone(order *Order, m Milestone) error
+	// OnOrderComplete 向客户端返回JNET确认，订单生命周期结束
+	OnOrderComplete(order *Order) error
+	// OnOrphan 收到exch_sim的JNET更正但直到输入结束都未返回客户端的订单
+	OnOrphan(order *Order) error
+	// OnSessionEvent FIX会话层管理报文
+	OnSessionEvent(event SessionEvent) error
+}
+
+// NopConsumer 忽略所有事件，嵌入后只需实现关心的方法
+type NopConsumer struct{}
+
+func (NopConsumer) OnMilestone(order *Order, m Milestone) error { return nil }
+func (NopConsumer) OnOrderComplete(order *Order) error          { return nil }
+func (NopConsumer) OnOrphan(order *Order) error                 { return nil }
+func (NopConsumer) OnSessionEvent(event SessionEvent) error     { return nil }
+
+// 会话层管理报文类型，心跳(35=0)过于频繁不作为事件
+var sessionMsgTypes = map[string]string{
+	"A": "Logon",
+	"5": "Logout",
+	"4": "SequenceReset",
+	"2": "ResendRequest",
+	"3": "Reject",
+	"1": "TestRequest",
+}
+
+// SessionEvent 一条会话层管理报文
+type SessionEvent struct {
+	Time         time.Time
+	LineNo       int
+	Direction    string // "send"或"recv"
+	MsgType      string // 35
+	Name         string // 报文类型名，如Logon
+	SenderCompID string `json:",omitempty"` // 49
+	TargetCompID string `json:",omitempty"` // 56
+	MsgSeqNum    string `json:",omitempty"` // 34
+	Text         string `json:",omitempty"` // 58
+	Line         string `json:",omitempty"` // 原始日志行，需WithRawLines
+}
+
+func (a *Analyzer) sessionEvent(line string, lineNo int) (SessionEvent, bool) {
+	msgType, ok := logline.Tag(line, "35")
+	if !ok {
+		return SessionEvent{}, false
+	}
+	name, ok := sessionMsgTypes[msgType]
+	if !ok {
+		return SessionEvent{}, false
+	}
+	logTime, ok := a.logTime(line)
+	if !ok {
+		return SessionEvent{}, false
+	}
+
+	event := SessionEvent{
+		Time:      logTime,
+		LineNo:    lineNo,
+		Direction: logline.Direction(line),
+		MsgType:   msgType,
+		Name:      name,
+	}
+	event.SenderCompID, _ = logline.Tag(line, "49")
+	event.TargetCompID, _ = logline.Tag(line, "56")
+	event.MsgSeqNum, _ = logline.Tag(line, "34")
+	event.Text, _ = logline.Tag(line, "58")
+	if a.opts.rawLines {
+		event.Line = line
+	}
+	return event, true
+}
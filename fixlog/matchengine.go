@@ -0,0 +1,99 @@
+package fixlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"v8/internal/logline"
+)
+
+// 撮合引擎日志中的时间点，按报文携带的198(客户原始ClOrdID)、11(发往exch_sim的ClOrdID)、17(回报的ExecID)索引
+type matchEngineIndex struct {
+	byClOrdID      map[string][]Milestone
+	byMatchClOrdID map[string][]Milestone
+	byExecID       map[string][]Milestone
+}
+
+// 按198、发往exch_sim的11、回报的17查找订单对应的撮合引擎时间点，每类只取日志中的第一条
+func (index *matchEngineIndex) lookup(order *Order) []Milestone {
+	var candidates []Milestone
+	candidates = append(candidates, index.byClOrdID[order.ClOrdID]...)
+	if order.MatchClOrdID != "" {
+		candidates = append(candidates, index.byMatchClOrdID[order.MatchClOrdID]...)
+	}
+	for _, e := range []*Execution{order.Fill, order.Correction} {
+		if e != nil && e.ExecID != "" {
+			candidates = append(candidates, index.byExecID[e.ExecID]...)
+		}
+	}
+
+	first := make(map[MilestoneKind]Milestone)
+	for _, m := range candidates {
+		if prev, exists := first[m.Kind]; !exists || m.LineNo < prev.LineNo {
+			first[m.Kind] = m
+		}
+	}
+	var milestones []Milestone
+	for _, kind := range []MilestoneKind{MeRecvOrder, MeSendFill, MeSendCorrect} {
+		if m, ok := first[kind]; ok {
+			milestones = append(milestones, m)
+		}
+	}
+	return milestones
+}
+
+// LoadMatchEngine 读入撮合引擎日志，订单完成时补充MeRecvOrder/MeSendFill/MeSendCorrect；
+// 需在Analyze之前调用，之后EstimateClockSkew改用撮合引擎日志时间作为样本
+func (a *Analyzer) LoadMatchEngine(r io.Reader) error {
+	index := &matchEngineIndex{
+		byClOrdID:      make(map[string][]Milestone),
+		byMatchClOrdID: make(map[string][]Milestone),
+		byExecID:       make(map[string][]Milestone),
+	}
+
+	scanner := logline.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !logline.IsFix(line) {
+			continue
+		}
+		direction := logline.Direction(line)
+
+		var kind MilestoneKind
+		switch {
+		case strings.Contains(line, "|35=D|") && direction == "recv":
+			kind = MeRecvOrder
+		case strings.Contains(line, "|35=8|") && strings.Contains(line, "|150=2|") && direction == "send":
+			kind = MeSendFill
+		case strings.Contains(line, "|35=8|") && strings.Contains(line, "|150=G|") && direction == "send":
+			kind = MeSendCorrect
+		default:
+			continue
+		}
+
+		logTime, hasTime := a.logTime(line)
+		if !hasTime {
+			continue
+		}
+		m := Milestone{Kind: kind, Time: logTime, LineNo: scanner.LineNo()}
+		if a.opts.rawLines {
+			m.Line = line
+		}
+		// 报文携带的各ID都建立索引，同一报文经多个ID命中时只算一次
+		for _, ids := range []struct {
+			tag   string
+			index map[string][]Milestone
+		}{{"198", index.byClOrdID}, {"11", index.byMatchClOrdID}, {"17", index.byExecID}} {
+			if id, ok := logline.Tag(line, ids.tag); ok {
+				ids.index[id] = append(ids.index[id], m)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading matching engine log: %v", err)
+	}
+
+	a.matchEngine = index
+	return nil
+}
@@ -0,0 +1,219 @@
+package fixlog
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MilestoneKind 订单生命周期中的时间点类型
+type MilestoneKind int
+
+const (
+	// OMS日志中的时间点
+	RecvClient       MilestoneKind = iota // 收到客户端35=D
+	SendMatch                             // router_branch发往exch_sim的35=D
+	RecvMatchFill                         // 收到exch_sim的成交回报(150=2)
+	RecvMatchCorrect                      // 收到exch_sim的JNET更正(150=G)
+	FinalReturn                           // 向客户端返回JNET确认(35=8,20=2,39=2)
+
+	// 对端在FIX报文中打点的时间(52/60)
+	ClientSending // 客户端35=D的52
+	MatchTransact // exch_sim成交回报的60
+	MatchSending  // exch_sim成交回报的52
+
+	// 撮合引擎日志中的时间点
+	MeRecvOrder
+	MeSendFill
+	MeSendCorrect
+
+	// 抓包中与OMS日志时间点对应的报文时间
+	WireRecvClient
+	WireSendMatch
+	WireRecvMatchFill
+	WireRecvMatchCorrect
+	WireFinalReturn
+
+	numMilestoneKinds
+)
+
+var milestoneNames = [numMilestoneKinds]string{
+	"RecvClientTime", "SendMatchTime", "RecvMatchFillTime", "RecvMatchCorrectTime", "FinalReturnTime",
+	"ClientSendingTime", "MatchTransactTime", "MatchSendingTime",
+	"MeRecvOrderTime", "MeSendFillTime", "MeSendCorrectTime",
+	"WireRecvClientTime", "WireSendMatchTime", "WireRecvMatchFillTime", "WireRecvMatchCorrectTime", "WireFinalReturnTime",
+}
+
+// LogMilestones OMS日志中的时间点，按生命周期顺序排列
+var LogMilestones = []MilestoneKind{RecvClient, SendMatch, RecvMatchFill, RecvMatchCorrect, FinalReturn}
+
+func (k MilestoneKind) String() string {
+	if k < 0 || k >= numMilestoneKinds {
+		return fmt.Sprintf("MilestoneKind(%d)", int(k))
+	}
+	return milestoneNames[k]
+}
+
+// MarshalText 使Order.Milestones在JSON中以时间点名称为键
+func (k MilestoneKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+func (k *MilestoneKind) UnmarshalText(text []byte) error {
+	for i, name := range milestoneNames {
+		if name == string(text) {
+			*k = MilestoneKind(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown milestone %q", text)
+}
+
+// Wire 返回OMS日志时间点在抓包中对应的时间点
+func (k MilestoneKind) Wire() (MilestoneKind, bool) {
+	if k < RecvClient || k > FinalReturn {
+		return 0, false
+	}
+	return WireRecvClient + (k - RecvClient), true
+}
+
+// Milestone 订单生命周期中的一个时间点
+type Milestone struct {
+	Kind   MilestoneKind `json:"-"`
+	Time   time.Time
+	LineNo int    `json:",omitempty"` // 所在日志的行号，FIX打点及抓包时间为0
+	Line   string `json:",omitempty"` // 原始日志行，需WithRawLines
+}
+
+// Execution 一条执行回报(35=8)
+type Execution struct {
+	ExecID        string `json:",omitempty"` // 17
+	ExecRefID     string `json:",omitempty"` // 19
+	OrderID       string `json:",omitempty"` // 37
+	ExecType      string `json:",omitempty"` // 150
+	OrdStatus     string `json:",omitempty"` // 39
+	ExecTransType string `json:",omitempty"` // 20
+	LastQty       string `json:",omitempty"` // 32
+	LastPx        string `json:",omitempty"` // 31
+	SenderCompID  string `json:",omitempty"` // 49
+	TargetCompID  string `json:",omitempty"` // 56
+}
+
+// Order 一笔客户订单的生命周期，以客户端的ClOrdID(11)标识，发往exch_sim时由198携带
+type Order struct {
+	ClOrdID      string
+	Account      string `json:",omitempty"`
+	Symbol       string `json:",omitempty"`
+	Side         string `json:",omitempty"`
+	OrderQty     string `json:",omitempty"`
+	ClientCompID string `json:",omitempty"` // 客户端会话的SenderCompID
+	MatchClOrdID string `json:",omitempty"` // 发往exch_sim的11
+
+	Fill       *Execution `json:",omitempty"` // exch_sim的成交回报
+	Correction *Execution `json:",omitempty"` // exch_sim的JNET更正
+	Final      *Execution `json:",omitempty"` // 返回客户端的JNET确认
+
+	Milestones map[MilestoneKind]Milestone
+
+	// 是否已补充外部时间点
+	resolved bool
+	// 开始跟踪时的日志时间，用于超时淘汰
+	firstSeen time.Time
+	// 被WithOrderFilter排除
+	excluded bool
+}
+
+// Milestone 返回指定时间点，不存在时ok为false
+func (o *Order) Milestone(kind MilestoneKind) (Milestone, bool) {
+	m, ok := o.Milestones[kind]
+	return m, ok
+}
+
+// Complete 是否已向客户端返回JNET确认
+func (o *Order) Complete() bool {
+	_, ok := o.Milestones[FinalReturn]
+	return ok
+}
+
+// 只记录每个时间点的第一次出现，返回是否为新记录
+func (o *Order) setMilestone(m Milestone) bool {
+	if _, exists := o.Milestones[m.Kind]; exists {
+		return false
+	}
+	if o.Milestones == nil {
+		o.Milestones = make(map[MilestoneKind]Milestone)
+	}
+	o.Milestones[m.Kind] = m
+	return true
+}
+
+// Stage 两个时间点之间的耗时阶段
+type Stage struct {
+	Name     string
+	From, To MilestoneKind
+}
+
+// Cost 计算订单在该阶段的耗时，任一时间点缺失时ok为false
+func (s Stage) Cost(o *Order) (time.Duration, bool) {
+	from, ok := o.Milestones[s.From]
+	if !ok {
+		return 0, false
+	}
+	to, ok := o.Milestones[s.To]
+	if !ok {
+		return 0, false
+	}
+	return to.Time.Sub(from.Time), true
+}
+
+var (
+	OmsCostTime1  = Stage{"OmsCostTime1", RecvClient, SendMatch}         // 处理客户端订单的耗时
+	MatchCostTime = Stage{"MatchCostTime", SendMatch, RecvMatchFill}     // 发出到收到成交回报
+	OmsCostTime2  = Stage{"OmsCostTime2", RecvMatchCorrect, FinalReturn} // 处理JNET更正并返回客户端的耗时
+	JnetCostTime  = Stage{"JnetCostTime", RecvMatchFill, RecvMatchCorrect}
+	TotalCostTime = Stage{"TotalCostTime", RecvClient, FinalReturn}
+)
+
+// CoreStages 基于OMS日志时间的阶段，顺序与CSV列一致
+var CoreStages = []Stage{OmsCostTime1, MatchCostTime, OmsCostTime2, JnetCostTime, TotalCostTime}
+
+// FixTimeStages 基于对端在报文中打点的52/60拆分的阶段
+var FixTimeStages = []Stage{
+	{"ClientNetCostTime", ClientSending, RecvClient},      // 客户端到OMS的网络延迟
+	{"MatchWireInCostTime", SendMatch, MatchTransact},     // OMS发出到交易所受理
+	{"MatchEngineCostTime", MatchTransact, MatchSending},  // 交易所撮合
+	{"MatchWireOutCostTime", MatchSending, RecvMatchFill}, // 交易所发出到OMS收到
+}
+
+// MatchEngineStages 结合撮合引擎日志拆分MatchCostTime得到的阶段
+var MatchEngineStages = []Stage{
+	{"OmsToMeNetCostTime", SendMatch, MeRecvOrder},
+	{"MeInternalCostTime", MeRecvOrder, MeSendFill},
+	{"MeToOmsNetCostTime", MeSendFill, RecvMatchFill},
+}
+
+// WireStages 抓包时间计算的阶段(口径同CoreStages)，以及日志与抓包的时间差：
+// 收到的报文为日志时间-抓包时间，发出的报文为抓包时间-日志时间，即报文在本机应用内外之间的耗时
+var WireStages = []Stage{
+	{"WireOmsCostTime1", WireRecvClient, WireSendMatch},
+	{"WireMatchCostTime", WireSendMatch, WireRecvMatchFill},
+	{"WireOmsCostTime2", WireRecvMatchCorrect, WireFinalReturn},
+	{"WireJnetCostTime", WireRecvMatchFill, WireRecvMatchCorrect},
+	{"WireTotalCostTime", WireRecvClient, WireFinalReturn},
+	{"RecvClientLogDelay", WireRecvClient, RecvClient},
+	{"SendMatchLogDelay", SendMatch, WireSendMatch},
+	{"RecvMatchFillLogDelay", WireRecvMatchFill, RecvMatchFill},
+	{"RecvMatchCorrectLogDelay", WireRecvMatchCorrect, RecvMatchCorrect},
+	{"FinalReturnLogDelay", FinalReturn, WireFinalReturn},
+}
+
+// SortByRecvClientTime 按收到客户端订单的时间排序，缺少该时间点的订单排在最前，时间相同时按ClOrdID排序
+func SortByRecvClientTime(orders []*Order) {
+	sort.Slice(orders, func(i, j int) bool {
+		ti, tj := orders[i].Milestones[RecvClient].Time, orders[j].Milestones[RecvClient].Time
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return orders[i].ClOrdID < orders[j].ClOrdID
+	})
+}
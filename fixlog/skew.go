@@ -0,0 +1,161 @@
+package fixlog
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"v8/internal/logline"
+)
+
+// SkewSample 一次跨主机的请求/应答往返：A发出T1，B收到T2，B发出T3，A收到T4
+// T1/T4为A主机时钟，T2/T3为B主机时钟
+type SkewSample struct {
+	T1, T2, T3, T4 time.Time
+}
+
+// B相对A的时钟偏移(NTP算法)
+func (s SkewSample) offset() time.Duration {
+	return (s.T2.Sub(s.T1) + s.T3.Sub(s.T4)) / 2
+}
+
+// 往返的网络耗时，扣除B端的处理时间
+func (s SkewSample) delay() time.Duration {
+	return s.T4.Sub(s.T1) - s.T3.Sub(s.T2)
+}
+
+// ClockSkew B时钟相对A时钟的偏移估计：offset(t) = Offset + Drift*(t-Base)
+type ClockSkew struct {
+	Offset  time.Duration
+	Drift   float64 // 每秒漂移的秒数
+	Base    time.Time
+	Samples int
+}
+
+// OffsetAt t时刻的偏移
+func (c ClockSkew) OffsetAt(t time.Time) time.Duration {
+	return c.Offset + time.Duration(c.Drift*float64(t.Sub(c.Base)))
+}
+
+// Correct 将B主机时钟下的时间换算到A主机时钟
+func (c ClockSkew) Correct(t time.Time) time.Time {
+	return t.Add(-c.OffsetAt(t))
+}
+
+func (c ClockSkew) String() string {
+	s := fmt.Sprintf("offset %+.1fus", float64(c.Offset)/float64(time.Microsecond))
+	if c.Drift != 0 {
+		s += fmt.Sprintf(" at %s, drift %+.3fus/s", c.Base.Format(logline.TimeLayout), c.Drift*1e6)
+	}
+	return s + fmt.Sprintf(", %d samples", c.Samples)
+}
+
+// EstimateClockSkew 按window切分时间段，每段只取往返耗时最小的样本(排队最少，偏移估计最准)。
+// 不拟合漂移时取各段偏移的中位数；拟合漂移时对各段偏移做最小二乘直线拟合。
+func EstimateClockSkew(samples []SkewSample, window time.Duration, fitDrift bool) (ClockSkew, error) {
+	if len(samples) == 0 {
+		return ClockSkew{}, fmt.Errorf("no request/response pairs to estimate clock offset")
+	}
+	if window <= 0 {
+		return ClockSkew{}, fmt.Errorf("invalid skew window: %v", window)
+	}
+
+	minRtt := make(map[int64]SkewSample)
+	for _, s := range samples {
+		if s.T3.Before(s.T2) || s.delay() < 0 {
+			continue // 时间戳乱序或精度不足，样本无效
+		}
+		bucket := s.T1.UnixNano() / int64(window)
+		best, ok := minRtt[bucket]
+		if !ok || s.delay() < best.delay() || (s.delay() == best.delay() && s.T1.Before(best.T1)) {
+			minRtt[bucket] = s
+		}
+	}
+	if len(minRtt) == 0 {
+		return ClockSkew{}, fmt.Errorf("no valid request/response pairs to estimate clock offset")
+	}
+
+	best := make([]SkewSample, 0, len(minRtt))
+	for _, s := range minRtt {
+		best = append(best, s)
+	}
+	sort.Slice(best, func(i, j int) bool {
+		return best[i].T1.Before(best[j].T1)
+	})
+
+	skew := ClockSkew{Base: best[0].T1, Samples: len(samples)}
+	if !fitDrift || len(best) < 2 {
+		offsets := make([]time.Duration, 0, len(best))
+		for _, s := range best {
+			offsets = append(offsets, s.offset())
+		}
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		skew.Offset = offsets[len(offsets)/2]
+		return skew, nil
+	}
+
+	// 最小二乘：x为距Base的秒数，y为偏移秒数
+	var sumX, sumY, sumXX, sumXY float64
+	for _, s := range best {
+		x := s.T1.Sub(skew.Base).Seconds()
+		y := s.offset().Seconds()
+		sumX += x
+		sumY += y
+		sumXX += x * x
+		sumXY += x * y
+	}
+	n := float64(len(best))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		skew.Offset = time.Duration(sumY / n * float64(time.Second))
+		return skew, nil
+	}
+	skew.Drift = (n*sumXY - sumX*sumY) / denominator
+	skew.Offset = time.Duration((sumY - skew.Drift*sumX) / n * float64(time.Second))
+	return skew, nil
+}
+
+// EstimateClockSkew 估计撮合主机相对OMS的时钟偏移：读入过撮合引擎日志时用其收发时间，
+// 否则退而使用exch_sim成交回报中的60/52；需在Flush之后调用
+func (a *Analyzer) EstimateClockSkew(window time.Duration, fitDrift bool) (ClockSkew, error) {
+	// OMS发出D -> 撮合收到D -> 撮合发出成交 -> OMS收到成交
+	pair := [4]MilestoneKind{SendMatch, MatchTransact, MatchSending, RecvMatchFill}
+	if a.matchEngine != nil {
+		pair = [4]MilestoneKind{SendMatch, MeRecvOrder, MeSendFill, RecvMatchFill}
+	}
+
+	var samples []SkewSample
+	for _, order := range a.orders {
+		var times [4]time.Time
+		valid := true
+		for i, kind := range pair {
+			m, ok := order.Milestones[kind]
+			if !ok {
+				valid = false
+				break
+			}
+			times[i] = m.Time
+		}
+		if valid {
+			samples = append(samples, SkewSample{times[0], times[1], times[2], times[3]})
+		}
+	}
+	return EstimateClockSkew(samples, window, fitDrift)
+}
+
+// 撮合主机时钟下的时间点：exch_sim即撮合引擎，其在FIX报文中打点的60/52与撮合引擎日志使用同一主机时钟
+func isMatchClock(kind MilestoneKind) bool {
+	switch kind {
+	case MatchTransact, MatchSending, MeRecvOrder, MeSendFill, MeSendCorrect:
+		return true
+	}
+	return false
+}
+
+// WithClockSkew 分析时将撮合主机时钟下的时间点按skew换算到OMS时钟。
+// 偏移需在读完全部样本后才能估计，通常先用一个Analyzer调用EstimateClockSkew，再带上结果重新分析
+func WithClockSkew(skew ClockSkew) Option {
+	return func(o *options) {
+		o.skew = &skew
+	}
+}
@@ -0,0 +1,276 @@
+package fixlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v8/internal/stats"
+)
+
+// SLAConfig SLA规则配置，JSON格式：
+//
+//	{"rules": [
+//	  {"stage": "OmsCostTime1", "stat": "p99", "lessThan": "200us"},
+//	  {"stage": "TotalCostTime", "stat": "max", "lessThan": "5ms", "perAccount": true},
+//	  {"stage": "TotalCostTime", "stat": "p99", "lessThan": "2ms", "account": "ACC1"},
+//	  {"stage": "TotalCostTime", "stat": "p99", "lessThan": "1ms", "perSession": true, "excludeWarmup": true}
+//	]}
+//
+// session、perSession、excludeWarmup需要交易时段配置(TradingCalendar)
+type SLAConfig struct {
+	Rules []SLARule `json:"rules"`
+}
+
+// SLARule 某阶段耗时统计量需低于阈值；stat为max、mean或pNN(如p99、p99.9)
+type SLARule struct {
+	Name       string   `json:"name,omitempty"`
+	Stage      string   `json:"stage"`
+	Stat       string   `json:"stat"`
+	LessThan   Duration `json:"lessThan"`
+	Account    string   `json:"account,omitempty"`    // 只评估该账户的订单
+	PerAccount bool     `json:"perAccount,omitempty"` // 对每个账户分别评估
+
+	Session       string `json:"session,omitempty"`       // 只评估该交易时段内收到的订单
+	PerSession    bool   `json:"perSession,omitempty"`    // 对每个交易时段分别评估，不在任何时段内的订单不参与
+	ExcludeWarmup bool   `json:"excludeWarmup,omitempty"` // 不评估交易时段预热期内收到的订单
+}
+
+func (r SLARule) usesCalendar() bool {
+	return r.Session != "" || r.PerSession || r.ExcludeWarmup
+}
+
+// Duration JSON中以"200us"、"5ms"等字符串表示的时长
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"200us\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (r SLARule) String() string {
+	s := fmt.Sprintf("%s %s < %v", r.Stat, r.Stage, time.Duration(r.LessThan))
+	switch {
+	case r.Account != "":
+		s += " (account " + r.Account + ")"
+	case r.PerAccount:
+		s += " (per account)"
+	}
+	switch {
+	case r.Session != "":
+		s += " (session " + r.Session + ")"
+	case r.PerSession:
+		s += " (per session)"
+	}
+	if r.ExcludeWarmup {
+		s += " (excluding warm-up)"
+	}
+	if r.Name != "" {
+		s = r.Name + ": " + s
+	}
+	return s
+}
+
+// StageByName 按名称查找阶段，包括FIX打点、撮合引擎日志和抓包计算的阶段
+func StageByName(name string) (Stage, bool) {
+	for _, stages := range [][]Stage{CoreStages, FixTimeStages, MatchEngineStages, WireStages} {
+		for _, stage := range stages {
+			if stage.Name == name {
+				return stage, true
+			}
+		}
+	}
+	return Stage{}, false
+}
+
+// 解析统计量，返回按升序排序的耗时计算统计值的函数
+func parseStat(stat string) (func(sorted []time.Duration) time.Duration, error) {
+	switch {
+	case stat == "max":
+		return func(sorted []time.Duration) time.Duration { return sorted[len(sorted)-1] }, nil
+	case stat == "mean":
+		return func(sorted []time.Duration) time.Duration {
+			var sum time.Duration
+			for _, cost := range sorted {
+				sum += cost
+			}
+			return sum / time.Duration(len(sorted))
+		}, nil
+	case strings.HasPrefix(stat, "p"):
+		p, err := strconv.ParseFloat(stat[1:], 64)
+		if err != nil || p <= 0 || p > 100 {
+			return nil, fmt.Errorf("invalid percentile %q", stat)
+		}
+		return func(sorted []time.Duration) time.Duration { return stats.Percentile(sorted, p) }, nil
+	}
+	return nil, fmt.Errorf("unknown stat %q, expected max, mean or pNN", stat)
+}
+
+// LoadSLAConfig 读取并校验SLA规则
+func LoadSLAConfig(r io.Reader) (SLAConfig, error) {
+	var config SLAConfig
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return SLAConfig{}, fmt.Errorf("error parsing SLA config: %v", err)
+	}
+	for i, rule := range config.Rules {
+		if _, ok := StageByName(rule.Stage); !ok {
+			return SLAConfig{}, fmt.Errorf("rule %d: unknown stage %q", i+1, rule.Stage)
+		}
+		if _, err := parseStat(rule.Stat); err != nil {
+			return SLAConfig{}, fmt.Errorf("rule %d: %v", i+1, err)
+		}
+		if rule.LessThan <= 0 {
+			return SLAConfig{}, fmt.Errorf("rule %d: lessThan must be positive", i+1)
+		}
+	}
+	return config, nil
+}
+
+// CheckCalendar 校验规则中的交易时段；calendar为nil时不能使用session、perSession、excludeWarmup
+func (c SLAConfig) CheckCalendar(calendar *TradingCalendar) error {
+	for i, rule := range c.Rules {
+		if !rule.usesCalendar() {
+			continue
+		}
+		if calendar == nil {
+			return fmt.Errorf("rule %d: session, perSession and excludeWarmup need a trading calendar", i+1)
+		}
+		if rule.Session != "" {
+			if _, ok := calendar.Session(rule.Session); !ok {
+				return fmt.Errorf("rule %d: unknown session %q", i+1, rule.Session)
+			}
+		}
+	}
+	return nil
+}
+
+// SLAResult 一条规则在一组订单上的评估结果
+type SLAResult struct {
+	Rule      SLARule
+	Account   string        // 按账户评估时的账户
+	Session   string        // 按交易时段评估时的时段
+	Orders    int           // 有该阶段耗时的订单数
+	Value     time.Duration // 统计值
+	Violated  bool
+	Violators []*Order // 耗时未低于阈值的订单，按耗时降序
+}
+
+// EvaluateSLA 按规则评估订单，没有该阶段耗时的订单不参与；规则中没有订单的分组不产生结果。
+// calendar用于按交易时段评估的规则，须先经CheckCalendar校验
+func EvaluateSLA(config SLAConfig, orders []*Order, calendar *TradingCalendar) []SLAResult {
+	var results []SLAResult
+	for _, rule := range config.Rules {
+		stage, _ := StageByName(rule.Stage)
+		stat, _ := parseStat(rule.Stat)
+		threshold := time.Duration(rule.LessThan)
+
+		type slaGroup struct{ session, account string }
+		groups := make(map[slaGroup][]*Order)
+		for _, order := range orders {
+			if rule.Account != "" && order.Account != rule.Account {
+				continue
+			}
+			var group slaGroup
+			if rule.PerAccount {
+				group.account = order.Account
+			}
+			if rule.usesCalendar() {
+				session, ok := calendar.OrderSession(order)
+				if (rule.Session != "" || rule.PerSession) && !ok {
+					continue
+				}
+				if rule.Session != "" && session.Name != rule.Session {
+					continue
+				}
+				if rule.ExcludeWarmup && calendar.InWarmup(order) {
+					continue
+				}
+				if rule.PerSession {
+					group.session = session.Name
+				}
+			}
+			groups[group] = append(groups[group], order)
+		}
+
+		// 按时段先后、账户排序
+		sessionIndex := make(map[string]int)
+		if calendar != nil {
+			for i, session := range calendar.Sessions {
+				sessionIndex[session.Name] = i
+			}
+		}
+		keys := make([]slaGroup, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].session != keys[j].session {
+				return sessionIndex[keys[i].session] < sessionIndex[keys[j].session]
+			}
+			return keys[i].account < keys[j].account
+		})
+
+		for _, key := range keys {
+			type costOrder struct {
+				order *Order
+				cost  time.Duration
+			}
+			var costOrders []costOrder
+			for _, order := range groups[key] {
+				if cost, ok := stage.Cost(order); ok {
+					costOrders = append(costOrders, costOrder{order, cost})
+				}
+			}
+			if len(costOrders) == 0 {
+				continue
+			}
+			sort.Slice(costOrders, func(i, j int) bool {
+				if costOrders[i].cost != costOrders[j].cost {
+					return costOrders[i].cost > costOrders[j].cost
+				}
+				return costOrders[i].order.ClOrdID < costOrders[j].order.ClOrdID
+			})
+
+			sorted := make([]time.Duration, len(costOrders))
+			for i, co := range costOrders {
+				sorted[len(costOrders)-1-i] = co.cost
+			}
+			result := SLAResult{Rule: rule, Account: key.account, Session: key.session, Orders: len(costOrders), Value: stat(sorted)}
+			if rule.Account != "" {
+				result.Account = rule.Account
+			}
+			if rule.Session != "" {
+				result.Session = rule.Session
+			}
+			result.Violated = result.Value >= threshold
+			if result.Violated {
+				for _, co := range costOrders {
+					if co.cost < threshold {
+						break
+					}
+					result.Violators = append(result.Violators, co.order)
+				}
+			}
+			results = append(results, result)
+		}
+	}
+	return results
+}
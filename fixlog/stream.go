@@ -0,0 +1,90 @@
+package fixlog
+
+import (
+	"strings"
+	"time"
+)
+
+// WithOrderTimeout 订单开始跟踪超过timeout(按日志时间)后不再跟踪，其中未完成且收到过JNET更正的作为孤儿订单上报。
+// 用于长时间运行的实时分析，避免订单一直占用内存；0表示不淘汰
+func WithOrderTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.orderTimeout = timeout
+	}
+}
+
+// WithEvictCompleted 订单完成并推送OnOrderComplete后即不再保留，Orders/CompletedOrders不再包含这些订单。
+// 之后同一ClOrdID重复的JNET确认被忽略，直到超过WithOrderTimeout
+func WithEvictCompleted() Option {
+	return func(o *options) {
+		o.evictCompleted = true
+	}
+}
+
+// Feed 处理一行日志，供实时跟踪等逐行读取的场景使用；lineNo为该行在所在文件中的行号
+func (a *Analyzer) Feed(line string, lineNo int) error {
+	return a.processLine(strings.Replace(line, "\x01", "|", -1), lineNo)
+}
+
+// 日志时间前进时淘汰超时的订单，每秒(日志时间)最多检查一次
+func (a *Analyzer) advance(logTime time.Time) error {
+	if !logTime.After(a.now) {
+		return nil
+	}
+	a.now = logTime
+	if a.opts.orderTimeout <= 0 || a.now.Sub(a.lastSweep) < time.Second {
+		return nil
+	}
+	a.lastSweep = a.now
+
+	deadline := a.now.Add(-a.opts.orderTimeout)
+	for len(a.pending) > 0 && a.pending[0].firstSeen.Before(deadline) {
+		order := a.pending[0]
+		a.pending[0] = nil
+		a.pending = a.pending[1:]
+
+		// 已完成并被淘汰，或同一ClOrdID已重新开始跟踪
+		if current, exists := a.orders[order.ClOrdID]; !exists || current != order {
+			continue
+		}
+		delete(a.orders, order.ClOrdID)
+		if order.Complete() {
+			continue
+		}
+		a.stats.Evicted += 1
+		if err := a.abandon(order); err != nil {
+			return err
+		}
+	}
+	for len(a.evictedOrder) > 0 && a.evictedOrder[0].at.Before(deadline) {
+		a.forgetEvicted()
+	}
+	return nil
+}
+
+// 未设置WithOrderTimeout时最多记住的已淘汰订单数
+const maxEvicted = 100000
+
+type evictedID struct {
+	clOrdID string
+	at      time.Time
+}
+
+// 淘汰已完成的订单，并记住其ClOrdID直到超时(或超过maxEvicted)，期间重复的JNET确认被忽略
+func (a *Analyzer) evict(order *Order, logTime time.Time) {
+	delete(a.orders, order.ClOrdID)
+	a.evicted[order.ClOrdID] = logTime
+	a.evictedOrder = append(a.evictedOrder, evictedID{order.ClOrdID, logTime})
+	for len(a.evictedOrder) > maxEvicted {
+		a.forgetEvicted()
+	}
+}
+
+// 按淘汰的先后忘掉最早的一个已淘汰订单；同一ClOrdID再次淘汰过时保留较新的记录
+func (a *Analyzer) forgetEvicted() {
+	id := a.evictedOrder[0]
+	a.evictedOrder = a.evictedOrder[1:]
+	if at, ok := a.evicted[id.clOrdID]; ok && at.Equal(id.at) {
+		delete(a.evicted, id.clOrdID)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/stats"
+)
+
+// 跟踪持续增长的日志文件，处理轮转(同一路径换成新文件)和截断
+type tailer struct {
+	path    string
+	file    *os.File
+	info    os.FileInfo
+	reader  *bufio.Reader
+	offset  int64
+	partial string // 尚未读到换行符的半行
+	lineNo  int
+
+	// 上次检查时的修改时间和文件开头的内容，用于发现copytruncate：
+	// 截断后写入方很快又写过了原来的位置时，仅凭大小看不出截断
+	modTime time.Time
+	head    []byte
+}
+
+// 比较文件开头的字节数
+const tailerHeadSize = 256
+
+func (t *tailer) open(fromEnd bool) error {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("error reading file info: %v", err)
+	}
+
+	var offset int64
+	if fromEnd {
+		if offset, err = file.Seek(0, io.SeekEnd); err != nil {
+			file.Close()
+			return fmt.Errorf("error seeking file: %v", err)
+		}
+	}
+	if t.file != nil {
+		t.file.Close()
+	}
+	t.file, t.info, t.offset = file, info, offset
+	t.reader = bufio.NewReaderSize(file, 1024*1024)
+	t.partial = ""
+	t.lineNo = 0
+	t.modTime, t.head = info.ModTime(), nil
+	return nil
+}
+
+func (t *tailer) close() {
+	if t.file != nil {
+		t.file.Close()
+	}
+}
+
+// 读出当前已写入的完整行，返回读到的行数
+func (t *tailer) readLines(emit func(line string, lineNo int) error) (int, error) {
+	count := 0
+	for {
+		chunk, err := t.reader.ReadString('\n')
+		t.offset += int64(len(chunk))
+		if err == io.EOF {
+			// 写入方可能只写了半行，留到下次凑成整行
+			t.partial += chunk
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("error reading file: %v", err)
+		}
+
+		line := strings.TrimRight(t.partial+chunk, "\r\n")
+		t.partial = ""
+		t.lineNo += 1
+		count += 1
+		if err := emit(line, t.lineNo); err != nil {
+			return count, err
+		}
+	}
+}
+
+// 检查文件是否被轮转或截断：轮转时先读完旧文件再切换到新文件，截断时从头重新读。
+// 文件变短、修改时间倒退或开头的内容改变均视为截断
+func (t *tailer) reopenIfRotated(emit func(line string, lineNo int) error) error {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		// 轮转过程中文件可能暂时不存在，下次再检查
+		return nil
+	}
+
+	if !os.SameFile(info, t.info) {
+		if _, err := t.readLines(emit); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Log rotated, reopening", t.path)
+		return t.open(false)
+	}
+
+	// 只比较已读过的部分
+	head := make([]byte, min(int64(tailerHeadSize), t.offset))
+	n, err := t.file.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("error reading file: %v", err)
+	}
+	head = head[:n]
+	truncated := info.Size() < t.offset || info.ModTime().Before(t.modTime) ||
+		len(head) < len(t.head) || !bytes.Equal(head[:len(t.head)], t.head)
+	t.modTime, t.head = info.ModTime(), head
+
+	if truncated {
+		fmt.Fprintln(os.Stderr, "Log truncated, reading from the start of", t.path)
+		if _, err := t.file.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("error seeking file: %v", err)
+		}
+		t.reader.Reset(t.file)
+		t.offset, t.partial, t.lineNo = 0, "", 0
+		t.head = nil
+	}
+	return nil
+}
+
+// 按JNET确认返回客户端的分钟汇总各阶段耗时，分钟结束后输出一行
+type minuteTable struct {
+	fixlog.NopConsumer
+	w      io.Writer
+	stages []fixlog.Stage
+	grace  time.Duration // 分钟结束后等待迟到订单的时间
+
+	minute time.Time
+	count  int
+	costs  [][]time.Duration
+	header bool
+}
+
+func newMinuteTable(w io.Writer, stages []fixlog.Stage, grace time.Duration) *minuteTable {
+	return &minuteTable{w: w, stages: stages, grace: grace, costs: make([][]time.Duration, len(stages))}
+}
+
+func (t *minuteTable) OnOrderComplete(order *fixlog.Order) error {
+	final, _ := order.Milestone(fixlog.FinalReturn)
+	minute := final.Time.Truncate(time.Minute)
+	if !t.minute.IsZero() && minute.After(t.minute) {
+		t.flush()
+	}
+	if t.minute.IsZero() {
+		t.minute = minute
+	}
+
+	// 迟到的订单计入当前分钟
+	t.count += 1
+	for i, stage := range t.stages {
+		if cost, ok := stage.Cost(order); ok {
+			t.costs[i] = append(t.costs[i], cost)
+		}
+	}
+	return nil
+}
+
+func (t *minuteTable) OnOrphan(order *fixlog.Order) error {
+	correct, _ := order.Milestone(fixlog.RecvMatchCorrect)
+	fmt.Fprintf(t.w, "ORPHAN ClientOrderID=%s Account=%s RecvMatchCorrectTime=%s not confirmed to the client\n",
+		order.ClOrdID, order.Account, correct.Time.Format("15:04:05.000000"))
+	return nil
+}
+
+// 没有新订单时，按当前时间判断分钟是否已结束
+func (t *minuteTable) tick(now time.Time) {
+	if !t.minute.IsZero() && now.Sub(t.minute) >= time.Minute+t.grace {
+		t.flush()
+	}
+}
+
+func (t *minuteTable) flush() {
+	if t.minute.IsZero() {
+		return
+	}
+	if !t.header {
+		fmt.Fprintf(t.w, "%-5s %6s", "Time", "Orders")
+		for _, stage := range t.stages {
+			fmt.Fprintf(t.w, " %21s", stage.Name+" p50/p99")
+		}
+		fmt.Fprintln(t.w)
+		t.header = true
+	}
+
+	fmt.Fprintf(t.w, "%-5s %6d", t.minute.Format("15:04"), t.count)
+	for i := range t.stages {
+		costs := t.costs[i]
+		if len(costs) == 0 {
+			fmt.Fprintf(t.w, " %21s", "-")
+			continue
+		}
+		stats.SortDurations(costs)
+		p50 := stats.Percentile(costs, 50).Seconds() * 1000
+		p99 := stats.Percentile(costs, 99).Seconds() * 1000
+		fmt.Fprintf(t.w, " %21s", fmt.Sprintf("%.3f/%.3f", p50, p99))
+		t.costs[i] = costs[:0]
+	}
+	fmt.Fprintln(t.w)
+
+	t.minute = time.Time{}
+	t.count = 0
+}
+
+func runFollow(args []string) error {
+	fs := newFlagSet("follow", "[options] <logFilePath>", "Tail a growing OMS log and print a rolling per-minute latency table (milliseconds) until interrupted. "+
+		"The log is read again from the start when it shrinks, its modification time goes backwards or its first bytes change (copytruncate); "+
+		"a copytruncate that rewrites the same first bytes and grows past the read position within one poll interval goes unnoticed.")
+	timeout := fs.Duration("timeout", 5*time.Minute, "stop tracking an order this long after its first message; unconfirmed JNET corrections are reported as orphans")
+	poll := fs.Duration("poll", 200*time.Millisecond, "interval to check the log for new lines, rotation and truncation")
+	grace := fs.Duration("grace", 5*time.Second, "wait this long after a minute ends before printing its row")
+	fromEnd := fs.Bool("from-end", false, "start at the end of the log instead of reading it from the start")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to compare with UTC FIX timestamps")
+	filterValue := fs.String("filter", "", orderFilterUsage)
+	metricsAddr := fs.String("metrics-addr", "", "serve Prometheus/OpenMetrics latency metrics on this address at /metrics (e.g. :9464)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf(fs, "expected <logFilePath>")
+	}
+	if *poll <= 0 {
+		return usageErrorf(fs, "-poll must be positive")
+	}
+	orderFilter, err := parseOrderFilter(*filterValue, nil)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+
+	table := newMinuteTable(os.Stdout, fixlog.CoreStages, *grace)
+	opts := []fixlog.Option{
+		fixlog.WithLocation(loc),
+		fixlog.WithConsumer(table),
+		fixlog.WithOrderTimeout(*timeout),
+		fixlog.WithEvictCompleted(),
+	}
+	if orderFilter != nil {
+		opts = append(opts, fixlog.WithOrderFilter(orderFilter))
+	}
+	var exporter *latencyMetrics
+	if *metricsAddr != "" {
+		exporter = newLatencyMetrics(fixlog.CoreStages)
+		if err := exporter.serve(*metricsAddr); err != nil {
+			return err
+		}
+		opts = append(opts, fixlog.WithConsumer(exporter))
+	}
+	analyzer := fixlog.NewAnalyzer(opts...)
+
+	t := &tailer{path: positional[0]}
+	if err := t.open(*fromEnd); err != nil {
+		return err
+	}
+	defer t.close()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(*poll)
+	defer ticker.Stop()
+	for {
+		// 先检查截断，避免把copytruncate后新写入的内容从旧的位置接着读
+		if err := t.reopenIfRotated(analyzer.Feed); err != nil {
+			return err
+		}
+		count, err := t.readLines(analyzer.Feed)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			table.tick(time.Now())
+		}
+		if exporter != nil {
+			exporter.updateStats(analyzer.Stats())
+		}
+
+		select {
+		case <-interrupt:
+			table.flush()
+			s := analyzer.Stats()
+			fmt.Fprintf(os.Stderr, "Lines: %d, JNET confirmations: %d, orphans: %d, evicted: %d\n",
+				s.Lines, s.ConfirmedMessages, s.Orphans, s.Evicted)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"v8/fixlog"
+	"v8/internal/stats"
+)
+
+// 分组中没有取值的订单的组名
+const noAttribute = "(none)"
+
+// 一组订单
+type orderGroup struct {
+	name   string
+	orders []*fixlog.Order
+	warmup int // 按交易时段分组时，预热期内收到的订单数
+}
+
+// 按组名排序，noAttribute在最后
+func sortGroupNames(names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == noAttribute) != (names[j] == noAttribute) {
+			return names[j] == noAttribute
+		}
+		return names[i] < names[j]
+	})
+}
+
+// 按订单字段的取值分组，如Account.desk
+func groupByField(orders []*fixlog.Order, field orderField) []orderGroup {
+	byValue := make(map[string][]*fixlog.Order)
+	for _, order := range orders {
+		value, ok := field(order)
+		if !ok || value == "" {
+			value = noAttribute
+		}
+		byValue[value] = append(byValue[value], order)
+	}
+	names := make([]string, 0, len(byValue))
+	for name := range byValue {
+		names = append(names, name)
+	}
+	sortGroupNames(names)
+	groups := make([]orderGroup, len(names))
+	for i, name := range names {
+		groups[i] = orderGroup{name: name, orders: byValue[name]}
+	}
+	return groups
+}
+
+// 每组一行：订单数(及预热期内的订单数)和各阶段的p50/p99(毫秒)
+func writeGroupStats(w io.Writer, title string, groups []orderGroup, stages []fixlog.Stage, withWarmup bool) {
+	fmt.Fprintf(w, "%-20s %6s", title, "Orders")
+	if withWarmup {
+		fmt.Fprintf(w, " %7s", "Warm-up")
+	}
+	for _, stage := range stages {
+		fmt.Fprintf(w, " %21s", stage.Name+" p50/p99")
+	}
+	fmt.Fprintln(w)
+
+	for _, group := range groups {
+		fmt.Fprintf(w, "%-20s %6d", group.name, len(group.orders))
+		if withWarmup {
+			fmt.Fprintf(w, " %7d", group.warmup)
+		}
+		for _, stage := range stages {
+			costs := stageCosts(group.orders, stage)
+			if len(costs) == 0 {
+				fmt.Fprintf(w, " %21s", "-")
+				continue
+			}
+			p50 := stats.Percentile(costs, 50)
+			p99 := stats.Percentile(costs, 99)
+			fmt.Fprintf(w, " %21s", fmt.Sprintf("%.3f/%.3f", ms(p50), ms(p99)))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// orders、corrections的-by可用的名称：Account、Symbol或参考数据属性
+func checkRecordGroups(ref *referenceData, names []string) error {
+	for _, name := range names {
+		if name == "Account" || name == "Symbol" {
+			continue
+		}
+		if err := ref.check(name); err != nil {
+			return err
+		}
+		if !ref.hasAttribute(name) {
+			return fmt.Errorf("unknown group %q, expected Account, Symbol or a reference attribute such as Account.desk", name)
+		}
+	}
+	return nil
+}
+
+// 一条记录在分组键下的组名，没有取值时为noAttribute
+func recordGroup(ref *referenceData, name string, account string, symbol string) string {
+	var value string
+	switch name {
+	case "Account":
+		value = account
+	case "Symbol":
+		value = symbol
+	default:
+		value, _ = ref.lookup(name, account, symbol)
+	}
+	if value == "" {
+		return noAttribute
+	}
+	return value
+}
+
+// 每个分组键一张表：组名和记录数
+func writeGroupCounts(w io.Writer, by []string, records int, group func(i int, name string) string) {
+	for _, name := range by {
+		counts := make(map[string]int)
+		for i := 0; i < records; i++ {
+			counts[group(i, name)] += 1
+		}
+		values := make([]string, 0, len(counts))
+		for value := range counts {
+			values = append(values, value)
+		}
+		sortGroupNames(values)
+		fmt.Fprintf(w, "%-20s %6s\n", name, "Count")
+		for _, value := range values {
+			fmt.Fprintf(w, "%-20s %6d\n", value, counts[value])
+		}
+	}
+}
@@ -0,0 +1,322 @@
+// Package expr 解析并求值过滤表达式，如 35=D and 49=HRT* and dir=recv、
+// Account in (A1,A2) and 55 ~ "^7203"、54=1 and 38>1000。
+//
+// 语法：
+//
+//	expr       = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | "(" expr ")" | comparison
+//	comparison = name ( "=" | "!=" | "<" | "<=" | ">" | ">=" ) value
+//	           | name ( "~" | "!~" ) value
+//	           | name [ "not" ] "in" "(" value { "," value } ")"
+//
+// name为标签号或调用方定义的名称(如dir、time)。关键字不区分大小写。
+//
+// =、!=和in中value的*匹配任意字符串、?匹配任意一个字符，用双引号括起的value按字面比较。
+// ~为正则表达式匹配(RE2语法，匹配值的任意部分，需要时用^、$锚定)。
+// <、<=、>、>=按value的形式比较：数值(1000)、时长(5ms)、当日时刻(09:30:00)、
+// 日期时间("2024-04-11 09:30:00")，其余按字符串；取到的值无法按同一类型解析时不满足。
+//
+// 取不到值的name不满足除!=、!~、not in之外的任何比较。
+package expr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Env 按名称取值
+type Env interface {
+	Lookup(name string) (string, bool)
+}
+
+// EnvFunc 将函数用作Env
+type EnvFunc func(name string) (string, bool)
+
+func (f EnvFunc) Lookup(name string) (string, bool) {
+	return f(name)
+}
+
+// Expr 解析后的表达式
+type Expr interface {
+	Eval(env Env) bool
+	String() string
+}
+
+type orExpr struct{ left, right Expr }
+
+func (e orExpr) Eval(env Env) bool { return e.left.Eval(env) || e.right.Eval(env) }
+func (e orExpr) String() string    { return "(" + e.left.String() + " or " + e.right.String() + ")" }
+
+type andExpr struct{ left, right Expr }
+
+func (e andExpr) Eval(env Env) bool { return e.left.Eval(env) && e.right.Eval(env) }
+func (e andExpr) String() string    { return "(" + e.left.String() + " and " + e.right.String() + ")" }
+
+type notExpr struct{ operand Expr }
+
+func (e notExpr) Eval(env Env) bool { return !e.operand.Eval(env) }
+func (e notExpr) String() string    { return "not " + e.operand.String() }
+
+type compareExpr struct {
+	name   string
+	op     string  // =、!=、<、<=、>、>=、~、!~、in、not in
+	values []value // in、not in为列表，其余为一个
+	re     *regexp.Regexp
+}
+
+func (e compareExpr) Eval(env Env) bool {
+	s, ok := env.Lookup(e.name)
+	switch e.op {
+	case "=":
+		return ok && e.values[0].equal(s)
+	case "!=":
+		return !ok || !e.values[0].equal(s)
+	case "~":
+		return ok && e.re.MatchString(s)
+	case "!~":
+		return !ok || !e.re.MatchString(s)
+	case "in":
+		return ok && e.in(s)
+	case "not in":
+		return !ok || !e.in(s)
+	}
+	if !ok {
+		return false
+	}
+	c, ok := e.values[0].compare(s)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+func (e compareExpr) in(s string) bool {
+	for _, v := range e.values {
+		if v.equal(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e compareExpr) String() string {
+	if e.op == "in" || e.op == "not in" {
+		items := make([]string, len(e.values))
+		for i, v := range e.values {
+			items[i] = v.String()
+		}
+		return e.name + " " + e.op + " (" + strings.Join(items, ",") + ")"
+	}
+	return e.name + e.op + e.values[0].String()
+}
+
+// Glob 通配匹配：*匹配任意字符串(包括空串)，?匹配任意一个字符
+func Glob(pattern string, s string) bool {
+	// 回溯到最近一个*的位置重试
+	px, sx := 0, 0
+	starPx, starSx := -1, 0
+	for sx < len(s) {
+		switch {
+		case px < len(pattern) && pattern[px] == '*':
+			starPx, starSx = px, sx
+			px += 1
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == s[sx]):
+			px += 1
+			sx += 1
+		case starPx >= 0:
+			starSx += 1
+			px, sx = starPx+1, starSx
+		default:
+			return false
+		}
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px += 1
+	}
+	return px == len(pattern)
+}
+
+// Names 返回表达式中出现的全部名称，按首次出现的顺序
+func Names(e Expr) []string {
+	var names []string
+	seen := make(map[string]bool)
+	var walk func(e Expr)
+	walk = func(e Expr) {
+		switch e := e.(type) {
+		case orExpr:
+			walk(e.left)
+			walk(e.right)
+		case andExpr:
+			walk(e.left)
+			walk(e.right)
+		case notExpr:
+			walk(e.operand)
+		case compareExpr:
+			if !seen[e.name] {
+				seen[e.name] = true
+				names = append(names, e.name)
+			}
+		}
+	}
+	walk(e)
+	return names
+}
+
+// Parse 解析表达式
+func Parse(src string) (Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	e, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return e, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos += 1
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return fmt.Errorf("error parsing filter at position %d: %s", t.pos+1, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	t := p.next()
+	switch {
+	case t.isKeyword("not"):
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{operand}, nil
+	case t.kind == tokenLParen:
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, p.errorf(closing, "expected ) but found %s", closing)
+		}
+		return e, nil
+	case t.kind == tokenWord:
+		return p.parseComparison(t)
+	}
+	return nil, p.errorf(t, "expected a comparison but found %s", t)
+}
+
+func (p *parser) parseComparison(name token) (Expr, error) {
+	op := p.next()
+	switch {
+	case op.isKeyword("in"):
+		return p.parseIn(name, "in")
+	case op.isKeyword("not"):
+		if in := p.next(); !in.isKeyword("in") {
+			return nil, p.errorf(in, "expected in after %s not but found %s", name.text, in)
+		}
+		return p.parseIn(name, "not in")
+	case op.kind != tokenOp:
+		return nil, p.errorf(op, "expected an operator or in after %s but found %s", name.text, op)
+	}
+	t, err := p.parseValue(name.text + op.text)
+	if err != nil {
+		return nil, err
+	}
+	e := compareExpr{name: name.text, op: op.text, values: []value{newValue(t.text, t.kind == tokenString)}}
+	if op.text == "~" || op.text == "!~" {
+		if e.re, err = regexp.Compile(t.text); err != nil {
+			return nil, p.errorf(t, "invalid regular expression: %v", err)
+		}
+	}
+	return e, nil
+}
+
+func (p *parser) parseIn(name token, op string) (Expr, error) {
+	if open := p.next(); open.kind != tokenLParen {
+		return nil, p.errorf(open, "expected ( after %s %s but found %s", name.text, op, open)
+	}
+	e := compareExpr{name: name.text, op: op}
+	for {
+		t, err := p.parseValue(name.text + " " + op)
+		if err != nil {
+			return nil, err
+		}
+		e.values = append(e.values, newValue(t.text, t.kind == tokenString))
+		switch next := p.next(); next.kind {
+		case tokenComma:
+		case tokenRParen:
+			return e, nil
+		default:
+			return nil, p.errorf(next, "expected , or ) but found %s", next)
+		}
+	}
+}
+
+func (p *parser) parseValue(after string) (token, error) {
+	t := p.next()
+	if t.kind != tokenWord && t.kind != tokenString {
+		return t, p.errorf(t, "expected a value after %s but found %s", after, t)
+	}
+	return t, nil
+}
@@ -0,0 +1,226 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string // String()的结果，为空时应解析失败
+		err  string
+	}{
+		{"35=D", "35=D", ""},
+		{"35 = D and 49=HRT*", "(35=D and 49=HRT*)", ""},
+		// and优先于or，not只作用于紧随的比较或括号
+		{"35=D or 35=F and 49=A", "(35=D or (35=F and 49=A))", ""},
+		{"(35=D or 35=F) and 49=A", "((35=D or 35=F) and 49=A)", ""},
+		{"not 35=D and 49=A", "(not 35=D and 49=A)", ""},
+		{"not (35=D or 35=F)", "not (35=D or 35=F)", ""},
+		{"NOT 35=D AND 49=A OR 56=B", "((not 35=D and 49=A) or 56=B)", ""},
+		{"a=1 and b=2 and c=3", "((a=1 and b=2) and c=3)", ""},
+		{"1 in (A1, A2,A3)", "1 in (A1,A2,A3)", ""},
+		{"1 not in (A1)", "1 not in (A1)", ""},
+		{"1 NOT IN (\"A*\",B)", "1 not in (\"A*\",B)", ""},
+		{"55 ~ \"^72\" and 58 !~ reject", "(55~\"^72\" and 58!~reject)", ""},
+		{"38>1000 and 38<=2000 and 44>=1.5 and 44<2 and 54!=1", "((((38>1000 and 38<=2000) and 44>=1.5) and 44<2) and 54!=1)", ""},
+		{"58=\"a \\\"b\\\" c\"", "58=\"a \\\"b\\\" c\"", ""},
+
+		{"", "", "position 1: expected a comparison but found end of filter"},
+		{"35", "", "position 3: expected an operator or in after 35 but found end of filter"},
+		{"35=", "", "position 4: expected a value after 35= but found end of filter"},
+		{"35=D and", "", "position 9: expected a comparison but found end of filter"},
+		{"35=D 49=A", "", "position 6: unexpected '49'"},
+		{"(35=D", "", "position 6: expected ) but found end of filter"},
+		{"35=D)", "", "position 5: unexpected ')'"},
+		{"35 ! D", "", "position 4: expected != or !~"},
+		{"58=\"abc", "", "position 4: unterminated string"},
+		{"1 not (A)", "", "position 7: expected in after 1 not but found '('"},
+		{"1 in A", "", "position 6: expected ( after 1 in but found 'A'"},
+		{"1 in (A B)", "", "position 9: expected , or ) but found 'B'"},
+		{"1 in ()", "", "position 7: expected a value after 1 in but found ')'"},
+		{"55 ~ \"[\"", "", "position 6: invalid regular expression"},
+		{"= D", "", "position 1: expected a comparison but found '='"},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if tt.want == "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.src, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.src, err)
+			continue
+		}
+		if got := e.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := EnvFunc(func(name string) (string, bool) {
+		value, ok := map[string]string{
+			"35":   "D",
+			"49":   "HRT01",
+			"1":    "ACC1",
+			"55":   "7203.T",
+			"58":   "order a*b",
+			"38":   "1500",
+			"44":   "99.5",
+			"cost": "1.5ms",
+			"time": "04/11/2024 09:30:15.250000",
+			"52":   "20240411-00:30:15.250",
+			"iso":  "2024-04-11T09:30:15+09:00",
+		}[name]
+		return value, ok
+	})
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		// 逻辑运算及优先级
+		{"35=D and 49=HRT01", true},
+		{"35=D and 49=X", false},
+		{"35=F or 49=HRT01", true},
+		{"35=F or 35=G", false},
+		{"35=F and 49=X or 1=ACC1", true},
+		{"35=F and (49=X or 1=ACC1)", false},
+		{"not 35=F", true},
+		{"not 35=D or 1=ACC1", true},
+		{"not (35=D or 1=X)", false},
+		{"not not 35=D", true},
+
+		// 通配与字面值
+		{"49=HRT*", true},
+		{"49=HRT0?", true},
+		{"49=HRT?", false},
+		{"49=*01", true},
+		{"49=\"HRT*\"", false},
+		{"58=\"order a*b\"", true},
+		{"58=order*", true},
+		{"49!=HRT*", false},
+		{"49!=ABC*", true},
+
+		// in、not in
+		{"1 in (ACC2,ACC1)", true},
+		{"1 in (ACC2,ACC3)", false},
+		{"1 in (ACC*)", true},
+		{"1 in (\"ACC*\")", false},
+		{"1 not in (ACC2,ACC3)", true},
+		{"1 not in (ACC2,ACC?)", false},
+
+		// 正则
+		{"55 ~ \"^72\"", true},
+		{"55 ~ \"^03\"", false},
+		{"55 ~ \\.T$", true},
+		{"55 !~ \"^72\"", false},
+		{"55 !~ \"^03\"", true},
+
+		// 数值按数值比较而非字典序
+		{"38>1000", true},
+		{"38>=1500", true},
+		{"38>1500", false},
+		{"38<900", false},
+		{"38<=1500", true},
+		{"44<100", true},
+		{"44>99.49", true},
+		{"49>1000", false}, // 无法解析为数值
+		{"35>C", true},     // 非数值的value按字符串比较
+		{"35<C", false},
+
+		// 时长
+		{"cost>=1ms", true},
+		{"cost>1500us", false},
+		{"cost<2ms", true},
+		{"cost>1", false}, // 数值与时长不可比较
+		{"38>1ms", false},
+
+		// 当日时刻：日志前缀时间、FIX时间戳、RFC3339均按各自的时钟读数
+		{"time>=09:30", true},
+		{"time>=09:30:15", true},
+		{"time<09:30:15", false},
+		{"time<09:31", true},
+		{"52<01:00", true},
+		{"52>=00:30:15", true},
+		{"iso>=09:30 and iso<09:31", true},
+		{"cost>09:30", false},
+
+		// 日期时间
+		{"time>\"2024-04-11 09:30:00\"", true},
+		{"time<\"2024-04-11 09:30:15\"", false},
+		{"time>=2024-04-11", true},
+		{"time<2024-04-12", true},
+		{"52<2024-04-11T00:30:16", true},
+		{"iso>\"2024-04-11 09:30:16\"", false},
+		{"35<2024-04-12", false},
+
+		// 取不到值时只有!=、!~、not in满足
+		{"100=X", false},
+		{"100=*", false},
+		{"100!=X", true},
+		{"100~.", false},
+		{"100!~.", true},
+		{"100 in (X)", false},
+		{"100 not in (X)", true},
+		{"100<1", false},
+		{"100>=1", false},
+		{"100>\"\"", false},
+		{"not 100=X", true},
+	}
+	for _, tt := range tests {
+		e, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.src, err)
+			continue
+		}
+		if got := e.Eval(env); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestGlob(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "abc", true},
+		{"a*", "bac", false},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYc", true},
+		{"a*b*c", "aXcYb", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"??", "ab", true},
+		{"??", "abc", false},
+		{"*?", "", false},
+		{"**a", "ba", true},
+	}
+	for _, tt := range tests {
+		if got := Glob(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("Glob(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	e, err := Parse("35=D and (1 in (A) or not time>09:30) and 35!=F and Account.desk ~ eq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"35", "1", "time", "Account.desk"}
+	if got := Names(e); !reflect.DeepEqual(got, want) {
+		t.Errorf("Names = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString // 双引号括起的字面值
+	tokenOp
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // 在表达式中的字节位置
+}
+
+func (t token) isKeyword(keyword string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of filter"
+	case tokenString:
+		return fmt.Sprintf("%q", t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+// 运算符及括号之外、不含空白的连续字符构成一个词
+func isWordByte(c byte) bool {
+	return !strings.ContainsRune(" \t\r\n()=!<>~,\"", rune(c))
+}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			i += 1
+		case c == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i += 1
+		case c == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i += 1
+		case c == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i += 1
+		case c == '=' || c == '~':
+			tokens = append(tokens, token{tokenOp, string(c), i})
+			i += 1
+		case c == '<' || c == '>':
+			op := string(c)
+			if i+1 < len(src) && src[i+1] == '=' {
+				op += "="
+			}
+			tokens = append(tokens, token{tokenOp, op, i})
+			i += len(op)
+		case c == '!':
+			if i+1 >= len(src) || (src[i+1] != '=' && src[i+1] != '~') {
+				return nil, fmt.Errorf("error parsing filter at position %d: expected != or !~", i+1)
+			}
+			tokens = append(tokens, token{tokenOp, src[i : i+2], i})
+			i += 2
+		case c == '"':
+			text, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, fmt.Errorf("error parsing filter at position %d: %v", i+1, err)
+			}
+			tokens = append(tokens, token{tokenString, text, i})
+			i += n
+		default:
+			start := i
+			for i < len(src) && isWordByte(src[i]) {
+				i += 1
+			}
+			tokens = append(tokens, token{tokenWord, src[start:i], start})
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(src)}), nil
+}
+
+// 读取双引号字符串，支持\"和\\转义，返回内容及消耗的字节数
+func lexString(src string) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if i+1 >= len(src) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i += 1
+			b.WriteByte(src[i])
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteByte(src[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
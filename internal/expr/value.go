@@ -0,0 +1,139 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 大小比较时按字面值的形式确定类型，取到的值按同一类型解析，解析失败时比较结果为假
+type valueKind int
+
+const (
+	kindString   valueKind = iota // 按字典序
+	kindNumber                    // 如38>1000
+	kindDuration                  // 如TotalCostTime>=5ms
+	kindClock                     // 当日时刻，如time>=09:30
+	kindDateTime                  // 日期时间，如time<"2024-04-11 11:30:00"
+)
+
+// 取值中可识别的时间格式：日志前缀时间、FIX UTCTimestamp(52/60)、RFC3339及ISO 8601。
+// 比较时各自按其中的时钟读数，不做时区换算
+var valueLayouts = []string{
+	"01/02/2006 15:04:05",
+	"20060102-15:04:05",
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"15:04:05",
+}
+
+var clockLayouts = []string{"15:04:05", "15:04"}
+
+var dateTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+type value struct {
+	text    string
+	literal bool // 带引号，=、!=、in中不作为通配模式
+	kind    valueKind
+
+	number   float64
+	duration time.Duration // kindDuration为时长，kindClock为距零点的时长
+	dateTime time.Time
+}
+
+func newValue(text string, literal bool) value {
+	v := value{text: text, literal: literal}
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		v.kind, v.number = kindNumber, number
+		return v
+	}
+	if duration, err := time.ParseDuration(text); err == nil {
+		v.kind, v.duration = kindDuration, duration
+		return v
+	}
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			v.kind, v.duration = kindClock, clock(t)
+			return v
+		}
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			v.kind, v.dateTime = kindDateTime, t
+			return v
+		}
+	}
+	return v
+}
+
+func (v value) String() string {
+	if v.literal {
+		return strconv.Quote(v.text)
+	}
+	return v.text
+}
+
+// 等值比较：不带引号且含*或?时通配匹配
+func (v value) equal(s string) bool {
+	if v.literal || !strings.ContainsAny(v.text, "*?") {
+		return s == v.text
+	}
+	return Glob(v.text, s)
+}
+
+// 返回s与v比较的结果(-1、0、1)，s不能按v的类型解析时ok为false
+func (v value) compare(s string) (int, bool) {
+	switch v.kind {
+	case kindNumber:
+		number, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false
+		}
+		return sign(number - v.number), true
+	case kindDuration:
+		duration, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, false
+		}
+		return sign(float64(duration - v.duration)), true
+	case kindClock:
+		t, ok := parseTime(s)
+		if !ok {
+			return 0, false
+		}
+		return sign(float64(clock(t) - v.duration)), true
+	case kindDateTime:
+		t, ok := parseTime(s)
+		if !ok {
+			return 0, false
+		}
+		return t.Compare(v.dateTime), true
+	}
+	return strings.Compare(s, v.text), true
+}
+
+// 按时钟读数解析为UTC时间，丢弃时区
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range valueLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC), true
+		}
+	}
+	return time.Time{}, false
+}
+
+func clock(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+}
+
+func sign(x float64) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
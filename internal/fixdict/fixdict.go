@@ -0,0 +1,273 @@
+// Package fixdict 加载QuickFIX格式的XML数据字典，将FIX标签号、报文类型和枚举值翻译为名称，并提供重复组的定义。
+package fixdict
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// 内置字典，覆盖本系统各会话中出现的报文及字段
+//
+//go:embed default.xml
+var defaultXML []byte
+
+// Field 一个字段的定义
+type Field struct {
+	Number string
+	Name   string
+	Type   string
+	Values map[string]string // 枚举值 -> 含义
+}
+
+// Dictionary 可依次加载多个字典(如FIXT11.xml与FIX50SP2.xml，或标准字典与场内自定义标签)，后加载的覆盖先加载的
+type Dictionary struct {
+	fields   map[string]*Field   // 标签号 -> 定义
+	tags     map[string]string   // 字段名 -> 标签号
+	messages map[string]string   // MsgType -> 报文名
+	header   map[string]bool     // 标准头中的字段名
+	trailer  map[string]bool     // 标准尾中的字段名
+	groups   map[string][]string // 重复组的NumInGroup标签号 -> 组内字段的标签号，第一个为分隔字段
+}
+
+// QuickFIX XML中用到的部分
+type xmlDictionary struct {
+	XMLName      xml.Name       `xml:"fix"`
+	Header       []xmlRef       `xml:"header>field"`
+	HeaderGroups []xmlItem      `xml:"header>group"`
+	Trailer      []xmlRef       `xml:"trailer>field"`
+	Messages     []xmlMessage   `xml:"messages>message"`
+	Components   []xmlComponent `xml:"components>component"`
+	Fields       []xmlField     `xml:"fields>field"`
+}
+
+// 标准头、标准尾中按名称引用的字段
+type xmlRef struct {
+	Name string `xml:"name,attr"`
+}
+
+type xmlMessage struct {
+	Name    string    `xml:"name,attr"`
+	MsgType string    `xml:"msgtype,attr"`
+	Items   []xmlItem `xml:",any"`
+}
+
+type xmlComponent struct {
+	Name  string    `xml:"name,attr"`
+	Items []xmlItem `xml:",any"`
+}
+
+// 报文、组件和重复组中的field、component、group元素
+type xmlItem struct {
+	XMLName xml.Name
+	Name    string    `xml:"name,attr"`
+	Items   []xmlItem `xml:",any"`
+}
+
+type xmlField struct {
+	Number string     `xml:"number,attr"`
+	Name   string     `xml:"name,attr"`
+	Type   string     `xml:"type,attr"`
+	Values []xmlValue `xml:"value"`
+}
+
+type xmlValue struct {
+	Enum        string `xml:"enum,attr"`
+	Description string `xml:"description,attr"`
+}
+
+// New 返回空字典
+func New() *Dictionary {
+	return &Dictionary{fields: make(map[string]*Field), tags: make(map[string]string), messages: make(map[string]string), header: make(map[string]bool), trailer: make(map[string]bool), groups: make(map[string][]string)}
+}
+
+// Default 返回加载了内置字典的新字典
+func Default() *Dictionary {
+	d := New()
+	if err := d.Load(bytes.NewReader(defaultXML)); err != nil {
+		panic(fmt.Sprintf("fixdict: invalid built-in dictionary: %v", err))
+	}
+	return d
+}
+
+// Load 加载一个QuickFIX XML字典。已有的字段被同标签号的定义覆盖，枚举值合并，
+// 因此只含自定义标签或补充枚举值的小字典可叠加在标准字典之上
+func (d *Dictionary) Load(r io.Reader) error {
+	var x xmlDictionary
+	if err := xml.NewDecoder(r).Decode(&x); err != nil {
+		return fmt.Errorf("error parsing dictionary: %v", err)
+	}
+	// FIX 5.0的标准头、标准尾在FIXT11.xml中，FIX50.xml中为空，因此取并集
+	for _, ref := range x.Header {
+		d.header[ref.Name] = true
+	}
+	for _, group := range x.HeaderGroups {
+		d.header[group.Name] = true
+	}
+	for _, ref := range x.Trailer {
+		d.trailer[ref.Name] = true
+	}
+	for _, m := range x.Messages {
+		if m.MsgType == "" {
+			return fmt.Errorf("error parsing dictionary: message %q has no msgtype", m.Name)
+		}
+		d.messages[m.MsgType] = m.Name
+	}
+	for _, f := range x.Fields {
+		if f.Number == "" {
+			return fmt.Errorf("error parsing dictionary: field %q has no number", f.Name)
+		}
+		field, ok := d.fields[f.Number]
+		if !ok {
+			field = &Field{Number: f.Number, Values: make(map[string]string)}
+			d.fields[f.Number] = field
+		}
+		if f.Name != "" {
+			if d.tags[field.Name] == field.Number {
+				delete(d.tags, field.Name)
+			}
+			field.Name = f.Name
+			d.tags[f.Name] = f.Number
+		}
+		if f.Type != "" {
+			field.Type = f.Type
+		}
+		for _, v := range f.Values {
+			field.Values[v.Enum] = v.Description
+		}
+	}
+
+	// 重复组按NumInGroup字段登记，不区分所在的报文；字段名需在字段定义之后解析
+	components := make(map[string][]xmlItem)
+	for _, c := range x.Components {
+		components[c.Name] = c.Items
+	}
+	groups := &groupLoader{d: d, components: components}
+	groups.walk(x.HeaderGroups, 0)
+	for _, m := range x.Messages {
+		groups.walk(m.Items, 0)
+	}
+	for _, c := range x.Components {
+		groups.walk(c.Items, 0)
+	}
+	return nil
+}
+
+// 组件可以互相引用，展开时限制深度，避免循环引用
+const maxComponentDepth = 32
+
+type groupLoader struct {
+	d          *Dictionary
+	components map[string][]xmlItem
+}
+
+// 登记items中的全部重复组
+func (g *groupLoader) walk(items []xmlItem, depth int) {
+	if depth > maxComponentDepth {
+		return
+	}
+	for _, item := range items {
+		if item.XMLName.Local == "group" {
+			g.define(item)
+			g.walk(item.Items, depth+1)
+		}
+	}
+}
+
+func (g *groupLoader) define(group xmlItem) {
+	tag, ok := g.d.tags[group.Name]
+	if !ok {
+		return
+	}
+	members := g.d.groups[tag]
+	for _, member := range g.members(group.Items, 0) {
+		if !containsTag(members, member) {
+			members = append(members, member)
+		}
+	}
+	if len(members) > 0 {
+		g.d.groups[tag] = members
+	}
+}
+
+// 组内字段的标签号，展开组件；嵌套的重复组只取其NumInGroup字段
+func (g *groupLoader) members(items []xmlItem, depth int) []string {
+	if depth > maxComponentDepth {
+		return nil
+	}
+	var tags []string
+	for _, item := range items {
+		switch item.XMLName.Local {
+		case "field", "group":
+			if tag, ok := g.d.tags[item.Name]; ok {
+				tags = append(tags, tag)
+			}
+		case "component":
+			tags = append(tags, g.members(g.components[item.Name], depth+1)...)
+		}
+	}
+	return tags
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Field 返回标签号对应的字段定义
+func (d *Dictionary) Field(tag string) (*Field, bool) {
+	field, ok := d.fields[tag]
+	return field, ok
+}
+
+// FieldName 返回字段名，字典中没有的标签返回标签号本身
+func (d *Dictionary) FieldName(tag string) string {
+	if field, ok := d.fields[tag]; ok && field.Name != "" {
+		return field.Name
+	}
+	return tag
+}
+
+// Tag 返回字段名对应的标签号
+func (d *Dictionary) Tag(name string) (string, bool) {
+	tag, ok := d.tags[name]
+	return tag, ok
+}
+
+// Value 返回枚举值的含义，如150=G为TRADE_CORRECT
+func (d *Dictionary) Value(tag string, value string) (string, bool) {
+	field, ok := d.fields[tag]
+	if !ok {
+		return "", false
+	}
+	description, ok := field.Values[value]
+	return description, ok
+}
+
+// MessageName 返回报文类型名，如8为ExecutionReport
+func (d *Dictionary) MessageName(msgType string) (string, bool) {
+	name, ok := d.messages[msgType]
+	return name, ok
+}
+
+// Group 返回以tag为NumInGroup字段的重复组中各字段的标签号，第一个为每个条目开头的分隔字段
+func (d *Dictionary) Group(tag string) ([]string, bool) {
+	members, ok := d.groups[tag]
+	return members, ok
+}
+
+// IsHeader 字段是否属于标准头
+func (d *Dictionary) IsHeader(tag string) bool {
+	return d.header[d.FieldName(tag)]
+}
+
+// IsTrailer 字段是否属于标准尾
+func (d *Dictionary) IsTrailer(tag string) bool {
+	return d.trailer[d.FieldName(tag)]
+}
@@ -0,0 +1,126 @@
+package fixdict
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 一个小的FIX 4.4字典：报文中的重复组、组件中的重复组、嵌套的重复组以及标准头中的重复组
+const groupsXML = `<fix type="FIX" major="4" minor="4">
+  <header>
+    <field name="MsgType" required="Y"/>
+    <group name="NoHops" required="N">
+      <field name="HopCompID" required="N"/>
+      <field name="HopSendingTime" required="N"/>
+    </group>
+  </header>
+  <trailer/>
+  <messages>
+    <message name="NewOrderSingle" msgtype="D" msgcat="app">
+      <field name="ClOrdID" required="Y"/>
+      <component name="Parties" required="N"/>
+      <group name="NoAllocs" required="N">
+        <field name="AllocAccount" required="N"/>
+        <field name="AllocQty" required="N"/>
+      </group>
+    </message>
+    <message name="ExecutionReport" msgtype="8" msgcat="app">
+      <group name="NoAllocs" required="N">
+        <field name="AllocAccount" required="N"/>
+        <field name="IndividualAllocID" required="N"/>
+      </group>
+    </message>
+  </messages>
+  <components>
+    <component name="Parties">
+      <group name="NoPartyIDs" required="N">
+        <field name="PartyID" required="N"/>
+        <field name="PartyRole" required="N"/>
+        <component name="PtysSubGrp" required="N"/>
+      </group>
+    </component>
+    <component name="PtysSubGrp">
+      <group name="NoPartySubIDs" required="N">
+        <field name="PartySubID" required="N"/>
+        <field name="PartySubIDType" required="N"/>
+      </group>
+    </component>
+    <component name="Loop">
+      <component name="Loop" required="N"/>
+    </component>
+  </components>
+  <fields>
+    <field number="11" name="ClOrdID" type="STRING"/>
+    <field number="35" name="MsgType" type="STRING"/>
+    <field number="78" name="NoAllocs" type="NUMINGROUP"/>
+    <field number="79" name="AllocAccount" type="STRING"/>
+    <field number="80" name="AllocQty" type="QTY"/>
+    <field number="467" name="IndividualAllocID" type="STRING"/>
+    <field number="448" name="PartyID" type="STRING"/>
+    <field number="452" name="PartyRole" type="INT"/>
+    <field number="453" name="NoPartyIDs" type="NUMINGROUP"/>
+    <field number="523" name="PartySubID" type="STRING"/>
+    <field number="802" name="NoPartySubIDs" type="NUMINGROUP"/>
+    <field number="803" name="PartySubIDType" type="INT"/>
+    <field number="627" name="NoHops" type="NUMINGROUP"/>
+    <field number="628" name="HopCompID" type="STRING"/>
+    <field number="629" name="HopSendingTime" type="UTCTIMESTAMP"/>
+  </fields>
+</fix>`
+
+func TestLoadGroups(t *testing.T) {
+	d := New()
+	if err := d.Load(strings.NewReader(groupsXML)); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tag     string
+		members []string
+	}{
+		{"627", []string{"628", "629"}},
+		{"453", []string{"448", "452", "802"}},
+		{"802", []string{"523", "803"}},
+		// 不同报文中的同一重复组取字段的并集
+		{"78", []string{"79", "80", "467"}},
+	}
+	for _, tt := range tests {
+		members, ok := d.Group(tt.tag)
+		if !ok || !reflect.DeepEqual(members, tt.members) {
+			t.Errorf("Group(%s) = %v, %v, want %v", tt.tag, members, ok, tt.members)
+		}
+	}
+	for _, tag := range []string{"11", "448", "9999"} {
+		if _, ok := d.Group(tag); ok {
+			t.Errorf("Group(%s) should not be a group", tag)
+		}
+	}
+	if !d.IsHeader("35") || !d.IsHeader("627") || d.IsHeader("453") {
+		t.Errorf("wrong header fields")
+	}
+}
+
+func TestDefault(t *testing.T) {
+	d := Default()
+	tests := []struct {
+		tag, name string
+	}{
+		{"35", "MsgType"},
+		{"150", "ExecType"},
+		{"9999", "9999"},
+	}
+	for _, tt := range tests {
+		if got := d.FieldName(tt.tag); got != tt.name {
+			t.Errorf("FieldName(%s) = %s, want %s", tt.tag, got, tt.name)
+		}
+	}
+	if tag, ok := d.Tag("ExecType"); !ok || tag != "150" {
+		t.Errorf("Tag(ExecType) = %s, %v", tag, ok)
+	}
+	if name, ok := d.MessageName("8"); !ok || name != "ExecutionReport" {
+		t.Errorf("MessageName(8) = %s, %v", name, ok)
+	}
+	if !d.IsHeader("49") || d.IsHeader("11") || !d.IsTrailer("10") {
+		t.Errorf("wrong header or trailer fields")
+	}
+}
@@ -0,0 +1,37 @@
+package logline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// WriteJsonl 按顺序将records逐行写入jsonl文件
+func WriteJsonl[T any](jsonlFilename string, records []T) error {
+	file, err := os.Create(jsonlFilename)
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, record := range records {
+		jsonBytes, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("error marshalling to JSON: %v", err)
+		}
+		if _, err := w.Write(jsonBytes); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+		// 写入换行符以满足jsonl格式要求
+		if err := w.WriteByte('\n'); err != nil {
+			return fmt.Errorf("error writing newline to file: %v", err)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package logline 解析OMS/撮合引擎日志行：前缀时间、收发方向及'|'分隔的FIX标签。
+package logline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// TimeLayout 日志前缀时间格式
+const TimeLayout = "01/02/2006 15:04:05.000000"
+
+// FixTimestampLayout FIX UTCTimestamp格式(52/60等)，小数秒部分(毫秒/微秒/纳秒)解析时自动识别
+const FixTimestampLayout = "20060102-15:04:05"
+
+var reTime = regexp.MustCompile(`^D\d{4} (\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}\.\d{6})`)
+
+// Scanner 逐行读取日志，并将每一行中的'\x01'替换为'|'
+type Scanner struct {
+	scanner *bufio.Scanner
+	text    string
+	lineNo  int
+}
+
+func NewScanner(r io.Reader) *Scanner {
+	scanner := bufio.NewScanner(r)
+	// 部分应用日志行较长，放宽默认的64KB限制
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	return &Scanner{scanner: scanner}
+}
+
+func (s *Scanner) Scan() bool {
+	if !s.scanner.Scan() {
+		return false
+	}
+	s.lineNo += 1
+	s.text = strings.Replace(s.scanner.Text(), "\x01", "|", -1)
+	return true
+}
+
+// Text 当前行，'\x01'已替换为'|'
+func (s *Scanner) Text() string {
+	return s.text
+}
+
+// LineNo 当前行号，从1开始
+func (s *Scanner) LineNo() int {
+	return s.lineNo
+}
+
+func (s *Scanner) Err() error {
+	return s.scanner.Err()
+}
+
+// Time 返回日志行的前缀时间
+func Time(line string) (string, bool) {
+	matches := reTime.FindStringSubmatch(line)
+	if len(matches) < 2 {
+		return "", false
+	}
+	return matches[1], true
+}
+
+// Tag 返回FIX标签的值，需完整匹配"|tag=...|"，避免11=误匹配到111=
+func Tag(line string, tag string) (string, bool) {
+	key := "|" + tag + "="
+	for {
+		i := strings.Index(line, key)
+		if i < 0 {
+			return "", false
+		}
+		rest := line[i+len(key):]
+		j := strings.IndexByte(rest, '|')
+		if j < 0 {
+			return "", false
+		}
+		if j > 0 {
+			return rest[:j], true
+		}
+		// 空值跳过，继续查找后面的同名标签
+		line = rest
+	}
+}
+
+// Field 一个FIX字段
+type Field struct {
+	Tag   string
+	Value string
+}
+
+// Fields 按出现顺序返回日志行中FIX报文的全部字段，从8=FIX开始
+func Fields(line string) []Field {
+	i := strings.Index(line, "8=FIX")
+	if i < 0 {
+		return nil
+	}
+	var fields []Field
+	for _, token := range strings.Split(line[i:], "|") {
+		tag, value, ok := strings.Cut(token, "=")
+		if !ok || tag == "" {
+			continue
+		}
+		fields = append(fields, Field{tag, value})
+	}
+	return fields
+}
+
+// IsFix 日志行是否包含FIX报文
+func IsFix(line string) bool {
+	return strings.Contains(line, "8=FIX")
+}
+
+// Direction 返回日志行记录的收发方向("send"或"recv")，只看FIX报文之前的部分
+func Direction(line string) string {
+	prefix := line
+	if i := strings.Index(line, "8=FIX"); i >= 0 {
+		prefix = line[:i]
+	}
+	switch {
+	case strings.Contains(prefix, "recv"):
+		return "recv"
+	case strings.Contains(prefix, "send"):
+		return "send"
+	}
+	return ""
+}
+
+// ParseTime 解析日志前缀时间
+func ParseTime(value string) (time.Time, error) {
+	return time.Parse(TimeLayout, value)
+}
+
+// Unmarshal 按结构体字段的`fix`标签从日志行取值："time"为前缀时间，其余为FIX标签号。
+// 任一字段缺失时返回"<字段名> not found"。
+func Unmarshal(line string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("unmarshal target must be a pointer to struct")
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("fix")
+		if tag == "" {
+			continue
+		}
+
+		var value string
+		var ok bool
+		if tag == "time" {
+			value, ok = Time(line)
+		} else {
+			value, ok = Tag(line, tag)
+		}
+		if !ok {
+			return fmt.Errorf("%s not found", rt.Field(i).Name)
+		}
+		rv.Field(i).SetString(value)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Package metrics 以Prometheus文本格式或OpenMetrics格式输出计数器和直方图，供抓取或textfile collector读取。
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry 指标集合，并发安全
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]family
+}
+
+type family interface {
+	write(w *bufio.Writer, openMetrics bool)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]family)}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.families[name]; exists {
+		panic("metrics: duplicate metric " + name)
+	}
+	r.families[name] = f
+}
+
+// 标签值以'\xff'拼接作为序列的键
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func formatLabels(names []string, values []string, extra ...string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escapeLabel(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter 带标签的计数器，输出时名称加_total后缀
+type Counter struct {
+	r          *Registry
+	name, help string
+	labelNames []string
+	series     map[string]*counterSeries
+}
+
+type counterSeries struct {
+	labelValues []string
+	value       float64
+}
+
+func (r *Registry) NewCounter(name string, help string, labelNames ...string) *Counter {
+	c := &Counter{r: r, name: name, help: help, labelNames: labelNames, series: make(map[string]*counterSeries)}
+	r.register(name, c)
+	return c
+}
+
+func (c *Counter) get(labelValues []string) *counterSeries {
+	key := seriesKey(labelValues)
+	s, exists := c.series[key]
+	if !exists {
+		s = &counterSeries{labelValues: append([]string(nil), labelValues...)}
+		c.series[key] = s
+	}
+	return s
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.get(labelValues).value += delta
+}
+
+// Set 直接设置计数值，用于同步其他地方维护的累计值
+func (c *Counter) Set(value float64, labelValues ...string) {
+	c.r.mu.Lock()
+	defer c.r.mu.Unlock()
+	c.get(labelValues).value = value
+}
+
+func (c *Counter) write(w *bufio.Writer, openMetrics bool) {
+	// OpenMetrics的指标族名不含_total，Prometheus文本格式的TYPE行需与样本名一致
+	familyName := c.name + "_total"
+	if openMetrics {
+		familyName = c.name
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", familyName, c.help, familyName)
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s_total%s %s\n", c.name, formatLabels(c.labelNames, s.labelValues), formatFloat(s.value))
+	}
+}
+
+// Histogram 带标签的直方图
+type Histogram struct {
+	r          *Registry
+	name, help string
+	labelNames []string
+	buckets    []float64
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // 各桶(不累计)的样本数，最后一个为+Inf
+	sum         float64
+	count       uint64
+}
+
+// NewHistogram buckets为升序的上界，+Inf桶自动追加
+func (r *Registry) NewHistogram(name string, help string, buckets []float64, labelNames ...string) *Histogram {
+	h := &Histogram{r: r, name: name, help: help, labelNames: labelNames, buckets: buckets, series: make(map[string]*histogramSeries)}
+	r.register(name, h)
+	return h
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.r.mu.Lock()
+	defer h.r.mu.Unlock()
+	key := seriesKey(labelValues)
+	s, exists := h.series[key]
+	if !exists {
+		s = &histogramSeries{labelValues: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets)+1)}
+		h.series[key] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, value)] += 1
+	s.sum += value
+	s.count += 1
+}
+
+func (h *Histogram) write(w *bufio.Writer, openMetrics bool) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, count := range s.counts {
+			cumulative += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(h.labelNames, s.labelValues, "le", formatFloat(le)), cumulative)
+		}
+		labels := formatLabels(h.labelNames, s.labelValues)
+		fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, labels, formatFloat(s.sum), h.name, labels, s.count)
+	}
+}
+
+// Write 按指标名顺序输出；openMetrics为true时输出OpenMetrics格式(以# EOF结尾)，否则为Prometheus文本格式
+func (r *Registry) Write(w io.Writer, openMetrics bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, name := range sortedKeys(r.families) {
+		r.families[name].write(bw, openMetrics)
+	}
+	if openMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// WriteFile 以Prometheus文本格式写入textfile collector目录下的文件；先写临时文件再改名，避免被读到写了一半的内容
+func (r *Registry) WriteFile(filename string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return fmt.Errorf("error creating metrics file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := r.Write(tmp, false); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing metrics file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing metrics file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error writing metrics file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return fmt.Errorf("error writing metrics file: %v", err)
+	}
+	return nil
+}
+
+// Handler 按Accept头选择OpenMetrics或Prometheus文本格式
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		openMetrics := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+		if openMetrics {
+			w.Header().Set("Content-Type", "application/openmetrics-text; version=1.0.0; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		}
+		r.Write(w, openMetrics)
+	})
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestRegistry() *Registry {
+	r := NewRegistry()
+	orders := r.NewCounter("oms_orders", "Completed orders.", "account")
+	orders.Add(2, "ACC1")
+	orders.Add(1, `A"\`)
+	r.NewCounter("oms_lines", "Log lines read.").Set(42)
+
+	latency := r.NewHistogram("oms_latency_seconds", "Latency.", []float64{0.001, 0.01, 0.1}, "stage")
+	for _, v := range []float64{0.0005, 0.001, 0.005, 0.05, 2} {
+		latency.Observe(v, "Total")
+	}
+	return r
+}
+
+const wantPrometheus = `# HELP oms_latency_seconds Latency.
+# TYPE oms_latency_seconds histogram
+oms_latency_seconds_bucket{stage="Total",le="0.001"} 2
+oms_latency_seconds_bucket{stage="Total",le="0.01"} 3
+oms_latency_seconds_bucket{stage="Total",le="0.1"} 4
+oms_latency_seconds_bucket{stage="Total",le="+Inf"} 5
+oms_latency_seconds_sum{stage="Total"} 2.0565
+oms_latency_seconds_count{stage="Total"} 5
+# HELP oms_lines_total Log lines read.
+# TYPE oms_lines_total counter
+oms_lines_total 42
+# HELP oms_orders_total Completed orders.
+# TYPE oms_orders_total counter
+oms_orders_total{account="A\"\\"} 1
+oms_orders_total{account="ACC1"} 2
+`
+
+// OpenMetrics的计数器族名不含_total，样本名仍带_total，并以# EOF结尾
+const wantOpenMetrics = `# HELP oms_latency_seconds Latency.
+# TYPE oms_latency_seconds histogram
+oms_latency_seconds_bucket{stage="Total",le="0.001"} 2
+oms_latency_seconds_bucket{stage="Total",le="0.01"} 3
+oms_latency_seconds_bucket{stage="Total",le="0.1"} 4
+oms_latency_seconds_bucket{stage="Total",le="+Inf"} 5
+oms_latency_seconds_sum{stage="Total"} 2.0565
+oms_latency_seconds_count{stage="Total"} 5
+# HELP oms_lines Log lines read.
+# TYPE oms_lines counter
+oms_lines_total 42
+# HELP oms_orders Completed orders.
+# TYPE oms_orders counter
+oms_orders_total{account="A\"\\"} 1
+oms_orders_total{account="ACC1"} 2
+# EOF
+`
+
+func TestWrite(t *testing.T) {
+	r := newTestRegistry()
+	for _, tt := range []struct {
+		openMetrics bool
+		want        string
+	}{
+		{false, wantPrometheus},
+		{true, wantOpenMetrics},
+	} {
+		var b strings.Builder
+		if err := r.Write(&b, tt.openMetrics); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("openMetrics %v:\n got:\n%s\nwant:\n%s", tt.openMetrics, b.String(), tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := newTestRegistry().Handler()
+	for _, tt := range []struct {
+		accept      string
+		contentType string
+		want        string
+	}{
+		{"", "text/plain; version=0.0.4; charset=utf-8", wantPrometheus},
+		{"application/openmetrics-text; version=1.0.0,text/plain;q=0.5", "application/openmetrics-text; version=1.0.0; charset=utf-8", wantOpenMetrics},
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+			t.Errorf("Accept %q: Content-Type %q, want %q", tt.accept, got, tt.contentType)
+		}
+		if rec.Body.String() != tt.want {
+			t.Errorf("Accept %q: body\n%s", tt.accept, rec.Body.String())
+		}
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "v8.prom")
+	if err := newTestRegistry().WriteFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != wantPrometheus {
+		t.Errorf("file content:\n%s", data)
+	}
+	// 临时文件已改名，目录中只有目标文件
+	if entries, _ := os.ReadDir(filepath.Dir(filename)); len(entries) != 1 {
+		t.Errorf("%d files in the textfile directory, want 1", len(entries))
+	}
+}
@@ -0,0 +1,140 @@
+// Package otlp 按OTLP/JSON编码span，写入文件(每行一个ExportTraceServiceRequest)或通过OTLP/HTTP推送到collector。
+package otlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// SpanKind 与OTLP的枚举值一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// KeyValue 字符串属性
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// Span 一个span；ParentSpanID全为0表示根span
+type Span struct {
+	TraceID      [16]byte
+	SpanID       [8]byte
+	ParentSpanID [8]byte
+	Name         string
+	Kind         SpanKind
+	Start, End   time.Time
+	Attributes   []KeyValue
+}
+
+// 以下为OTLP/JSON的结构：ID为十六进制字符串，64位整数为十进制字符串
+
+type jsonAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type jsonKeyValue struct {
+	Key   string       `json:"key"`
+	Value jsonAnyValue `json:"value"`
+}
+
+type jsonSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []jsonKeyValue `json:"attributes,omitempty"`
+}
+
+type jsonScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type jsonScopeSpans struct {
+	Scope jsonScope  `json:"scope"`
+	Spans []jsonSpan `json:"spans"`
+}
+
+type jsonResource struct {
+	Attributes []jsonKeyValue `json:"attributes"`
+}
+
+type jsonResourceSpans struct {
+	Resource   jsonResource     `json:"resource"`
+	ScopeSpans []jsonScopeSpans `json:"scopeSpans"`
+}
+
+type jsonRequest struct {
+	ResourceSpans []jsonResourceSpans `json:"resourceSpans"`
+}
+
+func jsonAttributes(attributes []KeyValue) []jsonKeyValue {
+	var result []jsonKeyValue
+	for _, attribute := range attributes {
+		result = append(result, jsonKeyValue{attribute.Key, jsonAnyValue{attribute.Value}})
+	}
+	return result
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// MarshalRequest 将spans编码为一个OTLP/JSON的ExportTraceServiceRequest
+func MarshalRequest(resource []KeyValue, scopeName string, scopeVersion string, spans []Span) ([]byte, error) {
+	scopeSpans := jsonScopeSpans{Scope: jsonScope{scopeName, scopeVersion}}
+	for _, span := range spans {
+		s := jsonSpan{
+			TraceID:           hex.EncodeToString(span.TraceID[:]),
+			SpanID:            hex.EncodeToString(span.SpanID[:]),
+			Name:              span.Name,
+			Kind:              span.Kind,
+			StartTimeUnixNano: unixNano(span.Start),
+			EndTimeUnixNano:   unixNano(span.End),
+			Attributes:        jsonAttributes(span.Attributes),
+		}
+		if span.ParentSpanID != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(span.ParentSpanID[:])
+		}
+		scopeSpans.Spans = append(scopeSpans.Spans, s)
+	}
+
+	request := jsonRequest{[]jsonResourceSpans{{
+		Resource:   jsonResource{jsonAttributes(resource)},
+		ScopeSpans: []jsonScopeSpans{scopeSpans},
+	}}}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %v", err)
+	}
+	return body, nil
+}
+
+// Post 以OTLP/HTTP JSON推送一个请求，如http://localhost:4318/v1/traces
+func Post(client *http.Client, url string, body []byte) error {
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error pushing traces: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("error pushing traces: %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
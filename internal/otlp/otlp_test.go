@@ -0,0 +1,77 @@
+package otlp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalRequest(t *testing.T) {
+	var traceID [16]byte
+	for i := range traceID {
+		traceID[i] = byte(i)
+	}
+	root := Span{
+		TraceID: traceID,
+		SpanID:  [8]byte{0xa0, 0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7},
+		Name:    "order",
+		Kind:    SpanKindServer,
+		Start:   time.Date(2024, 4, 11, 0, 0, 0, 600000123, time.UTC),
+		End:     time.Date(2024, 4, 11, 0, 0, 0, 625075000, time.UTC),
+		Attributes: []KeyValue{
+			{Key: "fix.cl_ord_id", Value: "C0"},
+		},
+	}
+	// 时区不影响Unix时间
+	jst := time.FixedZone("JST", 9*60*60)
+	child := Span{
+		TraceID:      traceID,
+		SpanID:       [8]byte{0xb0, 0, 0, 0, 0, 0, 0, 0x01},
+		ParentSpanID: root.SpanID,
+		Name:         "matching",
+		Kind:         SpanKindClient,
+		Start:        time.Date(2024, 4, 11, 9, 0, 0, 605100000, jst),
+		End:          time.Date(2024, 4, 11, 9, 0, 0, 605598000, jst),
+	}
+
+	body, err := MarshalRequest([]KeyValue{{Key: "service.name", Value: "oms"}}, "v8", "1.2.3", []Span{root, child})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"oms"}}]},"scopeSpans":[{"scope":{"name":"v8","version":"1.2.3"},"spans":[` +
+		`{"traceId":"000102030405060708090a0b0c0d0e0f","spanId":"a0a1a2a3a4a5a6a7","name":"order","kind":2,"startTimeUnixNano":"1712793600600000123","endTimeUnixNano":"1712793600625075000","attributes":[{"key":"fix.cl_ord_id","value":{"stringValue":"C0"}}]},` +
+		`{"traceId":"000102030405060708090a0b0c0d0e0f","spanId":"b000000000000001","parentSpanId":"a0a1a2a3a4a5a6a7","name":"matching","kind":3,"startTimeUnixNano":"1712793600605100000","endTimeUnixNano":"1712793600605598000"}` +
+		`]}]}]}`
+	if string(body) != want {
+		t.Errorf("\n got %s\nwant %s", body, want)
+	}
+}
+
+func TestPost(t *testing.T) {
+	var contentType, received string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(status)
+		io.WriteString(w, "bad request body\n")
+	}))
+	defer server.Close()
+
+	if err := Post(server.Client(), server.URL+"/v1/traces", []byte(`{"resourceSpans":[]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "application/json" || received != `{"resourceSpans":[]}` {
+		t.Errorf("collector received %q %s", contentType, received)
+	}
+
+	status = http.StatusBadRequest
+	err := Post(server.Client(), server.URL+"/v1/traces", []byte(`{}`))
+	if err == nil || !strings.Contains(err.Error(), "400 Bad Request: bad request body") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package parquet
+
+import (
+	"encoding/binary"
+)
+
+// 按RLE/bit-packing混合编码写出values，用于定义级别和字典索引。
+// 连续8个以上相同的值写成RLE游程，其余每8个一组按bitWidth位紧密打包
+func appendHybrid(dst []byte, values []uint32, bitWidth int) []byte {
+	runAt := func(i int) int {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		return j - i
+	}
+
+	for i := 0; i < len(values); {
+		if run := runAt(i); run >= 8 {
+			dst = binary.AppendUvarint(dst, uint64(run)<<1)
+			for b := 0; b < (bitWidth+7)/8; b++ {
+				dst = append(dst, byte(values[i]>>(8*b)))
+			}
+			i += run
+			continue
+		}
+
+		// 打包到下一个可以按8对齐切换为RLE的位置；只有最后一组可能补0
+		start := i
+		for i < len(values) {
+			if (i-start)%8 == 0 && i > start && runAt(i) >= 8 {
+				break
+			}
+			i++
+		}
+		groups := (i - start + 7) / 8
+		dst = binary.AppendUvarint(dst, uint64(groups)<<1|1)
+		packed := make([]byte, groups*bitWidth)
+		for j, v := range values[start:i] {
+			for b := 0; b < bitWidth; b++ {
+				if v&(1<<b) != 0 {
+					bit := j*bitWidth + b
+					packed[bit/8] |= 1 << (bit % 8)
+				}
+			}
+		}
+		dst = append(dst, packed...)
+	}
+	return dst
+}
@@ -0,0 +1,365 @@
+// Package parquet 写出Apache Parquet文件：单层schema、不压缩，每个行组中每列一个数据页(v1)，字符串列可用字典编码。
+package parquet
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// Type 列类型
+type Type int
+
+const (
+	Int64      Type = iota // INT64
+	Timestamp              // INT64，TIMESTAMP(NANOS, UTC)，值为Unix纳秒
+	String                 // BYTE_ARRAY(UTF8)，PLAIN编码
+	DictString             // BYTE_ARRAY(UTF8)，字典编码，适合账户、合约等取值较少的列
+)
+
+// Column 列定义
+type Column struct {
+	Name     string
+	Type     Type
+	Optional bool // 可为空，Write时对应的值为nil
+}
+
+// 每个行组的行数，达到后写出行组以限制内存占用
+const rowGroupRows = 128 * 1024
+
+// Parquet枚举值
+const (
+	physicalInt64     = 2
+	physicalByteArray = 6
+
+	repetitionRequired = 0
+	repetitionOptional = 1
+
+	convertedUTF8 = 0
+
+	encodingPlain           = 0
+	encodingPlainDictionary = 2
+	encodingRLE             = 3
+
+	pageData       = 0
+	pageDictionary = 2
+)
+
+var magic = []byte("PAR1")
+
+// 一列在当前行组中缓存的值
+type columnBuffer struct {
+	defs    []uint32 // 定义级别，1为有值，0为空
+	ints    []int64
+	strings []string
+}
+
+// 已写出的列块的元数据
+type columnChunk struct {
+	offset           int64
+	dataPageOffset   int64
+	dictionaryOffset int64 // 无字典页时为-1
+	size             int64
+	numValues        int64
+	encodings        []int32
+}
+
+type rowGroup struct {
+	chunks []columnChunk
+	rows   int64
+	size   int64
+}
+
+// Writer 按行写入，Close时写出最后一个行组和文件尾的元数据
+type Writer struct {
+	w         *bufio.Writer
+	offset    int64
+	columns   []Column
+	buffers   []columnBuffer
+	rows      int
+	rowGroups []rowGroup
+	createdBy string
+}
+
+// NewWriter 写出文件头；createdBy记录在元数据中，如"v8 0.1.0"
+func NewWriter(w io.Writer, columns []Column, createdBy string) (*Writer, error) {
+	pw := &Writer{w: bufio.NewWriter(w), columns: columns, buffers: make([]columnBuffer, len(columns)), createdBy: createdBy}
+	if err := pw.write(magic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *Writer) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing parquet file: %v", err)
+	}
+	return nil
+}
+
+// Write 写入一行，values与列一一对应：Int64/Timestamp列为int64，字符串列为string，空值为nil
+func (pw *Writer) Write(values ...any) error {
+	if len(values) != len(pw.columns) {
+		return fmt.Errorf("expected %d values, got %d", len(pw.columns), len(values))
+	}
+	// 先校验整行，避免写入半行
+	for i, value := range values {
+		column := pw.columns[i]
+		var ok bool
+		switch column.Type {
+		case Int64, Timestamp:
+			_, ok = value.(int64)
+		case String, DictString:
+			_, ok = value.(string)
+		}
+		if value == nil {
+			ok = column.Optional
+		}
+		if !ok {
+			return fmt.Errorf("invalid value %#v for column %s", value, column.Name)
+		}
+	}
+
+	for i, value := range values {
+		buffer := &pw.buffers[i]
+		if value == nil {
+			buffer.defs = append(buffer.defs, 0)
+			continue
+		}
+		buffer.defs = append(buffer.defs, 1)
+		switch v := value.(type) {
+		case int64:
+			buffer.ints = append(buffer.ints, v)
+		case string:
+			buffer.strings = append(buffer.strings, v)
+		}
+	}
+
+	pw.rows += 1
+	if pw.rows >= rowGroupRows {
+		return pw.flushRowGroup()
+	}
+	return nil
+}
+
+func (pw *Writer) flushRowGroup() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	group := rowGroup{rows: int64(pw.rows)}
+	for i, column := range pw.columns {
+		chunk, err := pw.writeColumnChunk(column, &pw.buffers[i], pw.rows)
+		if err != nil {
+			return err
+		}
+		group.chunks = append(group.chunks, chunk)
+		group.size += chunk.size
+		pw.buffers[i] = columnBuffer{}
+	}
+	pw.rowGroups = append(pw.rowGroups, group)
+	pw.rows = 0
+	return nil
+}
+
+func appendPlainString(dst []byte, s string) []byte {
+	dst = binary.LittleEndian.AppendUint32(dst, uint32(len(s)))
+	return append(dst, s...)
+}
+
+func (pw *Writer) writeColumnChunk(column Column, buffer *columnBuffer, rows int) (columnChunk, error) {
+	chunk := columnChunk{offset: pw.offset, dictionaryOffset: -1, numValues: int64(rows)}
+
+	var body []byte
+	if column.Optional {
+		defs := appendHybrid(nil, buffer.defs, 1)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(defs)))
+		body = append(body, defs...)
+	}
+
+	encoding := int32(encodingPlain)
+	switch {
+	case column.Type == DictString && len(buffer.strings) > 0:
+		// 字典按首次出现的顺序编号
+		index := make(map[string]uint32)
+		var dictionary []byte
+		indices := make([]uint32, len(buffer.strings))
+		for i, s := range buffer.strings {
+			id, ok := index[s]
+			if !ok {
+				id = uint32(len(index))
+				index[s] = id
+				dictionary = appendPlainString(dictionary, s)
+			}
+			indices[i] = id
+		}
+
+		chunk.dictionaryOffset = pw.offset
+		header := pageHeader(pageDictionary, len(dictionary), len(index), encodingPlainDictionary)
+		if err := pw.write(header); err != nil {
+			return chunk, err
+		}
+		if err := pw.write(dictionary); err != nil {
+			return chunk, err
+		}
+
+		bitWidth := bits.Len32(uint32(len(index) - 1))
+		if bitWidth == 0 {
+			bitWidth = 1
+		}
+		body = append(body, byte(bitWidth))
+		body = appendHybrid(body, indices, bitWidth)
+		encoding = encodingPlainDictionary
+	case column.Type == Int64 || column.Type == Timestamp:
+		for _, v := range buffer.ints {
+			body = binary.LittleEndian.AppendUint64(body, uint64(v))
+		}
+	default:
+		for _, s := range buffer.strings {
+			body = appendPlainString(body, s)
+		}
+	}
+
+	chunk.dataPageOffset = pw.offset
+	if err := pw.write(pageHeader(pageData, len(body), rows, encoding)); err != nil {
+		return chunk, err
+	}
+	if err := pw.write(body); err != nil {
+		return chunk, err
+	}
+	chunk.size = pw.offset - chunk.offset
+	chunk.encodings = []int32{encoding, encodingRLE}
+	return chunk, nil
+}
+
+func pageHeader(pageType int32, size int, numValues int, encoding int32) []byte {
+	var t thriftWriter
+	t.beginStruct(0)
+	t.i32(1, pageType)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	if pageType == pageDictionary {
+		t.beginStruct(7)
+		t.i32(1, int32(numValues))
+		t.i32(2, encoding)
+		t.endStruct()
+	} else {
+		t.beginStruct(5)
+		t.i32(1, int32(numValues))
+		t.i32(2, encoding)
+		t.i32(3, encodingRLE)
+		t.i32(4, encodingRLE)
+		t.endStruct()
+	}
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+func (pw *Writer) fileMetadata() []byte {
+	var t thriftWriter
+	t.beginStruct(0)
+	t.i32(1, 1)
+
+	t.listHeader(2, thriftStruct, len(pw.columns)+1)
+	t.beginStruct(0)
+	t.string(4, "schema")
+	t.i32(5, int32(len(pw.columns)))
+	t.endStruct()
+	for _, column := range pw.columns {
+		t.beginStruct(0)
+		if column.Type == Int64 || column.Type == Timestamp {
+			t.i32(1, physicalInt64)
+		} else {
+			t.i32(1, physicalByteArray)
+		}
+		if column.Optional {
+			t.i32(3, repetitionOptional)
+		} else {
+			t.i32(3, repetitionRequired)
+		}
+		t.string(4, column.Name)
+		switch column.Type {
+		case String, DictString:
+			t.i32(6, convertedUTF8)
+			t.beginStruct(10)
+			t.emptyStruct(1) // STRING
+			t.endStruct()
+		case Timestamp:
+			t.beginStruct(10)
+			t.beginStruct(8) // TIMESTAMP
+			t.bool(1, true)  // isAdjustedToUTC
+			t.beginStruct(2)
+			t.emptyStruct(3) // NANOS
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		}
+		t.endStruct()
+	}
+
+	var rows int64
+	for _, group := range pw.rowGroups {
+		rows += group.rows
+	}
+	t.i64(3, rows)
+
+	t.listHeader(4, thriftStruct, len(pw.rowGroups))
+	for _, group := range pw.rowGroups {
+		t.beginStruct(0)
+		t.listHeader(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			column := pw.columns[i]
+			t.beginStruct(0)
+			t.i64(2, chunk.offset)
+			t.beginStruct(3)
+			if column.Type == Int64 || column.Type == Timestamp {
+				t.i32(1, physicalInt64)
+			} else {
+				t.i32(1, physicalByteArray)
+			}
+			t.i32List(2, chunk.encodings)
+			t.stringList(3, []string{column.Name})
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.dataPageOffset)
+			if chunk.dictionaryOffset >= 0 {
+				t.i64(11, chunk.dictionaryOffset)
+			}
+			t.endStruct()
+			t.endStruct()
+		}
+		t.i64(2, group.size)
+		t.i64(3, group.rows)
+		t.endStruct()
+	}
+
+	t.string(6, pw.createdBy)
+	t.endStruct()
+	return t.buf.Bytes()
+}
+
+// Close 写出剩余的行组和文件尾，不关闭底层的io.Writer
+func (pw *Writer) Close() error {
+	if err := pw.flushRowGroup(); err != nil {
+		return err
+	}
+	metadata := pw.fileMetadata()
+	if err := pw.write(metadata); err != nil {
+		return err
+	}
+	if err := pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(metadata)))); err != nil {
+		return err
+	}
+	if err := pw.write(magic); err != nil {
+		return err
+	}
+	if err := pw.w.Flush(); err != nil {
+		return fmt.Errorf("error writing parquet file: %v", err)
+	}
+	return nil
+}
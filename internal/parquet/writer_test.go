@@ -0,0 +1,401 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// 读取Thrift compact protocol，结构体解码为字段编号到值的map，整数均为int64
+type thriftReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *thriftReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.err = fmt.Errorf("unexpected end of data at %d", r.pos)
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos += 1
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.err = fmt.Errorf("invalid varint at %d", r.pos)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.varint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftBoolTrue:
+		return true
+	case thriftBoolFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := int(r.varint())
+		if r.pos+n > len(r.data) {
+			r.err = fmt.Errorf("binary of %d bytes at %d exceeds data", n, r.pos)
+			return ""
+		}
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case thriftList:
+		header := r.byte()
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]any, 0, size)
+		for i := 0; i < size && r.err == nil; i++ {
+			list = append(list, r.value(header&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	r.err = fmt.Errorf("unsupported thrift type %d at %d", typ, r.pos)
+	return nil
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	fields := make(map[int16]any)
+	var id int16
+	for r.err == nil {
+		header := r.byte()
+		if header == 0 {
+			break
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+	return fields
+}
+
+func readStruct(t *testing.T, data []byte) (map[int16]any, int) {
+	t.Helper()
+	r := &thriftReader{data: data}
+	fields := r.structure()
+	if r.err != nil {
+		t.Fatalf("error decoding thrift struct: %v", r.err)
+	}
+	return fields, r.pos
+}
+
+// 按RLE/bit-packing混合编码读出count个值
+func decodeHybrid(t *testing.T, data []byte, bitWidth int, count int) []uint32 {
+	t.Helper()
+	var values []uint32
+	for len(values) < count {
+		header, n := binary.Uvarint(data)
+		if n <= 0 {
+			t.Fatalf("invalid hybrid header after %d values", len(values))
+		}
+		data = data[n:]
+		if header&1 == 0 {
+			var v uint32
+			width := (bitWidth + 7) / 8
+			for b := 0; b < width; b++ {
+				v |= uint32(data[b]) << (8 * b)
+			}
+			data = data[width:]
+			for i := 0; i < int(header>>1); i++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		groups := int(header >> 1)
+		packed := data[:groups*bitWidth]
+		data = data[groups*bitWidth:]
+		for j := 0; j < groups*8; j++ {
+			var v uint32
+			for b := 0; b < bitWidth; b++ {
+				bit := j*bitWidth + b
+				if packed[bit/8]&(1<<(bit%8)) != 0 {
+					v |= 1 << b
+				}
+			}
+			values = append(values, v)
+		}
+	}
+	// 最后一组bit-packing可能补了0
+	return values[:count]
+}
+
+func readPlainStrings(t *testing.T, data []byte, count int) []string {
+	t.Helper()
+	var values []string
+	for i := 0; i < count; i++ {
+		n := int(binary.LittleEndian.Uint32(data))
+		values = append(values, string(data[4:4+n]))
+		data = data[4+n:]
+	}
+	return values
+}
+
+// 读出一个列块中的全部值，空值为nil
+func readColumnChunk(t *testing.T, file []byte, column Column, meta map[int16]any, rows int) []any {
+	t.Helper()
+	var dictionary []string
+	if offset, ok := meta[11].(int64); ok {
+		header, n := readStruct(t, file[offset:])
+		if header[1] != int64(pageDictionary) {
+			t.Fatalf("%s: page at dictionary offset has type %v", column.Name, header[1])
+		}
+		dictHeader := header[7].(map[int16]any)
+		if dictHeader[2] != int64(encodingPlainDictionary) {
+			t.Errorf("%s: dictionary encoding %v", column.Name, dictHeader[2])
+		}
+		body := file[offset+int64(n) : offset+int64(n)+header[3].(int64)]
+		dictionary = readPlainStrings(t, body, int(dictHeader[1].(int64)))
+	}
+
+	offset := meta[9].(int64)
+	header, n := readStruct(t, file[offset:])
+	if header[1] != int64(pageData) || header[2] != header[3] {
+		t.Fatalf("%s: unexpected data page header %v", column.Name, header)
+	}
+	dataHeader := header[5].(map[int16]any)
+	if dataHeader[1] != int64(rows) {
+		t.Errorf("%s: data page has %v values, want %d", column.Name, dataHeader[1], rows)
+	}
+	body := file[offset+int64(n) : offset+int64(n)+header[3].(int64)]
+
+	defs := make([]uint32, rows)
+	for i := range defs {
+		defs[i] = 1
+	}
+	if column.Optional {
+		length := binary.LittleEndian.Uint32(body)
+		defs = decodeHybrid(t, body[4:4+length], 1, rows)
+		body = body[4+length:]
+	}
+	defined := 0
+	for _, def := range defs {
+		defined += int(def)
+	}
+
+	var values []any
+	switch encoding := dataHeader[2]; {
+	case encoding == int64(encodingPlainDictionary):
+		for _, index := range decodeHybrid(t, body[1:], int(body[0]), defined) {
+			values = append(values, dictionary[index])
+		}
+	case column.Type == Int64 || column.Type == Timestamp:
+		for i := 0; i < defined; i++ {
+			values = append(values, int64(binary.LittleEndian.Uint64(body[8*i:])))
+		}
+	default:
+		for _, s := range readPlainStrings(t, body, defined) {
+			values = append(values, s)
+		}
+	}
+
+	result := make([]any, rows)
+	for i, def := range defs {
+		if def == 1 {
+			result[i], values = values[0], values[1:]
+		}
+	}
+	return result
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	columns := []Column{
+		{Name: "ID", Type: Int64},
+		{Name: "Qty", Type: Int64, Optional: true},
+		{Name: "Time", Type: Timestamp, Optional: true},
+		{Name: "Note", Type: String, Optional: true},
+		{Name: "Account", Type: DictString, Optional: true},
+		{Name: "Side", Type: DictString},
+		{Name: "Empty", Type: DictString, Optional: true},
+	}
+	// 空值既有连续的长游程，也有与非空值交替出现的，覆盖RLE和bit-packing两种定义级别
+	var rows [][]any
+	for i := 0; i < 40; i++ {
+		row := []any{int64(i), int64(i * 100), int64(1712793600000000000 + i*1000), fmt.Sprintf("note %d", i), []string{"ACC1", "ACC2", "ACC3"}[i%3], "1", nil}
+		if i >= 10 && i < 25 {
+			row[1] = nil
+		}
+		if i%2 == 1 {
+			row[2] = nil
+		}
+		if i%5 == 0 {
+			row[3] = nil
+		}
+		if i >= 30 {
+			row[4] = nil
+		}
+		rows = append(rows, row)
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, columns, "v8 test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err := w.Write(row...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file := buf.Bytes()
+	if !bytes.HasPrefix(file, magic) || !bytes.HasSuffix(file, magic) {
+		t.Fatalf("missing PAR1 magic")
+	}
+	footerLength := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := file[len(file)-8-footerLength : len(file)-8]
+	metadata, n := readStruct(t, footer)
+	if n != footerLength {
+		t.Errorf("footer decoded %d bytes, length says %d", n, footerLength)
+	}
+
+	if metadata[1] != int64(1) || metadata[3] != int64(len(rows)) || metadata[6] != "v8 test" {
+		t.Errorf("version %v, rows %v, created by %v", metadata[1], metadata[3], metadata[6])
+	}
+
+	schema := metadata[2].([]any)
+	if len(schema) != len(columns)+1 {
+		t.Fatalf("schema has %d elements, want %d", len(schema), len(columns)+1)
+	}
+	root := schema[0].(map[int16]any)
+	if root[4] != "schema" || root[5] != int64(len(columns)) {
+		t.Errorf("schema root %v", root)
+	}
+	stringType := map[int16]any{1: map[int16]any{}}
+	timestampType := map[int16]any{8: map[int16]any{1: true, 2: map[int16]any{3: map[int16]any{}}}}
+	for i, column := range columns {
+		element := schema[i+1].(map[int16]any)
+		want := map[int16]any{1: int64(physicalInt64), 3: int64(repetitionRequired), 4: column.Name}
+		if column.Optional {
+			want[3] = int64(repetitionOptional)
+		}
+		switch column.Type {
+		case String, DictString:
+			want[1], want[6], want[10] = int64(physicalByteArray), int64(convertedUTF8), stringType
+		case Timestamp:
+			want[10] = timestampType
+		}
+		if !reflect.DeepEqual(element, want) {
+			t.Errorf("schema of %s = %v, want %v", column.Name, element, want)
+		}
+	}
+
+	rowGroups := metadata[4].([]any)
+	if len(rowGroups) != 1 {
+		t.Fatalf("got %d row groups, want 1", len(rowGroups))
+	}
+	group := rowGroups[0].(map[int16]any)
+	if group[3] != int64(len(rows)) {
+		t.Errorf("row group has %v rows", group[3])
+	}
+	chunks := group[1].([]any)
+	var totalSize int64
+	for i, column := range columns {
+		chunk := chunks[i].(map[int16]any)
+		meta := chunk[3].(map[int16]any)
+		if !reflect.DeepEqual(meta[3], []any{column.Name}) || meta[4] != int64(0) || meta[5] != int64(len(rows)) {
+			t.Errorf("%s: column metadata %v", column.Name, meta)
+		}
+		start := meta[9].(int64)
+		if offset, ok := meta[11].(int64); ok {
+			start = offset
+		}
+		if chunk[2] != start {
+			t.Errorf("%s: file offset %v, want %d", column.Name, chunk[2], start)
+		}
+		totalSize += meta[6].(int64)
+
+		encoding := int64(encodingPlain)
+		if _, hasDictionary := meta[11]; hasDictionary {
+			encoding = encodingPlainDictionary
+		}
+		if !reflect.DeepEqual(meta[2], []any{encoding, int64(encodingRLE)}) {
+			t.Errorf("%s: encodings %v", column.Name, meta[2])
+		}
+
+		got := readColumnChunk(t, file, column, meta, len(rows))
+		for row := range rows {
+			if got[row] != rows[row][i] {
+				t.Errorf("%s row %d = %#v, want %#v", column.Name, row, got[row], rows[row][i])
+			}
+		}
+	}
+	if group[2] != totalSize {
+		t.Errorf("row group size %v, want %d", group[2], totalSize)
+	}
+
+	// 字符串列使用字典编码，全为空的字典列没有字典页
+	for i, hasDictionary := range []bool{false, false, false, false, true, true, false} {
+		meta := chunks[i].(map[int16]any)[3].(map[int16]any)
+		if _, ok := meta[11]; ok != hasDictionary {
+			t.Errorf("%s: dictionary page %v, want %v", columns[i].Name, ok, hasDictionary)
+		}
+	}
+}
+
+func TestWriterRejectsInvalidRow(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{{Name: "ID", Type: Int64}, {Name: "Note", Type: String, Optional: true}}, "v8 test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range [][]any{
+		{int64(1)},
+		{nil, "a"},
+		{"1", "a"},
+		{int64(1), int64(2)},
+	} {
+		if err := w.Write(row...); err == nil {
+			t.Errorf("Write(%#v) should fail", row)
+		}
+	}
+	if w.rows != 0 || len(w.buffers[0].defs) != 0 || len(w.buffers[1].defs) != 0 {
+		t.Errorf("rejected rows were partially written")
+	}
+}
+
+func TestAppendHybrid(t *testing.T) {
+	tests := []struct {
+		values   []uint32
+		bitWidth int
+	}{
+		{[]uint32{1}, 1},
+		{[]uint32{1, 1, 1, 1, 1, 1, 1, 1}, 1},
+		{[]uint32{0, 1, 0, 1, 0, 1, 0, 1, 0}, 1},
+		{[]uint32{0, 1, 2, 3, 3, 3, 3, 3, 3, 3, 3, 3, 3, 4, 5}, 3},
+		{[]uint32{7, 7, 7, 7, 7, 7, 7, 7, 7, 7, 1, 2, 3, 4, 5, 6, 7, 8, 8, 8, 8, 8, 8, 8, 8, 8, 8}, 4},
+		{[]uint32{300, 300, 300, 300, 300, 300, 300, 300, 1, 511}, 9},
+	}
+	for _, tt := range tests {
+		encoded := appendHybrid(nil, tt.values, tt.bitWidth)
+		if got := decodeHybrid(t, encoded, tt.bitWidth, len(tt.values)); !reflect.DeepEqual(got, tt.values) {
+			t.Errorf("appendHybrid(%v, %d) decoded as %v", tt.values, tt.bitWidth, got)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package pcap
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 与testdata/gen.go中的报文相同
+func fixLine(body string) string {
+	head := fmt.Sprintf("8=FIX.4.2\x019=%d\x01", len(body))
+	sum := 0
+	for _, b := range []byte(head + body) {
+		sum += int(b)
+	}
+	return strings.ReplaceAll(fmt.Sprintf("%s%s10=%03d\x01", head, body, sum%256), "\x01", "|")
+}
+
+var (
+	client = "10.0.0.1:40000"
+	oms    = "10.0.0.2:9880"
+	base   = time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)
+)
+
+// 每条报文的时间为其最后一个字节所在帧的抓包时间
+func expectedMessages(fraction time.Duration) []Message {
+	at := func(ms int) time.Time { return base.Add(time.Duration(ms)*time.Millisecond + fraction) }
+	return []Message{
+		{at(2), client, oms, fixLine("35=D\x0149=HRT01\x0156=OMS\x0134=1\x0111=C1\x0155=7203\x01")},
+		{at(2), client, oms, fixLine("35=D\x0149=HRT01\x0156=OMS\x0134=2\x0111=C2\x0155=6758\x01")},
+		// 后半在乱序先到的第3帧中
+		{at(3), client, oms, fixLine("35=D\x0149=HRT01\x0156=OMS\x0134=3\x0111=C3\x0155=9984\x0158=split across segments\x01")},
+		// 第6帧与第3帧重叠，只取其中的新数据
+		{at(6), client, oms, fixLine("35=F\x0149=HRT01\x0156=OMS\x0134=4\x0111=C4\x0141=C3\x01")},
+		{at(7), client, oms, fixLine("35=D\x0149=HRT01\x0156=OMS\x0134=5\x0111=C5\x0155=7203\x01")},
+		{at(9), oms, client, fixLine("35=8\x0149=OMS\x0156=HRT01\x0134=1\x0111=C1\x01150=0\x01")},
+	}
+}
+
+func readMessages(t *testing.T, filename string, ports []uint16) []Message {
+	t.Helper()
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reassembler := NewReassembler(ports)
+	var messages []Message
+	for {
+		packet, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reassembler.Add(packet, func(m Message) { messages = append(messages, m) })
+	}
+	return messages
+}
+
+func TestReassemble(t *testing.T) {
+	tests := []struct {
+		filename string
+		fraction time.Duration // 生成时加在每帧时间上的零头，检验时间戳精度
+	}{
+		{"testdata/stream.pcap", 123 * time.Microsecond},
+		{"testdata/stream.pcapng", 456 * time.Nanosecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			got := readMessages(t, tt.filename, nil)
+			want := expectedMessages(tt.fraction)
+			if len(got) != len(want) {
+				t.Fatalf("got %d messages, want %d: %+v", len(got), len(want), got)
+			}
+			for i := range want {
+				if !got[i].Time.Equal(want[i].Time) {
+					t.Errorf("message %d: time %v, want %v", i+1, got[i].Time.UTC(), want[i].Time)
+				}
+				if got[i].Src != want[i].Src || got[i].Dst != want[i].Dst {
+					t.Errorf("message %d: %s > %s, want %s > %s", i+1, got[i].Src, got[i].Dst, want[i].Src, want[i].Dst)
+				}
+				if got[i].Line != want[i].Line {
+					t.Errorf("message %d:\n got %q\nwant %q", i+1, got[i].Line, want[i].Line)
+				}
+			}
+		})
+	}
+}
+
+func TestReassemblePorts(t *testing.T) {
+	if got := readMessages(t, "testdata/stream.pcap", []uint16{9881}); len(got) != 0 {
+		t.Errorf("got %d messages on an unused port, want 0", len(got))
+	}
+	if got := readMessages(t, "testdata/stream.pcap", []uint16{9880}); len(got) != 6 {
+		t.Errorf("got %d messages on port 9880, want 6", len(got))
+	}
+}
+
+func TestFixMessageLength(t *testing.T) {
+	message := strings.ReplaceAll(fixLine("35=0\x01"), "|", "\x01")
+	tests := []struct {
+		data   string
+		length int
+		ok     bool
+	}{
+		{message, len(message), true},
+		{message + "8=FIX", len(message), true},
+		{message[:len(message)-1], 0, false},
+		{"8=FIX.4.2\x019=", 0, false},
+		{"8=FIX.4.2\x0135=0\x01", -1, false},
+		{"8=FIX.4.2\x019=x\x01", -1, false},
+		{"8=FIX.4.2\x019=5\x0135=0\x0111=000\x01", -1, false},
+	}
+	for _, tt := range tests {
+		length, ok := fixMessageLength([]byte(tt.data))
+		if length != tt.length || ok != tt.ok {
+			t.Errorf("fixMessageLength(%q) = %d, %v, want %d, %v", tt.data, length, ok, tt.length, tt.ok)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		value string
+		ports []uint16
+		err   bool
+	}{
+		{"", nil, false},
+		{"9880", []uint16{9880}, false},
+		{" 9880, 9881 ,", []uint16{9880, 9881}, false},
+		{"9880,x", nil, true},
+		{"70000", nil, true},
+	}
+	for _, tt := range tests {
+		ports, err := ParsePorts(tt.value)
+		if (err != nil) != tt.err || !tt.err && !reflect.DeepEqual(ports, tt.ports) {
+			t.Errorf("ParsePorts(%q) = %v, %v", tt.value, ports, err)
+		}
+	}
+}
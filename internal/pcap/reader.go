@@ -0,0 +1,234 @@
+// Package pcap 读取pcap/pcapng抓包文件，重组TCP流并切分出其中的FIX报文。
+package pcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// Packet 抓包文件中的一帧
+type Packet struct {
+	Time     time.Time
+	LinkType uint32
+	Data     []byte
+}
+
+// Reader 按抓包顺序读取帧，读完时返回io.EOF
+type Reader interface {
+	Next() (Packet, error)
+}
+
+// NewReader 根据文件头的magic识别pcap或pcapng
+func NewReader(r io.Reader) (Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("error reading capture header: %v", err)
+	}
+
+	switch binary.LittleEndian.Uint32(magic) {
+	case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1:
+		return newPcapReader(br)
+	case 0x0a0d0d0a:
+		return &pcapngReader{r: br}, nil
+	}
+	return nil, fmt.Errorf("unknown capture file format, magic %x", magic)
+}
+
+type pcapReader struct {
+	r        io.Reader
+	order    binary.ByteOrder
+	nano     bool
+	linkType uint32
+	header   [16]byte
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	var header [24]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, fmt.Errorf("error reading pcap header: %v", err)
+	}
+
+	reader := &pcapReader{r: r}
+	switch binary.LittleEndian.Uint32(header[0:4]) {
+	case 0xa1b2c3d4:
+		reader.order = binary.LittleEndian
+	case 0xd4c3b2a1:
+		reader.order = binary.BigEndian
+	case 0xa1b23c4d:
+		reader.order, reader.nano = binary.LittleEndian, true
+	case 0x4d3cb2a1:
+		reader.order, reader.nano = binary.BigEndian, true
+	}
+	// 高位可能带有FCS标志，链路类型只取低16位
+	reader.linkType = reader.order.Uint32(header[20:24]) & 0xffff
+	return reader, nil
+}
+
+func (p *pcapReader) Next() (Packet, error) {
+	if _, err := io.ReadFull(p.r, p.header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return Packet{}, fmt.Errorf("truncated pcap record header")
+		}
+		return Packet{}, err
+	}
+
+	seconds := int64(p.order.Uint32(p.header[0:4]))
+	fraction := int64(p.order.Uint32(p.header[4:8]))
+	capLen := p.order.Uint32(p.header[8:12])
+	if capLen > 1<<26 {
+		return Packet{}, fmt.Errorf("invalid pcap record length %d", capLen)
+	}
+
+	data := make([]byte, capLen)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return Packet{}, fmt.Errorf("truncated pcap record: %v", err)
+	}
+
+	if !p.nano {
+		fraction *= 1000
+	}
+	return Packet{Time: time.Unix(seconds, fraction), LinkType: p.linkType, Data: data}, nil
+}
+
+// pcapng中每个接口的链路类型与时间戳精度
+type pcapngInterface struct {
+	linkType       uint32
+	unitsPerSecond uint64
+	offsetSeconds  int64
+}
+
+type pcapngReader struct {
+	r          *bufio.Reader
+	order      binary.ByteOrder
+	interfaces []pcapngInterface
+}
+
+func (p *pcapngReader) Next() (Packet, error) {
+	for {
+		blockType, body, err := p.readBlock()
+		if err != nil {
+			return Packet{}, err
+		}
+
+		switch blockType {
+		case 0x0a0d0d0a: // Section Header Block，每个section重新声明接口
+			p.interfaces = p.interfaces[:0]
+		case 0x00000001: // Interface Description Block
+			if len(body) < 8 {
+				return Packet{}, fmt.Errorf("invalid pcapng interface block")
+			}
+			iface := pcapngInterface{linkType: uint32(p.order.Uint16(body[0:2])), unitsPerSecond: 1000000}
+			p.parseInterfaceOptions(&iface, body[8:])
+			p.interfaces = append(p.interfaces, iface)
+		case 0x00000006: // Enhanced Packet Block
+			if len(body) < 20 {
+				return Packet{}, fmt.Errorf("invalid pcapng enhanced packet block")
+			}
+			ifaceID := p.order.Uint32(body[0:4])
+			timestamp := uint64(p.order.Uint32(body[4:8]))<<32 | uint64(p.order.Uint32(body[8:12]))
+			capLen := p.order.Uint32(body[12:16])
+			if int(capLen) > len(body)-20 {
+				return Packet{}, fmt.Errorf("invalid pcapng packet length %d", capLen)
+			}
+			return p.packet(ifaceID, timestamp, body[20:20+capLen])
+		case 0x00000002: // 旧版Packet Block
+			if len(body) < 20 {
+				return Packet{}, fmt.Errorf("invalid pcapng packet block")
+			}
+			ifaceID := uint32(p.order.Uint16(body[0:2]))
+			timestamp := uint64(p.order.Uint32(body[4:8]))<<32 | uint64(p.order.Uint32(body[8:12]))
+			capLen := p.order.Uint32(body[12:16])
+			if int(capLen) > len(body)-20 {
+				return Packet{}, fmt.Errorf("invalid pcapng packet length %d", capLen)
+			}
+			return p.packet(ifaceID, timestamp, body[20:20+capLen])
+		}
+		// Simple Packet Block没有时间戳，其余块与报文无关，均跳过
+	}
+}
+
+func (p *pcapngReader) packet(ifaceID uint32, timestamp uint64, data []byte) (Packet, error) {
+	if int(ifaceID) >= len(p.interfaces) {
+		return Packet{}, fmt.Errorf("pcapng packet refers to unknown interface %d", ifaceID)
+	}
+	iface := p.interfaces[ifaceID]
+	seconds := timestamp / iface.unitsPerSecond
+	fraction := timestamp % iface.unitsPerSecond
+	nanos := int64(float64(fraction) * 1e9 / float64(iface.unitsPerSecond))
+	return Packet{
+		Time:     time.Unix(int64(seconds)+iface.offsetSeconds, nanos),
+		LinkType: iface.linkType,
+		Data:     data,
+	}, nil
+}
+
+func (p *pcapngReader) parseInterfaceOptions(iface *pcapngInterface, options []byte) {
+	for len(options) >= 4 {
+		code := p.order.Uint16(options[0:2])
+		length := int(p.order.Uint16(options[2:4]))
+		if code == 0 || 4+length > len(options) {
+			return
+		}
+		value := options[4 : 4+length]
+		switch {
+		case code == 9 && length >= 1: // if_tsresol：最高位为0表示10的负幂，为1表示2的负幂
+			exponent := float64(value[0] & 0x7f)
+			if value[0]&0x80 == 0 {
+				iface.unitsPerSecond = uint64(math.Pow(10, exponent))
+			} else {
+				iface.unitsPerSecond = uint64(math.Pow(2, exponent))
+			}
+		case code == 14 && length >= 8: // if_tsoffset
+			iface.offsetSeconds = int64(p.order.Uint64(value))
+		}
+		options = options[4+(length+3)/4*4:]
+	}
+}
+
+// 读取一个块，返回块类型和去掉首尾长度字段后的内容
+func (p *pcapngReader) readBlock() (uint32, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(p.r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, fmt.Errorf("truncated pcapng block header")
+		}
+		return 0, nil, err
+	}
+
+	// 字节序由Section Header Block中的byte-order magic决定
+	if binary.LittleEndian.Uint32(header[0:4]) == 0x0a0d0d0a {
+		magic, err := p.r.Peek(4)
+		if err != nil {
+			return 0, nil, fmt.Errorf("truncated pcapng section header")
+		}
+		switch binary.LittleEndian.Uint32(magic) {
+		case 0x1a2b3c4d:
+			p.order = binary.LittleEndian
+		case 0x4d3c2b1a:
+			p.order = binary.BigEndian
+		default:
+			return 0, nil, fmt.Errorf("invalid pcapng byte-order magic %x", magic)
+		}
+	}
+	if p.order == nil {
+		return 0, nil, errors.New("pcapng file does not start with a section header")
+	}
+
+	blockType := p.order.Uint32(header[0:4])
+	blockLen := p.order.Uint32(header[4:8])
+	if blockLen < 12 || blockLen%4 != 0 || blockLen > 1<<26 {
+		return 0, nil, fmt.Errorf("invalid pcapng block length %d", blockLen)
+	}
+
+	body := make([]byte, blockLen-8)
+	if _, err := io.ReadFull(p.r, body); err != nil {
+		return 0, nil, fmt.Errorf("truncated pcapng block: %v", err)
+	}
+	return blockType, body[:len(body)-4], nil
+}
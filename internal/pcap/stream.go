@@ -0,0 +1,408 @@
+package pcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message 从抓包中还原出的一条FIX报文
+type Message struct {
+	Time time.Time // 报文最后一个字节被抓到的时间
+	Src  string
+	Dst  string
+	Line string // '\x01'已替换为'|'
+}
+
+type tcpSegment struct {
+	src, dst         string
+	seq              uint32
+	syn, fin, rst    bool
+	payload          []byte
+	srcPort, dstPort uint16
+}
+
+// 解析链路层、IP层和TCP头，非TCP报文返回false
+func decodeTcpSegment(linkType uint32, data []byte) (tcpSegment, bool) {
+	var etherType uint16
+	switch linkType {
+	case 0: // BSD loopback，4字节协议族(主机字节序)
+		if len(data) < 4 {
+			return tcpSegment{}, false
+		}
+		family := binary.LittleEndian.Uint32(data[0:4])
+		if family > 0xffff {
+			family = binary.BigEndian.Uint32(data[0:4])
+		}
+		data = data[4:]
+		switch family {
+		case 2:
+			etherType = 0x0800
+		case 24, 28, 30:
+			etherType = 0x86dd
+		default:
+			return tcpSegment{}, false
+		}
+	case 1: // Ethernet，可能带802.1Q标签
+		if len(data) < 14 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[12:14])
+		data = data[14:]
+		for (etherType == 0x8100 || etherType == 0x88a8) && len(data) >= 4 {
+			etherType = binary.BigEndian.Uint16(data[2:4])
+			data = data[4:]
+		}
+	case 113: // Linux cooked capture
+		if len(data) < 16 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[14:16])
+		data = data[16:]
+	case 276: // Linux cooked capture v2
+		if len(data) < 20 {
+			return tcpSegment{}, false
+		}
+		etherType = binary.BigEndian.Uint16(data[0:2])
+		data = data[20:]
+	case 12, 101, 228, 229: // 裸IP
+		if len(data) < 1 {
+			return tcpSegment{}, false
+		}
+		etherType = 0x0800
+		if data[0]>>4 == 6 {
+			etherType = 0x86dd
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	var srcIP, dstIP net.IP
+	switch etherType {
+	case 0x0800:
+		if len(data) < 20 || data[0]>>4 != 4 {
+			return tcpSegment{}, false
+		}
+		headerLen := int(data[0]&0x0f) * 4
+		totalLen := int(binary.BigEndian.Uint16(data[2:4]))
+		// 忽略IP分片
+		if data[9] != 6 || headerLen < 20 || binary.BigEndian.Uint16(data[6:8])&0x3fff != 0 {
+			return tcpSegment{}, false
+		}
+		if totalLen < len(data) && totalLen >= headerLen {
+			data = data[:totalLen] // 去掉以太网填充
+		}
+		if len(data) < headerLen {
+			return tcpSegment{}, false
+		}
+		srcIP, dstIP = net.IP(data[12:16]), net.IP(data[16:20])
+		data = data[headerLen:]
+	case 0x86dd:
+		if len(data) < 40 || data[6] != 6 {
+			return tcpSegment{}, false
+		}
+		payloadLen := int(binary.BigEndian.Uint16(data[4:6]))
+		srcIP, dstIP = net.IP(data[8:24]), net.IP(data[24:40])
+		data = data[40:]
+		if payloadLen < len(data) {
+			data = data[:payloadLen]
+		}
+	default:
+		return tcpSegment{}, false
+	}
+
+	if len(data) < 20 {
+		return tcpSegment{}, false
+	}
+	dataOffset := int(data[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(data) {
+		return tcpSegment{}, false
+	}
+	flags := data[13]
+	segment := tcpSegment{
+		srcPort: binary.BigEndian.Uint16(data[0:2]),
+		dstPort: binary.BigEndian.Uint16(data[2:4]),
+		seq:     binary.BigEndian.Uint32(data[4:8]),
+		fin:     flags&0x01 != 0,
+		syn:     flags&0x02 != 0,
+		rst:     flags&0x04 != 0,
+		payload: data[dataOffset:],
+	}
+	segment.src = net.JoinHostPort(srcIP.String(), strconv.Itoa(int(segment.srcPort)))
+	segment.dst = net.JoinHostPort(dstIP.String(), strconv.Itoa(int(segment.dstPort)))
+	return segment, true
+}
+
+// 单向TCP流的重组状态
+type tcpStream struct {
+	src, dst  string
+	started   bool
+	nextSeq   uint32
+	buffer    []byte
+	bufferEnd []chunkMark // buffer中每段数据的结束位置及其抓包时间
+	pending   map[uint32]pendingSegment
+}
+
+type chunkMark struct {
+	end  int
+	time time.Time
+}
+
+type pendingSegment struct {
+	payload []byte
+	time    time.Time
+}
+
+// 乱序缓存上限，超过后认为中间数据已丢失，从最早的缓存段继续
+const maxPendingSegments = 1024
+
+// seq回绕比较：a在b之前返回负数
+func seqDiff(a, b uint32) int32 {
+	return int32(a - b)
+}
+
+func (s *tcpStream) add(seq uint32, payload []byte, t time.Time, emit func(Message)) {
+	if !s.started {
+		s.started = true
+		s.nextSeq = seq
+	}
+
+	diff := seqDiff(seq, s.nextSeq)
+	if diff > 0 {
+		// 乱序到达，先缓存
+		if _, exists := s.pending[seq]; !exists {
+			s.pending[seq] = pendingSegment{append([]byte(nil), payload...), t}
+		}
+		if len(s.pending) > maxPendingSegments {
+			s.skipGap(emit)
+		}
+		return
+	}
+	if -int(diff) >= len(payload) {
+		return // 重传
+	}
+	s.append(payload[-diff:], t)
+
+	// 继续处理已缓存的后续数据
+	for {
+		next, found := s.takePending()
+		if !found {
+			break
+		}
+		s.append(next.payload, next.time)
+	}
+	s.extract(emit)
+}
+
+func (s *tcpStream) takePending() (pendingSegment, bool) {
+	for seq, segment := range s.pending {
+		diff := seqDiff(seq, s.nextSeq)
+		if diff > 0 {
+			continue
+		}
+		delete(s.pending, seq)
+		if -int(diff) < len(segment.payload) {
+			return pendingSegment{segment.payload[-diff:], segment.time}, true
+		}
+	}
+	return pendingSegment{}, false
+}
+
+// 丢包导致无法继续重组时，丢弃不完整的数据，从最早的缓存段重新对齐FIX报文
+func (s *tcpStream) skipGap(emit func(Message)) {
+	var earliest uint32
+	first := true
+	for seq := range s.pending {
+		if first || seqDiff(seq, earliest) < 0 {
+			earliest, first = seq, false
+		}
+	}
+	s.buffer, s.bufferEnd = s.buffer[:0], s.bufferEnd[:0]
+	s.nextSeq = earliest
+	for {
+		next, found := s.takePending()
+		if !found {
+			break
+		}
+		s.append(next.payload, next.time)
+	}
+	s.extract(emit)
+}
+
+func (s *tcpStream) append(payload []byte, t time.Time) {
+	if len(payload) == 0 {
+		return
+	}
+	s.buffer = append(s.buffer, payload...)
+	s.bufferEnd = append(s.bufferEnd, chunkMark{len(s.buffer), t})
+	s.nextSeq += uint32(len(payload))
+}
+
+var fixBeginString = []byte("8=FIX")
+
+// 按BodyLength(9)切分出完整的FIX报文
+func (s *tcpStream) extract(emit func(Message)) {
+	consumed := 0
+	for {
+		data := s.buffer[consumed:]
+		start := bytes.Index(data, fixBeginString)
+		if start < 0 {
+			// 保留可能是报文开头的末尾几个字节
+			if len(data) > len(fixBeginString) {
+				consumed += len(data) - len(fixBeginString)
+			}
+			break
+		}
+		consumed += start
+		data = data[start:]
+
+		length, ok := fixMessageLength(data)
+		if !ok {
+			if length < 0 {
+				consumed += len(fixBeginString) // 报文头不合法，跳过
+				continue
+			}
+			break // 数据还不完整
+		}
+
+		emit(Message{
+			Time: s.timeAt(consumed + length - 1),
+			Src:  s.src,
+			Dst:  s.dst,
+			Line: string(bytes.ReplaceAll(data[:length], []byte{0x01}, []byte{'|'})),
+		})
+		consumed += length
+	}
+
+	s.buffer = append(s.buffer[:0], s.buffer[consumed:]...)
+	marks := s.bufferEnd[:0]
+	for _, mark := range s.bufferEnd {
+		if mark.end > consumed {
+			marks = append(marks, chunkMark{mark.end - consumed, mark.time})
+		}
+	}
+	s.bufferEnd = marks
+}
+
+func (s *tcpStream) timeAt(offset int) time.Time {
+	for _, mark := range s.bufferEnd {
+		if offset < mark.end {
+			return mark.time
+		}
+	}
+	return s.bufferEnd[len(s.bufferEnd)-1].time
+}
+
+// 返回完整报文长度；数据不足时返回(0,false)，报文头不合法时返回(-1,false)
+func fixMessageLength(data []byte) (int, bool) {
+	beginEnd := bytes.IndexByte(data, 0x01)
+	if beginEnd < 0 {
+		return 0, false
+	}
+	rest := data[beginEnd+1:]
+	if len(rest) < 3 {
+		return 0, false
+	}
+	if !bytes.HasPrefix(rest, []byte("9=")) {
+		return -1, false
+	}
+	lengthEnd := bytes.IndexByte(rest, 0x01)
+	if lengthEnd < 0 {
+		if len(rest) > 12 {
+			return -1, false
+		}
+		return 0, false
+	}
+	bodyLen, err := strconv.Atoi(string(rest[2:lengthEnd]))
+	if err != nil || bodyLen < 0 {
+		return -1, false
+	}
+
+	// 报文体之后是"10=xxx\x01"校验和字段
+	bodyStart := beginEnd + 1 + lengthEnd + 1
+	checksumStart := bodyStart + bodyLen
+	if len(data) < checksumStart+7 {
+		return 0, false
+	}
+	if !bytes.HasPrefix(data[checksumStart:], []byte("10=")) {
+		return -1, false
+	}
+	checksumEnd := bytes.IndexByte(data[checksumStart:], 0x01)
+	if checksumEnd < 0 {
+		return 0, false
+	}
+	return checksumStart + checksumEnd + 1, true
+}
+
+// Reassembler 按四元组区分的TCP重组器，只处理ports中的端口(为空时处理全部)
+type Reassembler struct {
+	ports   map[uint16]bool
+	streams map[string]*tcpStream
+}
+
+func NewReassembler(ports []uint16) *Reassembler {
+	r := &Reassembler{ports: make(map[uint16]bool), streams: make(map[string]*tcpStream)}
+	for _, port := range ports {
+		r.ports[port] = true
+	}
+	return r
+}
+
+// Add 处理一帧，每还原出一条完整的FIX报文调用一次emit
+func (r *Reassembler) Add(packet Packet, emit func(Message)) {
+	segment, ok := decodeTcpSegment(packet.LinkType, packet.Data)
+	if !ok {
+		return
+	}
+	if len(r.ports) > 0 && !r.ports[segment.srcPort] && !r.ports[segment.dstPort] {
+		return
+	}
+
+	key := segment.src + ">" + segment.dst
+	stream, exists := r.streams[key]
+	if segment.syn || segment.rst || !exists {
+		// 新连接(或中途开始抓包)时重新建立流状态
+		if segment.rst {
+			delete(r.streams, key)
+			return
+		}
+		stream = &tcpStream{src: segment.src, dst: segment.dst, pending: make(map[uint32]pendingSegment)}
+		r.streams[key] = stream
+		if segment.syn {
+			stream.started = true
+			stream.nextSeq = segment.seq + 1
+		}
+	}
+	stream.add(segment.seq+boolToUint32(segment.syn), segment.payload, packet.Time, emit)
+	if segment.fin {
+		delete(r.streams, key)
+	}
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// ParsePorts 解析逗号分隔的端口列表
+func ParsePorts(value string) ([]uint16, error) {
+	var ports []uint16
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		port, err := strconv.ParseUint(field, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q: %v", field, err)
+		}
+		ports = append(ports, uint16(port))
+	}
+	return ports, nil
+}
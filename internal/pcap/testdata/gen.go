@@ -0,0 +1,182 @@
+//go:build ignore
+
+// 生成测试用的抓包文件：go run gen.go(在testdata目录下执行)
+//
+// 两个文件内容相同：客户端10.0.0.1:40000到OMS 10.0.0.2:9880的一个TCP连接，
+// 流中依次为FIX报文M1..M5，之后OMS回一条M6。各帧(时间为第n毫秒)：
+//
+//	1 SYN
+//	2 M1+M2            一个分段中有多条报文
+//	3 M3后半+M4前半    乱序，先于4到达
+//	4 M3前半           补上空洞，M3跨两个分段
+//	5 M3前半           重传
+//	6 M4后半+M5前半    与3重叠
+//	7 M5剩余部分
+//	8 FIN
+//	9 M6               反方向
+//
+// stream.pcap为微秒精度、Ethernet；stream.pcapng为纳秒精度(if_tsresol=9)、裸IPv4
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+)
+
+var base = time.Date(2024, 4, 11, 0, 0, 0, 0, time.UTC)
+
+func fix(body string) []byte {
+	head := fmt.Sprintf("8=FIX.4.2\x019=%d\x01", len(body))
+	sum := 0
+	for _, b := range []byte(head + body) {
+		sum += int(b)
+	}
+	return []byte(fmt.Sprintf("%s%s10=%03d\x01", head, body, sum%256))
+}
+
+var messages = [][]byte{
+	fix("35=D\x0149=HRT01\x0156=OMS\x0134=1\x0111=C1\x0155=7203\x01"),
+	fix("35=D\x0149=HRT01\x0156=OMS\x0134=2\x0111=C2\x0155=6758\x01"),
+	fix("35=D\x0149=HRT01\x0156=OMS\x0134=3\x0111=C3\x0155=9984\x0158=split across segments\x01"),
+	fix("35=F\x0149=HRT01\x0156=OMS\x0134=4\x0111=C4\x0141=C3\x01"),
+	fix("35=D\x0149=HRT01\x0156=OMS\x0134=5\x0111=C5\x0155=7203\x01"),
+	fix("35=8\x0149=OMS\x0156=HRT01\x0134=1\x0111=C1\x01150=0\x01"),
+}
+
+type frame struct {
+	at      time.Duration
+	reverse bool
+	seq     uint32
+	flags   byte
+	payload []byte
+}
+
+func frames() []frame {
+	const isn = 1000
+	m1, m2, m3, m4, m5 := messages[0], messages[1], messages[2], messages[3], messages[4]
+	stream := bytes.Join([][]byte{m1, m2, m3, m4, m5}, nil)
+	offset := func(i int) int { // 流中第i条报文的起始位置
+		n := 0
+		for _, m := range messages[:i] {
+			n += len(m)
+		}
+		return n
+	}
+	m3Half := offset(2) + len(m3)/2
+	m4Half := offset(3) + len(m4)/2
+	m4Third := offset(3) + len(m4)/3
+	m5Half := offset(4) + len(m5)/2
+	seq := func(pos int) uint32 { return isn + 1 + uint32(pos) }
+	ms := time.Millisecond
+	return []frame{
+		{1 * ms, false, isn, 0x02, nil},
+		{2 * ms, false, seq(0), 0x18, stream[:offset(2)]},
+		{3 * ms, false, seq(m3Half), 0x18, stream[m3Half:m4Half]},
+		{4 * ms, false, seq(offset(2)), 0x18, stream[offset(2):m3Half]},
+		{5 * ms, false, seq(offset(2)), 0x18, stream[offset(2):m3Half]},
+		{6 * ms, false, seq(m4Third), 0x18, stream[m4Third:m5Half]},
+		{7 * ms, false, seq(m5Half), 0x18, stream[m5Half:]},
+		{8 * ms, false, seq(len(stream)), 0x11, nil},
+		{9 * ms, true, 5000, 0x18, messages[5]},
+	}
+}
+
+func ipv4(f frame) []byte {
+	src, dst := []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}
+	srcPort, dstPort := uint16(40000), uint16(9880)
+	if f.reverse {
+		src, dst, srcPort, dstPort = dst, src, dstPort, srcPort
+	}
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:2], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:4], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:8], f.seq)
+	tcp[12] = 5 << 4
+	tcp[13] = f.flags
+	binary.BigEndian.PutUint16(tcp[14:16], 65535)
+	tcp = append(tcp, f.payload...)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:4], uint16(20+len(tcp)))
+	ip[6] = 0x40 // DF
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:16], src)
+	copy(ip[16:20], dst)
+	return append(ip, tcp...)
+}
+
+func ethernet(f frame) []byte {
+	header := []byte{2, 0, 0, 0, 0, 2, 2, 0, 0, 0, 0, 1, 0x08, 0x00}
+	return append(header, ipv4(f)...)
+}
+
+func writePcap(filename string) error {
+	var buf bytes.Buffer
+	le := binary.LittleEndian
+	binary.Write(&buf, le, []uint32{0xa1b2c3d4})
+	binary.Write(&buf, le, []uint16{2, 4})
+	binary.Write(&buf, le, []uint32{0, 0, 65535, 1})
+	for _, f := range frames() {
+		t := base.Add(f.at + 123*time.Microsecond)
+		data := ethernet(f)
+		binary.Write(&buf, le, []uint32{uint32(t.Unix()), uint32(t.Nanosecond() / 1000), uint32(len(data)), uint32(len(data))})
+		buf.Write(data)
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
+
+func block(blockType uint32, body []byte) []byte {
+	for len(body)%4 != 0 {
+		body = append(body, 0)
+	}
+	length := uint32(12 + len(body))
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, []uint32{blockType, length})
+	buf.Write(body)
+	binary.Write(&buf, binary.LittleEndian, length)
+	return buf.Bytes()
+}
+
+func writePcapng(filename string) error {
+	le := binary.LittleEndian
+	var buf bytes.Buffer
+
+	var shb bytes.Buffer
+	binary.Write(&shb, le, uint32(0x1a2b3c4d))
+	binary.Write(&shb, le, []uint16{1, 0})
+	binary.Write(&shb, le, int64(-1))
+	buf.Write(block(0x0a0d0d0a, shb.Bytes()))
+
+	var idb bytes.Buffer
+	binary.Write(&idb, le, []uint16{101, 0})
+	binary.Write(&idb, le, uint32(65535))
+	binary.Write(&idb, le, []uint16{9, 1}) // if_tsresol=9，纳秒
+	idb.Write([]byte{9, 0, 0, 0})
+	binary.Write(&idb, le, []uint16{0, 0})
+	buf.Write(block(0x00000001, idb.Bytes()))
+
+	for _, f := range frames() {
+		t := base.Add(f.at + 456*time.Nanosecond)
+		nanos := uint64(t.UnixNano())
+		data := ipv4(f)
+		var epb bytes.Buffer
+		binary.Write(&epb, le, []uint32{0, uint32(nanos >> 32), uint32(nanos), uint32(len(data)), uint32(len(data))})
+		epb.Write(data)
+		buf.Write(block(0x00000006, epb.Bytes()))
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0o644)
+}
+
+func main() {
+	if err := writePcap("stream.pcap"); err != nil {
+		panic(err)
+	}
+	if err := writePcapng("stream.pcapng"); err != nil {
+		panic(err)
+	}
+}
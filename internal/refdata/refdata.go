@@ -0,0 +1,222 @@
+// Package refdata 加载账户、合约等参考数据，按代码查找属性。
+//
+// CSV第一行为表头，键列按名称(不区分大小写)查找，其余列为属性：
+//
+//	Account,clientName,desk,strategy
+//	ACC1,Alpha Capital,Equity Desk,Market Making
+//
+// JSON为对象数组，每个对象含键字段；或以代码为键的对象，值为属性对象：
+//
+//	[{"symbol": "7203", "market": "Prime", "sector": "Automobiles", "lotSize": 100}]
+//	{"7203": {"market": "Prime", "sector": "Automobiles", "lotSize": 100}}
+//
+// 数值、布尔值按JSON文本转为字符串，null及空字符串视为没有该属性。
+package refdata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Table 一张参考数据表
+type Table struct {
+	Key     string   // 键字段名，如Account
+	Columns []string // 属性名：CSV按表头顺序，JSON按名称排序
+	rows    map[string]map[string]string
+}
+
+// Load 按扩展名(.csv、.json)读取参考数据文件
+func Load(filename string, key string) (*Table, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
+	case ".csv":
+		return ReadCSV(file, key)
+	case ".json":
+		return ReadJSON(file, key)
+	default:
+		return nil, fmt.Errorf("unsupported reference data format %q, expected .csv or .json", ext)
+	}
+}
+
+func newTable(key string) *Table {
+	return &Table{Key: key, rows: make(map[string]map[string]string)}
+}
+
+func (t *Table) add(code string, attributes map[string]string) error {
+	if code == "" {
+		return fmt.Errorf("empty %s", t.Key)
+	}
+	if _, exists := t.rows[code]; exists {
+		return fmt.Errorf("duplicate %s %q", t.Key, code)
+	}
+	t.rows[code] = attributes
+	return nil
+}
+
+// ReadCSV 读取带表头的CSV
+func ReadCSV(r io.Reader, key string) (*Table, error) {
+	// Excel等导出的UTF-8 CSV开头带BOM，需在解析前去掉，否则带引号的首列无法解析
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(3); err == nil && bytes.Equal(bom, []byte("\ufeff")) {
+		br.Discard(3)
+	}
+	records, err := csv.NewReader(br).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("error reading CSV: no header")
+	}
+	header := records[0]
+	keyIndex := -1
+	seen := make(map[string]bool)
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if seen[name] || strings.EqualFold(name, key) && keyIndex >= 0 {
+			return nil, fmt.Errorf("error reading CSV: duplicate column %q", name)
+		}
+		seen[name] = true
+		if strings.EqualFold(name, key) {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("error reading CSV: no %s column", key)
+	}
+
+	t := newTable(key)
+	for i, name := range header {
+		if i != keyIndex {
+			t.Columns = append(t.Columns, strings.TrimSpace(name))
+		}
+	}
+	for n, record := range records[1:] {
+		attributes := make(map[string]string)
+		for i, value := range record {
+			if i != keyIndex && value != "" {
+				attributes[strings.TrimSpace(header[i])] = value
+			}
+		}
+		if err := t.add(strings.TrimSpace(record[keyIndex]), attributes); err != nil {
+			return nil, fmt.Errorf("error reading CSV: line %d: %v", n+2, err)
+		}
+	}
+	return t, nil
+}
+
+// ReadJSON 读取对象数组或以代码为键的对象
+func ReadJSON(r io.Reader, key string) (*Table, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON: %v", err)
+	}
+	decode := func(v any) error {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		return decoder.Decode(v)
+	}
+
+	t := newTable(key)
+	columns := make(map[string]bool)
+	addObject := func(code string, object map[string]any, keyed bool) error {
+		attributes := make(map[string]string)
+		for name, value := range object {
+			if keyed && strings.EqualFold(name, key) {
+				continue
+			}
+			s, err := scalar(value)
+			if err != nil {
+				return fmt.Errorf("%s %q: %s: %v", key, code, name, err)
+			}
+			columns[name] = true
+			if s != "" {
+				attributes[name] = s
+			}
+		}
+		return t.add(code, attributes)
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var objects []map[string]any
+		if err := decode(&objects); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %v", err)
+		}
+		for i, object := range objects {
+			var code string
+			for name, value := range object {
+				if strings.EqualFold(name, key) {
+					if code, err = scalar(value); err != nil {
+						return nil, fmt.Errorf("error parsing JSON: item %d: %s: %v", i+1, key, err)
+					}
+				}
+			}
+			if err := addObject(code, object, true); err != nil {
+				return nil, fmt.Errorf("error parsing JSON: item %d: %v", i+1, err)
+			}
+		}
+	} else {
+		var objects map[string]map[string]any
+		if err := decode(&objects); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %v", err)
+		}
+		for code, object := range objects {
+			if err := addObject(code, object, false); err != nil {
+				return nil, fmt.Errorf("error parsing JSON: %v", err)
+			}
+		}
+	}
+
+	for name := range columns {
+		t.Columns = append(t.Columns, name)
+	}
+	sort.Strings(t.Columns)
+	return t, nil
+}
+
+// 字符串、数值、布尔值转为字符串，null为空串
+func scalar(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return fmt.Sprint(v), nil
+	}
+	return "", fmt.Errorf("expected a string, number or boolean")
+}
+
+// HasColumn 是否有该属性列
+func (t *Table) HasColumn(column string) bool {
+	for _, c := range t.Columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup 返回代码对应的属性，代码不在表中或没有该属性时ok为false
+func (t *Table) Lookup(code string, column string) (string, bool) {
+	value, ok := t.rows[code][column]
+	return value, ok
+}
+
+// Len 表中的代码数
+func (t *Table) Len() int {
+	return len(t.rows)
+}
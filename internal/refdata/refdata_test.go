@@ -0,0 +1,117 @@
+package refdata
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		columns []string
+		lookups map[string]map[string]string // 代码 -> 列 -> 值，值为空表示没有该属性
+		err     string
+	}{
+		{
+			name:    "plain",
+			csv:     "Account,clientName,desk\nACC1,Alpha Capital,Equity\nACC2,,Delta One\n",
+			columns: []string{"clientName", "desk"},
+			lookups: map[string]map[string]string{
+				"ACC1": {"clientName": "Alpha Capital", "desk": "Equity"},
+				"ACC2": {"clientName": "", "desk": "Delta One"},
+				"ACC3": {"desk": ""},
+			},
+		},
+		{
+			name:    "key in the middle, case-insensitive, spaces trimmed",
+			csv:     "desk, account ,strategy\nEquity, ACC1 ,MM\n",
+			columns: []string{"desk", "strategy"},
+			lookups: map[string]map[string]string{"ACC1": {"desk": "Equity", "strategy": "MM"}},
+		},
+		{
+			name:    "BOM",
+			csv:     "\ufeffAccount,desk\nACC1,Equity\n",
+			columns: []string{"desk"},
+			lookups: map[string]map[string]string{"ACC1": {"desk": "Equity"}},
+		},
+		{
+			name:    "BOM before a quoted header",
+			csv:     "\ufeff\"Account\",\"desk\"\nACC1,Equity\n",
+			columns: []string{"desk"},
+			lookups: map[string]map[string]string{"ACC1": {"desk": "Equity"}},
+		},
+		{name: "empty", csv: "", err: "no header"},
+		{name: "no key column", csv: "Symbol,market\n7203,Prime\n", err: "no Account column"},
+		{name: "duplicate column", csv: "Account,desk,desk\nACC1,A,B\n", err: `duplicate column "desk"`},
+		{name: "duplicate column after trimming", csv: "Account,desk, desk\nACC1,A,B\n", err: `duplicate column "desk"`},
+		{name: "duplicate key column", csv: "Account,desk,ACCOUNT\nACC1,A,ACC2\n", err: `duplicate column "ACCOUNT"`},
+		{name: "duplicate code", csv: "Account,desk\nACC1,A\nACC1,B\n", err: `line 3: duplicate Account "ACC1"`},
+		{name: "empty code", csv: "Account,desk\n,A\n", err: "line 2: empty Account"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := ReadCSV(strings.NewReader(tt.csv), "Account")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(table.Columns, tt.columns) {
+				t.Errorf("columns = %q, want %q", table.Columns, tt.columns)
+			}
+			for code, values := range tt.lookups {
+				for column, want := range values {
+					got, ok := table.Lookup(code, column)
+					if got != want || ok != (want != "") {
+						t.Errorf("Lookup(%s, %s) = %q, %v, want %q", code, column, got, ok, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{name: "array", json: `[{"symbol": "7203", "market": "Prime", "lotSize": 100, "active": true, "sector": null}]`},
+		{name: "keyed", json: `{"7203": {"market": "Prime", "lotSize": 100, "active": true, "sector": ""}}`},
+		{name: "nested value", json: `[{"Symbol": "7203", "market": {"name": "Prime"}}]`, err: "expected a string, number or boolean"},
+		{name: "missing key", json: `[{"market": "Prime"}]`, err: "empty Symbol"},
+		{name: "duplicate code", json: `[{"Symbol": "7203"}, {"Symbol": "7203"}]`, err: `item 2: duplicate Symbol "7203"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := ReadJSON(strings.NewReader(tt.json), "Symbol")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{"active", "lotSize", "market", "sector"}; !reflect.DeepEqual(table.Columns, want) {
+				t.Errorf("columns = %q, want %q", table.Columns, want)
+			}
+			for column, want := range map[string]string{"market": "Prime", "lotSize": "100", "active": "true"} {
+				if got, ok := table.Lookup("7203", column); !ok || got != want {
+					t.Errorf("Lookup(7203, %s) = %q, %v, want %q", column, got, ok, want)
+				}
+			}
+			if _, ok := table.Lookup("7203", "sector"); ok {
+				t.Errorf("an empty or null sector should not be an attribute")
+			}
+		})
+	}
+}
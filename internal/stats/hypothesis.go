@@ -0,0 +1,158 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// MannWhitneyU 双样本Mann-Whitney U检验(双侧，正态近似，含并列秩修正和连续性修正)，
+// 返回a的U统计量和p值；任一样本为空时p为1
+func MannWhitneyU(a, b []time.Duration) (float64, float64) {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if len(a) == 0 || len(b) == 0 {
+		return 0, 1
+	}
+
+	type value struct {
+		v     time.Duration
+		fromA bool
+	}
+	values := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		values = append(values, value{v, true})
+	}
+	for _, v := range b {
+		values = append(values, value{v, false})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].v < values[j].v })
+
+	// 并列值取平均秩
+	var rankSumA, tieTerm float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].v == values[i].v {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].fromA {
+				rankSumA += rank
+			}
+		}
+		t := float64(j - i)
+		tieTerm += t*t*t - t
+		i = j
+	}
+
+	u := rankSumA - n1*(n1+1)/2
+	n := n1 + n2
+	sigma := math.Sqrt(n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1))))
+	if sigma == 0 {
+		return u, 1
+	}
+	z := (math.Abs(u-n1*n2/2) - 0.5) / sigma
+	if z < 0 {
+		z = 0
+	}
+	return u, math.Erfc(z / math.Sqrt2)
+}
+
+// KolmogorovSmirnov 双样本KS检验，输入需已升序排序，返回D统计量和渐近p值；任一样本为空时p为1
+func KolmogorovSmirnov(a, b []time.Duration) (float64, float64) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, 1
+	}
+	n1, n2 := float64(len(a)), float64(len(b))
+	var d float64
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		v := a[i]
+		if b[j] < v {
+			v = b[j]
+		}
+		for i < len(a) && a[i] == v {
+			i++
+		}
+		for j < len(b) && b[j] == v {
+			j++
+		}
+		if diff := math.Abs(float64(i)/n1 - float64(j)/n2); diff > d {
+			d = diff
+		}
+	}
+
+	ne := n1 * n2 / (n1 + n2)
+	lambda := (math.Sqrt(ne) + 0.12 + 0.11/math.Sqrt(ne)) * d
+	return d, kolmogorovQ(lambda)
+}
+
+// Kolmogorov分布的上尾概率 Q(λ) = 2Σ(-1)^(k-1)exp(-2k²λ²)
+func kolmogorovQ(lambda float64) float64 {
+	if lambda < 1e-3 {
+		return 1
+	}
+	var sum float64
+	sign := 1.0
+	for k := 1; k <= 100; k++ {
+		term := sign * math.Exp(-2*float64(k*k)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-10 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, 2*sum))
+}
+
+// BootstrapPercentileDelta b与a的p分位数之差的自助法置信区间(置信水平confidence，如0.95)，输入需已升序排序。
+// 重抽样样本的第k小值等于原样本中下标为floor(n*U(k))的值，U(k)~Beta(k, n-k+1)，因此每次重抽样只需O(1)
+func BootstrapPercentileDelta(a, b []time.Duration, p float64, confidence float64, iterations int, rng *rand.Rand) (time.Duration, time.Duration) {
+	if len(a) == 0 || len(b) == 0 || iterations <= 0 {
+		return 0, 0
+	}
+	deltas := make([]time.Duration, iterations)
+	for i := range deltas {
+		deltas[i] = resampledPercentile(b, p, rng) - resampledPercentile(a, p, rng)
+	}
+	SortDurations(deltas)
+	tail := (1 - confidence) / 2 * 100
+	return Percentile(deltas, tail), Percentile(deltas, 100-tail)
+}
+
+func resampledPercentile(sorted []time.Duration, p float64, rng *rand.Rand) time.Duration {
+	n := len(sorted)
+	k := int(math.Ceil(p / 100 * float64(n)))
+	if k < 1 {
+		k = 1
+	}
+	if k > n {
+		k = n
+	}
+	x := gamma(float64(k), rng)
+	y := gamma(float64(n-k+1), rng)
+	index := int(float64(n) * x / (x + y))
+	if index >= n {
+		index = n - 1
+	}
+	return sorted[index]
+}
+
+// Gamma(shape, 1)分布抽样，shape>=1(Marsaglia-Tsang)
+func gamma(shape float64, rng *rand.Rand) float64 {
+	d := shape - 1.0/3
+	c := 1 / math.Sqrt(9*d)
+	for {
+		x := rng.NormFloat64()
+		v := 1 + c*x
+		if v <= 0 {
+			continue
+		}
+		v = v * v * v
+		u := rng.Float64()
+		if u < 1-0.0331*x*x*x*x || math.Log(u) < 0.5*x*x+d*(1-v+math.Log(v)) {
+			return d * v
+		}
+	}
+}
@@ -0,0 +1,28 @@
+// Package stats 耗时分布的统计函数。
+package stats
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// SortDurations 升序排序
+func SortDurations(values []time.Duration) {
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+}
+
+// Percentile 已升序排序的耗时的p分位数(0-100，最近秩法)，空切片返回0
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
@@ -0,0 +1,151 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func durations(values ...int) []time.Duration {
+	result := make([]time.Duration, len(values))
+	for i, v := range values {
+		result[i] = time.Duration(v) * time.Millisecond
+	}
+	return result
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := durations(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{10, time.Millisecond},
+		{11, 2 * time.Millisecond},
+		{50, 5 * time.Millisecond},
+		{99, 10 * time.Millisecond},
+		{100, 10 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := Percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile of an empty slice = %v", got)
+	}
+}
+
+// 参考值同R的wilcox.test(a, b, exact=FALSE, correct=TRUE)
+func TestMannWhitneyU(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []time.Duration
+		u, p float64
+	}{
+		{"separated", durations(1, 2, 3, 4, 5), durations(6, 7, 8, 9, 10), 0, 0.0121858},
+		{"reversed", durations(6, 7, 8, 9, 10), durations(1, 2, 3, 4, 5), 25, 0.0121858},
+		{"ties", durations(1, 2, 2, 3), durations(2, 3, 4, 5), 2.5, 0.1366582},
+		{"unsorted, unequal sizes", durations(3, 1, 4, 1, 5), durations(9, 2, 6, 5, 3, 5), 6.5, 0.1386259},
+		{"identical", durations(1, 2, 3), durations(1, 2, 3), 4.5, 1},
+		{"all tied", durations(7, 7), durations(7, 7, 7), 3, 1},
+		{"empty", nil, durations(1), 0, 1},
+	}
+	for _, tt := range tests {
+		u, p := MannWhitneyU(tt.a, tt.b)
+		if u != tt.u || math.Abs(p-tt.p) > 1e-6 {
+			t.Errorf("%s: U, p = %v, %.7f, want %v, %.7f", tt.name, u, p, tt.u, tt.p)
+		}
+	}
+}
+
+// p值为Numerical Recipes的渐近公式Q((√Ne+0.12+0.11/√Ne)·D)
+func TestKolmogorovSmirnov(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []time.Duration
+		d, p float64
+	}{
+		{"separated", durations(1, 2, 3, 4, 5), durations(6, 7, 8, 9, 10), 1, 0.0037814},
+		{"ties", durations(1, 2, 2, 3), durations(2, 3, 4, 5), 0.5, 0.5344157},
+		{"shifted", durations(1, 2, 3, 4, 5, 6, 7, 8), durations(3, 4, 5, 6, 7, 8, 9, 10), 0.25, 0.9289548},
+		{"identical", durations(1, 2, 3), durations(1, 2, 3), 0, 1},
+		{"empty", durations(1), nil, 0, 1},
+	}
+	for _, tt := range tests {
+		d, p := KolmogorovSmirnov(tt.a, tt.b)
+		if math.Abs(d-tt.d) > 1e-12 || math.Abs(p-tt.p) > 1e-6 {
+			t.Errorf("%s: D, p = %v, %.7f, want %v, %.7f", tt.name, d, p, tt.d, tt.p)
+		}
+	}
+}
+
+// 抽样的均值和方差与理论值比较：Gamma(k,1)均值方差均为k，Beta(k, n-k+1)均值k/(n+1)
+func TestGammaAndBetaSamplers(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	const samples = 20000
+	for _, shape := range []float64{1, 2.5, 10, 100} {
+		var sum, sumSquares float64
+		for i := 0; i < samples; i++ {
+			x := gamma(shape, rng)
+			if x <= 0 {
+				t.Fatalf("gamma(%v) = %v", shape, x)
+			}
+			sum += x
+			sumSquares += x * x
+		}
+		mean := sum / samples
+		variance := sumSquares/samples - mean*mean
+		if math.Abs(mean-shape) > 0.05*shape || math.Abs(variance-shape) > 0.1*shape {
+			t.Errorf("gamma(%v): mean %.3f, variance %.3f", shape, mean, variance)
+		}
+	}
+
+	for _, tt := range []struct{ k, n float64 }{{1, 10}, {99, 100}, {50, 100}} {
+		var sum float64
+		for i := 0; i < samples; i++ {
+			x, y := gamma(tt.k, rng), gamma(tt.n-tt.k+1, rng)
+			sum += x / (x + y)
+		}
+		want := tt.k / (tt.n + 1)
+		if mean := sum / samples; math.Abs(mean-want) > 0.01 {
+			t.Errorf("beta(%v, %v): mean %.4f, want %.4f", tt.k, tt.n-tt.k+1, mean, want)
+		}
+	}
+}
+
+func TestBootstrapPercentileDelta(t *testing.T) {
+	var a, b []time.Duration
+	for i := 1; i <= 200; i++ {
+		a = append(a, time.Duration(i)*time.Millisecond)
+		b = append(b, time.Duration(i+50)*time.Millisecond)
+	}
+
+	// 同样的种子得到同样的区间
+	low, high := BootstrapPercentileDelta(a, b, 99, 0.95, 2000, rand.New(rand.NewSource(1)))
+	for i := 0; i < 3; i++ {
+		l, h := BootstrapPercentileDelta(a, b, 99, 0.95, 2000, rand.New(rand.NewSource(1)))
+		if l != low || h != high {
+			t.Fatalf("seeded run %d: [%v, %v], want [%v, %v]", i, l, h, low, high)
+		}
+	}
+	// b整体慢50ms，区间包含50ms且整体大于0
+	if low > 50*time.Millisecond || high < 50*time.Millisecond || low <= 0 || low > high {
+		t.Errorf("CI = [%v, %v], want around 50ms", low, high)
+	}
+
+	// 相同样本的区间包含0
+	low, high = BootstrapPercentileDelta(a, a, 50, 0.95, 2000, rand.New(rand.NewSource(2)))
+	if low > 0 || high < 0 {
+		t.Errorf("CI of identical samples = [%v, %v]", low, high)
+	}
+
+	if low, high := BootstrapPercentileDelta(nil, b, 99, 0.95, 2000, rand.New(rand.NewSource(1))); low != 0 || high != 0 {
+		t.Errorf("CI with an empty sample = [%v, %v]", low, high)
+	}
+	if low, high := BootstrapPercentileDelta(a, b, 99, 0.95, 0, rand.New(rand.NewSource(1))); low != 0 || high != 0 {
+		t.Errorf("CI without iterations = [%v, %v]", low, high)
+	}
+}
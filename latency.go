@@ -0,0 +1,474 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/fixdict"
+	"v8/internal/pcap"
+)
+
+// 耗时以毫秒输出，缺少时间点的阶段留空
+func formatCost(stage fixlog.Stage, order *fixlog.Order) string {
+	cost, ok := stage.Cost(order)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%.3f", cost.Seconds()*1000)
+}
+
+// 收集完成的订单，Close时按收到客户端订单的时间排序后写出(同原v8)。
+// 届时才取耗时，重复的JNET确认以最后一条为准
+type csvConsumer struct {
+	fixlog.NopConsumer
+	file   *os.File
+	writer *csv.Writer
+	stages []fixlog.Stage
+	ref    *referenceData // 在耗时之后附加参考数据属性列
+	orders []*fixlog.Order
+}
+
+func newCsvConsumer(csvFilename string, stages []fixlog.Stage, ref *referenceData) (*csvConsumer, error) {
+	file, err := os.Create(csvFilename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating CSV file: %v", err)
+	}
+	c := &csvConsumer{file: file, writer: csv.NewWriter(file), stages: stages, ref: ref}
+
+	header := []string{"Account", "ClientOrderID"}
+	for _, stage := range stages {
+		header = append(header, stage.Name)
+	}
+	header = append(header, ref.attributes()...)
+	if err := c.writer.Write(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error writing header to CSV file: %v", err)
+	}
+	return c, nil
+}
+
+func (c *csvConsumer) OnOrderComplete(order *fixlog.Order) error {
+	c.orders = append(c.orders, order)
+	return nil
+}
+
+func (c *csvConsumer) record(order *fixlog.Order) []string {
+	record := []string{order.Account, order.ClOrdID}
+	for _, stage := range c.stages {
+		record = append(record, formatCost(stage, order))
+	}
+	for _, name := range c.ref.attributes() {
+		value, _ := c.ref.lookup(name, order.Account, order.Symbol)
+		record = append(record, value)
+	}
+	return record
+}
+
+func (c *csvConsumer) Close() error {
+	defer c.file.Close()
+	fixlog.SortByRecvClientTime(c.orders)
+	for _, order := range c.orders {
+		// 写入一行CSV数据
+		if err := c.writer.Write(c.record(order)); err != nil {
+			return fmt.Errorf("error writing record to CSV file: %v", err)
+		}
+	}
+	c.orders = nil
+	// 确保所有的缓存数据都被写入文件
+	c.writer.Flush()
+	if err := c.writer.Error(); err != nil {
+		return fmt.Errorf("error flushing data to CSV file: %v", err)
+	}
+	return c.file.Close()
+}
+
+// 每笔订单完成时写入一行完整的生命周期
+type jsonlConsumer struct {
+	fixlog.NopConsumer
+	file   *os.File
+	writer *bufio.Writer
+	dict   *fixdict.Dictionary // 非nil时附加枚举含义
+	ref    *referenceData      // 附加订单账户和合约的参考数据属性
+}
+
+// 附加了枚举含义和参考数据属性的订单，如{"Decoded":{"Side":"BUY"},"Reference":{"Account.desk":"Equity"}}
+type decodedOrder struct {
+	*fixlog.Order
+	Decoded   map[string]string `json:",omitempty"`
+	Reference map[string]string `json:",omitempty"`
+}
+
+func newJsonlConsumer(jsonlFilename string, dict *fixdict.Dictionary, ref *referenceData) (*jsonlConsumer, error) {
+	file, err := os.Create(jsonlFilename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	return &jsonlConsumer{file: file, writer: bufio.NewWriter(file), dict: dict, ref: ref}, nil
+}
+
+func (c *jsonlConsumer) record(order *fixlog.Order) any {
+	reference := c.ref.values(order.Account, order.Symbol)
+	if c.dict == nil {
+		if reference == nil {
+			return order
+		}
+		return decodedOrder{order, nil, reference}
+	}
+	decoded := decodeValues(c.dict, nil, "", "54", order.Side)
+	for _, e := range []struct {
+		prefix    string
+		execution *fixlog.Execution
+	}{{"Fill.", order.Fill}, {"Correction.", order.Correction}, {"Final.", order.Final}} {
+		if e.execution != nil {
+			decoded = decodeValues(c.dict, decoded, e.prefix, "150", e.execution.ExecType, "39", e.execution.OrdStatus, "20", e.execution.ExecTransType)
+		}
+	}
+	return decodedOrder{order, decoded, reference}
+}
+
+func (c *jsonlConsumer) OnOrderComplete(order *fixlog.Order) error {
+	jsonBytes, err := json.Marshal(c.record(order))
+	if err != nil {
+		return fmt.Errorf("error marshalling to JSON: %v", err)
+	}
+	// 写入换行符以满足jsonl格式要求
+	if _, err := c.writer.Write(append(jsonBytes, '\n')); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return nil
+}
+
+func (c *jsonlConsumer) Close() error {
+	defer c.file.Close()
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("error writing to file: %v", err)
+	}
+	return c.file.Close()
+}
+
+func openFile(filename string, read func(r io.Reader) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	return read(file)
+}
+
+// 依次读入撮合引擎日志、抓包(路径为空时跳过)和OMS日志
+func analyzeLatency(logFilePath string, meLogPath string, capturePath string, ports []uint16, opts ...fixlog.Option) (*fixlog.Analyzer, error) {
+	analyzer := fixlog.NewAnalyzer(opts...)
+	if meLogPath != "" {
+		if err := openFile(meLogPath, analyzer.LoadMatchEngine); err != nil {
+			return nil, fmt.Errorf("error loading matching engine log: %v", err)
+		}
+	}
+	if capturePath != "" {
+		err := openFile(capturePath, func(r io.Reader) error { return analyzer.LoadCapture(r, ports) })
+		if err != nil {
+			return nil, fmt.Errorf("error loading capture: %v", err)
+		}
+	}
+	if err := openFile(logFilePath, analyzer.Analyze); err != nil {
+		return nil, fmt.Errorf("error analyzing log: %v", err)
+	}
+	if err := analyzer.Flush(); err != nil {
+		return nil, fmt.Errorf("error analyzing log: %v", err)
+	}
+	return analyzer, nil
+}
+
+func runLatency(args []string) error {
+	fs := newFlagSet("latency", "[options] <logFilePath> <outputCsvPath>", "Compute per-order OMS latency from the OMS log and export it to CSV.")
+	topN := fs.Int("top", 0, "output the N slowest orders per stage (0 disables)")
+	contextLines := fs.Int("context", 3, "number of surrounding log lines printed around each slow order milestone")
+	exemplarPath := fs.String("exemplars", "", "file to write slow order exemplars to (default stdout)")
+	withFixTime := fs.Bool("fixtime", false, "add latency columns derived from FIX SendingTime(52)/TransactTime(60)")
+	meLogPath := fs.String("me", "", "matching engine log to correlate with the OMS log, splits MatchCostTime into network and ME internal stages")
+	capturePath := fs.String("pcap", "", "pcap/pcapng capture to compute wire-to-wire and log-to-wire latency from")
+	fixPorts := fs.String("fix-ports", "", "comma separated TCP ports carrying FIX in the capture (default all)")
+	estimateSkew := fs.Bool("skew", false, "estimate the matching engine clock offset from request/response pairs and correct its timestamps")
+	skewWindow := fs.Duration("skew-window", time.Minute, "window in which the minimum round trip pair is used for clock offset estimation")
+	skewDrift := fs.Bool("skew-drift", false, "fit clock drift over the day instead of a constant offset")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to compare with UTC FIX timestamps")
+	jsonlPath := fs.String("jsonl", "", "also export the full order lifecycles to this JSONL file")
+	decode := fs.Bool("decode", false, "add a Decoded object with the meaning of Side, ExecType, OrdStatus and ExecTransType from the data dictionary to each JSONL record")
+	dictPaths := addDictFlag(fs)
+	htmlPath := fs.String("html", "", "also write a self-contained HTML report with percentile tables and charts")
+	parquetPath := fs.String("parquet", "", "also export the order lifecycles to this Parquet file, with times and latencies as int64 nanoseconds")
+	otlpPath := fs.String("otlp", "", "also export the order lifecycles as OpenTelemetry traces to this OTLP/JSON file, one export request per line")
+	otlpEndpoint := fs.String("otlp-endpoint", "", "also push the order lifecycle traces to this OTLP/HTTP JSON endpoint, e.g. http://localhost:4318/v1/traces")
+	otlpService := fs.String("otlp-service", "oms", "service.name resource attribute of the exported traces")
+	slaPath := fs.String("sla", "", "JSON file with SLA rules to check; exits with code 3 when any rule is violated")
+	slaViolationsPath := fs.String("sla-violations", "", "also export every order violating an SLA rule to this CSV file")
+	summaryPath := fs.String("summary", "", "also save per-stage latency histograms by account and symbol to this JSON file, for compare")
+	storePath := fs.String("store", "", "also save the orders and daily per-stage aggregates to this history store directory, for trend")
+	tradeDate := fs.String("date", "", "trade date to save in the store, YYYY-MM-DD (default the date of the first order)")
+	filterValue := fs.String("filter", "", orderFilterUsage+"; Account./Symbol. attributes of -accounts/-symbols can be used too")
+	fromValue := fs.String("from", "", "only keep orders received from the client from this time, HH:MM:SS[.ffffff] on the day of the log or YYYY-MM-DD HH:MM:SS[.ffffff]")
+	toValue := fs.String("to", "", "only keep orders received from the client before this time, same format as -from")
+	calendarPath := fs.String("calendar", "", "JSON file with the trading sessions; prints the latency per session and enables -session and the session rules of -sla")
+	sessions := fs.String("session", "", "comma separated trading sessions from -calendar, only keep orders received from the client in them")
+	by := fs.String("by", "", "comma separated order fields or reference attributes, print the per-stage p50/p99 per value of each and add them to the HTML report, e.g. Account.desk,Symbol.market")
+	refFlags := addReferenceFlags(fs)
+	metricsPath := fs.String("metrics-file", "", "also write per-stage latency histograms in Prometheus text format, for the node_exporter textfile collector")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <outputCsvPath>")
+	}
+	logFilePath := positional[0]
+	outputCsvPath := positional[1]
+	if *tradeDate, err = parseTradeDate(*tradeDate); err != nil {
+		return usageErrorf(fs, "-date: %v", err)
+	}
+
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+	var slaConfig fixlog.SLAConfig
+	if *slaPath != "" {
+		if slaConfig, err = loadSlaConfig(*slaPath); err != nil {
+			return fmt.Errorf("error loading SLA config: %v", err)
+		}
+	}
+	var calendar *fixlog.TradingCalendar
+	if *calendarPath != "" {
+		if calendar, err = loadTradingCalendar(*calendarPath); err != nil {
+			return fmt.Errorf("error loading trading calendar: %v", err)
+		}
+	}
+	if err := slaConfig.CheckCalendar(calendar); err != nil {
+		return fmt.Errorf("error loading SLA config: %v", err)
+	}
+	ref, err := refFlags.load()
+	if err != nil {
+		return err
+	}
+	expressionFilter, err := parseOrderFilter(*filterValue, ref)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+	groups := splitList(*by)
+	groupFields, err := resolveOrderFields(fixdict.Default(), ref, groups)
+	if err != nil {
+		return usageErrorf(fs, "-by: %v", err)
+	}
+	window, err := parseTimeWindow(*fromValue, *toValue, loc)
+	if err != nil {
+		return usageErrorf(fs, "%v", err)
+	}
+	if err := window.resolveDate(logFilePath, loc); err != nil {
+		return err
+	}
+	var windowFilter, sessionFilter func(order *fixlog.Order) bool
+	if window.from.specified || window.to.specified {
+		windowFilter = windowOrderFilter(window)
+	}
+	if *sessions != "" {
+		if calendar == nil {
+			return usageErrorf(fs, "-session needs -calendar")
+		}
+		if sessionFilter, err = sessionOrderFilter(calendar, splitList(*sessions)); err != nil {
+			return usageErrorf(fs, "-session: %v", err)
+		}
+	}
+	orderFilter := allOrderFilters(windowFilter, sessionFilter, expressionFilter)
+	var ports []uint16
+	if *capturePath != "" {
+		if ports, err = pcap.ParsePorts(*fixPorts); err != nil {
+			return fmt.Errorf("error parsing FIX ports: %v", err)
+		}
+	}
+
+	opts := []fixlog.Option{fixlog.WithLocation(loc)}
+	if *estimateSkew {
+		// 偏移需读完全部样本才能估计：先单独分析一遍，再带着偏移重新分析并导出
+		analyzer, err := analyzeLatency(logFilePath, *meLogPath, "", nil, opts...)
+		if err != nil {
+			return err
+		}
+		skew, err := analyzer.EstimateClockSkew(*skewWindow, *skewDrift)
+		if err != nil {
+			return fmt.Errorf("error estimating clock skew: %v", err)
+		}
+		fmt.Println("Matching engine clock skew:", skew)
+		opts = append(opts, fixlog.WithClockSkew(skew))
+	}
+	// 时钟偏移按全部订单估计，过滤只作用于导出和统计
+	if orderFilter != nil {
+		opts = append(opts, fixlog.WithOrderFilter(orderFilter))
+	}
+
+	stages := append([]fixlog.Stage{}, fixlog.CoreStages...)
+	if *withFixTime {
+		stages = append(stages, fixlog.FixTimeStages...)
+	}
+	if *meLogPath != "" {
+		stages = append(stages, fixlog.MatchEngineStages...)
+	}
+	if *capturePath != "" {
+		stages = append(stages, fixlog.WireStages...)
+	}
+
+	// CSV和JSONL导出均作为Consumer在订单完成时写出
+	csvOut, err := newCsvConsumer(outputCsvPath, stages, ref)
+	if err != nil {
+		return fmt.Errorf("error exporting to CSV: %v", err)
+	}
+	defer csvOut.file.Close()
+	opts = append(opts, fixlog.WithConsumer(csvOut))
+
+	var jsonlOut *jsonlConsumer
+	if *jsonlPath != "" {
+		var dict *fixdict.Dictionary
+		if *decode {
+			if dict, err = dictPaths.load(); err != nil {
+				return err
+			}
+		}
+		if jsonlOut, err = newJsonlConsumer(*jsonlPath, dict, ref); err != nil {
+			return fmt.Errorf("error exporting to JSONL: %v", err)
+		}
+		defer jsonlOut.file.Close()
+		opts = append(opts, fixlog.WithConsumer(jsonlOut))
+	}
+
+	var parquetOut *parquetConsumer
+	if *parquetPath != "" {
+		if parquetOut, err = newParquetConsumer(*parquetPath, stages, ref); err != nil {
+			return fmt.Errorf("error exporting to Parquet: %v", err)
+		}
+		defer parquetOut.file.Close()
+		opts = append(opts, fixlog.WithConsumer(parquetOut))
+	}
+
+	var otlpOuts []*otlpConsumer
+	if *otlpPath != "" {
+		otlpOut, err := newOtlpFileConsumer(*otlpPath, *otlpService)
+		if err != nil {
+			return fmt.Errorf("error exporting to OTLP: %v", err)
+		}
+		defer otlpOut.close()
+		otlpOuts = append(otlpOuts, otlpOut)
+	}
+	if *otlpEndpoint != "" {
+		otlpOuts = append(otlpOuts, newOtlpHttpConsumer(*otlpEndpoint, *otlpService))
+	}
+	for _, otlpOut := range otlpOuts {
+		opts = append(opts, fixlog.WithConsumer(otlpOut))
+	}
+
+	var report *reportConsumer
+	if *htmlPath != "" {
+		report = &reportConsumer{}
+		opts = append(opts, fixlog.WithConsumer(report))
+	}
+
+	var exporter *latencyMetrics
+	if *metricsPath != "" {
+		exporter = newLatencyMetrics(stages)
+		opts = append(opts, fixlog.WithConsumer(exporter))
+	}
+
+	analyzer, err := analyzeLatency(logFilePath, *meLogPath, *capturePath, ports, opts...)
+	if err != nil {
+		return err
+	}
+	stats := analyzer.Stats()
+	fmt.Println("JNET Correction Order Count: ", stats.ConfirmedMessages)
+	if orderFilter != nil {
+		fmt.Println("Filtered Out Order Count: ", stats.Filtered)
+	}
+	orders := analyzer.CompletedOrders()
+	if calendar != nil {
+		writeSessionStats(os.Stdout, calendar, orders, stages)
+	}
+	var groupTables []groupTable
+	for _, name := range groups {
+		byField := groupByField(orders, groupFields[name])
+		writeGroupStats(os.Stdout, name, byField, stages, false)
+		groupTables = append(groupTables, newGroupTable(name, byField, stages))
+	}
+	if *meLogPath != "" {
+		fmt.Println("Matching Engine Linked Order Count: ", stats.MatchEngineLinked)
+	}
+	if *capturePath != "" {
+		fmt.Println("Wire FIX Message Count: ", stats.WireMessages)
+		fmt.Println("Wire Linked Order Count: ", stats.WireLinked)
+	}
+
+	if err := csvOut.Close(); err != nil {
+		return fmt.Errorf("error exporting to CSV: %v", err)
+	}
+	if jsonlOut != nil {
+		if err := jsonlOut.Close(); err != nil {
+			return fmt.Errorf("error exporting to JSONL: %v", err)
+		}
+	}
+	if parquetOut != nil {
+		if err := parquetOut.Close(); err != nil {
+			return fmt.Errorf("error exporting to Parquet: %v", err)
+		}
+	}
+	for _, otlpOut := range otlpOuts {
+		if err := otlpOut.Close(); err != nil {
+			return fmt.Errorf("error exporting to OTLP: %v", err)
+		}
+	}
+	if len(otlpOuts) > 0 {
+		fmt.Println("OTLP Exported Trace Count: ", otlpOuts[0].Exported)
+	}
+	if exporter != nil {
+		exporter.updateStats(stats)
+		if err := exporter.registry.WriteFile(*metricsPath); err != nil {
+			return fmt.Errorf("error exporting metrics: %v", err)
+		}
+	}
+
+	if *summaryPath != "" {
+		if err := samplesFromOrders(orders, stages).writeSummary(*summaryPath); err != nil {
+			return fmt.Errorf("error exporting summary: %v", err)
+		}
+	}
+	if *storePath != "" {
+		date := *tradeDate
+		if date == "" {
+			if date = tradeDateOf(orders); date == "" {
+				return fmt.Errorf("error saving to store: no completed orders to infer the trade date from, use -date")
+			}
+		}
+		if err := (latencyStore{*storePath}).put(date, logFilePath, orders, stages); err != nil {
+			return fmt.Errorf("error saving to store: %v", err)
+		}
+		fmt.Println("Trade date", date, "saved to", *storePath)
+	}
+	if report != nil {
+		data := newReportData(logFilePath, orders, len(analyzer.Orders()), report.orphans, stats, stages, calendar, groupTables)
+		if err := writeHtmlReport(*htmlPath, data); err != nil {
+			return fmt.Errorf("error exporting HTML report: %v", err)
+		}
+	}
+	if *topN > 0 {
+		if err := exportExemplars(orders, logFilePath, *exemplarPath, *topN, *contextLines); err != nil {
+			return fmt.Errorf("error exporting exemplars: %v", err)
+		}
+	}
+
+	fmt.Println("Orders exported successfully to", outputCsvPath)
+
+	if *slaPath != "" {
+		return checkSla(slaConfig, orders, calendar, *slaViolationsPath)
+	}
+	return nil
+}
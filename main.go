@@ -1,365 +1,158 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
-	"sort"
-	"strconv"
 	"strings"
-	"time"
 )
 
-var reTime, reClOrderId, reMatchOrderID, reAccount *regexp.Regexp
+// 版本号，发布时可通过 -ldflags "-X main.version=x.y.z" 覆盖
+var version = "0.1.0"
 
-func init() {
-	reTime = regexp.MustCompile(`^D\d{4} (\d{2}/\d{2}/\d{4} \d{2}:\d{2}:\d{2}\.\d{6})`)
-	reClOrderId = regexp.MustCompile(`\|11=([^|]+)\|`)
-	reMatchOrderID = regexp.MustCompile(`\|198=([^|]+)\|`)
-	reAccount = regexp.MustCompile(`\|1=([^|]+)\|`)
-}
-
-type JnetConfirmedOrder struct {
-	ClOrderId            string
-	Account              string
-	RecvClientTime       string
-	SendMatchTime        string
-	RecvMatchFillTime    string
-	RecvMatchCorrectTime string
-	FinalReturnTime      string
-
-	OmsCostTime1  string
-	MatchCostTime string
-	OmsCostTime2  string
-	JnetCostTime  string
+// 退出码：调度系统依据退出码判断运行结果
+const (
+	exitOK    = 0
+	exitError = 1 // 运行出错，如文件无法读取
+	exitUsage = 2 // 命令行参数错误
+	exitCheck = 3 // 运行成功但检查未通过：SLA未达标或发现性能回退
+)
 
-	TotalCostTime string
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
 }
 
-func isJnetConfirmed(line string) bool {
-	if strings.Contains(line, "|35=8|") && strings.Contains(line, "|20=2|") && strings.Contains(line, "|39=2|") && strings.Contains(line, "8=FIX") {
-		return true
-	}
-	return false
+var commands = []command{
+	{"latency", "per-order OMS latency from the OMS log (formerly v8)", runLatency},
+	{"batch", "per-day latency for every OMS log in a directory", runBatch},
+	{"compare", "compare the latency of two runs and flag significant regressions", runCompare},
+	{"trend", "daily order volume and p50/p99 from the history store", runTrend},
+	{"trace", "timeline of every message of one order", runTrace},
+	{"chrome-trace", "time window of the OMS log as Chrome Trace Event JSON for Perfetto", runChromeTrace},
+	{"decode", "pretty-print FIX messages of log lines with names from the data dictionary", runDecode},
+	{"fixjson", "FIX messages of a log to JSONL in the FIX JSON encoding", runFixJson},
+	{"extract", "messages matching a filter expression to CSV, JSONL or Parquet", runExtract},
+	{"follow", "tail a growing OMS log and print a rolling per-minute latency table", runFollow},
+	{"orders", "orders received from HRT sessions to JSONL (formerly v9)", runOrders},
+	{"corrections", "JNET corrections sent by the matching engine to JSONL (formerly v10)", runCorrections},
+	{"version", "print the version", runVersion},
 }
 
-func parseLine(line string) (JnetConfirmedOrder, error) {
-	returnTimeMatches := reTime.FindStringSubmatch(line)
-	clOrderIdMatches := reClOrderId.FindStringSubmatch(line)
-	accountMatches := reAccount.FindStringSubmatch(line)
-
-	order := JnetConfirmedOrder{}
-
-	if len(returnTimeMatches) > 1 {
-		order.FinalReturnTime = returnTimeMatches[1]
-	} else {
-		return JnetConfirmedOrder{}, fmt.Errorf("finalReturnTime not found")
-	}
-
-	if len(clOrderIdMatches) > 1 {
-		order.ClOrderId = clOrderIdMatches[1]
-	} else {
-		return JnetConfirmedOrder{}, fmt.Errorf("clOrderId not found")
-	}
-
-	if len(accountMatches) > 1 {
-		order.Account = accountMatches[1]
-	} else {
-		return JnetConfirmedOrder{}, fmt.Errorf("account not found")
-	}
-
-	return order, nil
-}
-
-func getAllJnetConfirmedOrder(filename string) (map[string]JnetConfirmedOrder, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	orders := make(map[string]JnetConfirmedOrder)
-	scanner := bufio.NewScanner(file)
-
-	count := 0
-	for scanner.Scan() {
-		// 替换每一行中的'\x01'为'|'
-		line := strings.Replace(scanner.Text(), "\x01", "|", -1)
-		if isJnetConfirmed(line) {
-			count += 1
-			order, err := parseLine(line)
-			if err != nil {
-				fmt.Printf("parse error: %v\n", err)
-				continue // 解析错误时跳过该行
-			}
-			orders[order.ClOrderId] = order
-		}
-	}
-
-	fmt.Println("JNET Correction Order Count: ", count)
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: v8 <command> [options] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
 	}
-
-	return orders, nil
+	fmt.Fprintf(w, "\nRun 'v8 <command> --help' for the options of a command.\nVersion: %s\n", version)
 }
 
-func exportToJsonl(orders map[string]JnetConfirmedOrder, jsonlFilename string) error {
-	file, err := os.Create(jsonlFilename)
-	if err != nil {
-		return fmt.Errorf("error creating file: %v", err)
-	}
-	defer file.Close()
-
-	for _, order := range orders {
-		jsonBytes, err := json.Marshal(order)
-		if err != nil {
-			return fmt.Errorf("error marshalling to JSON: %v", err)
-		}
-		_, err = file.Write(jsonBytes)
-		if err != nil {
-			return fmt.Errorf("error writing to file: %v", err)
-		}
-		// 写入换行符以满足jsonl格式要求
-		_, err = file.WriteString("\n")
-		if err != nil {
-			return fmt.Errorf("error writing newline to file: %v", err)
-		}
-	}
-
+func runVersion(args []string) error {
+	fmt.Println("v8", version)
 	return nil
 }
 
-func fillSendTime(orders map[string]JnetConfirmedOrder, filename string) error {
-	// 打开文件
-	file, err := os.Open(filename)
-	if err != nil {
-		return fmt.Errorf("error opening file: %v", err)
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.Replace(scanner.Text(), "\x01", "|", -1)
-		if strings.Contains(line, "|35=D|") && strings.Contains(line, "8=FIX") {
-			clOrderIdMatches := reClOrderId.FindStringSubmatch(line)
-			recvTimeMatches := reTime.FindStringSubmatch(line)
-			if len(clOrderIdMatches) > 1 && len(recvTimeMatches) > 1 {
-				order, exists := orders[clOrderIdMatches[1]]
-				if exists && order.RecvClientTime == "" {
-					// 修改结构体字段
-					order.RecvClientTime = recvTimeMatches[1]
-					orders[clOrderIdMatches[1]] = order
-				}
-			}
-		}
+// 参数错误，退出码为exitUsage
+type usageError struct {
+	msg string
+}
 
-		if strings.Contains(line, "|35=D|") && strings.Contains(line, "|49=router_branch|") && strings.Contains(line, "|56=exch_sim|") {
-			matchOrderIDResults := reMatchOrderID.FindStringSubmatch(line)
-			timeMatches := reTime.FindStringSubmatch(line)
-			if len(matchOrderIDResults) > 1 && len(timeMatches) > 1 {
-				order, exists := orders[matchOrderIDResults[1]]
-				if exists && order.SendMatchTime == "" {
-					// 修改结构体字段
-					order.SendMatchTime = timeMatches[1]
-					orders[matchOrderIDResults[1]] = order
-				}
-			}
-		}
+func (e usageError) Error() string {
+	return e.msg
+}
 
-		if strings.Contains(line, "|150=2|") && strings.Contains(line, "|49=exch_sim|") && strings.Contains(line, "|56=router_branch|") {
-			matchOrderIDResults := reMatchOrderID.FindStringSubmatch(line)
-			timeMatches := reTime.FindStringSubmatch(line)
-			if len(matchOrderIDResults) > 1 && len(timeMatches) > 1 {
-				order, exists := orders[matchOrderIDResults[1]]
-				if exists && order.RecvMatchFillTime == "" {
-					// 修改结构体字段
-					order.RecvMatchFillTime = timeMatches[1]
-					orders[matchOrderIDResults[1]] = order
-				}
-			}
-		}
+// 检查未通过，退出码为exitCheck
+type checkError struct {
+	msg string
+}
 
-		if strings.Contains(line, "|150=G|") && strings.Contains(line, "|49=exch_sim|") && strings.Contains(line, "|56=router_branch|") {
-			matchOrderIDResults := reMatchOrderID.FindStringSubmatch(line)
-			timeMatches := reTime.FindStringSubmatch(line)
-			if len(matchOrderIDResults) > 1 && len(timeMatches) > 1 {
-				order, exists := orders[matchOrderIDResults[1]]
-				if exists && order.RecvMatchCorrectTime == "" {
-					// 修改结构体字段
-					order.RecvMatchCorrectTime = timeMatches[1]
-					orders[matchOrderIDResults[1]] = order
-				}
-			}
-		}
+func (e checkError) Error() string {
+	return e.msg
+}
 
-	}
+func usageErrorf(fs *flag.FlagSet, format string, args ...any) error {
+	err := usageError{fmt.Sprintf(format, args...)}
+	fmt.Fprintln(fs.Output(), "Error:", err)
+	fs.Usage()
+	return err
+}
 
-	// 检查扫描过程中的错误
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error scanning file: %v", err)
+func newFlagSet(name string, synopsis string, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: v8 %s %s\n\n%s\n\nOptions:\n", name, synopsis, description)
+		fs.PrintDefaults()
 	}
-
-	return nil
+	return fs
 }
 
-func fillCostTime(orders map[string]JnetConfirmedOrder) error {
-	// 定义时间字符串的解析格式
-	const layout = "01/02/2006 15:04:05.000000" // 注意Go中月份和日的位置是固定的
-
-	for i, order := range orders {
-		// 解析RecvClientTime
-		recvClientTime, err := time.Parse(layout, order.RecvClientTime)
-		if err != nil {
-			fmt.Printf("error parsing RecvClientTime for order %s: %v", order.ClOrderId, err)
-			return fmt.Errorf("error parsing RecvClientTime for order %s: %v", order.ClOrderId, err)
-		}
-
-		// 解析SendMatchTime
-		sendMatchTime, err := time.Parse(layout, order.SendMatchTime)
-		if err != nil {
-			fmt.Printf("error parsing SendMatchTime for order %s: %v", order.ClOrderId, err)
-			return fmt.Errorf("error parsing SendMatchTime for order %s: %v", order.ClOrderId, err)
-		}
-
-		// 解析RecvMatchFillTime
-		RecvMatchFillTime, err := time.Parse(layout, order.RecvMatchFillTime)
-		if err != nil {
-			fmt.Printf("error parsing RecvMatchFillTime for order %s: %v", order.ClOrderId, err)
-			return fmt.Errorf("error parsing RecvMatchFillTime for order %s: %v", order.ClOrderId, err)
+// 解析选项并返回位置参数，选项可以出现在位置参数之后，"--"之后的参数均视为位置参数
+func parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err.Error()}
 		}
-
-		// 解析RecvMatchCorrectTime
-		RecvMatchCorrectTime, err := time.Parse(layout, order.RecvMatchCorrectTime)
-		if err != nil {
-			fmt.Printf("error parsing RecvMatchCorrectTime for order %s: %v", order.ClOrderId, err)
-			return fmt.Errorf("error parsing RecvMatchCorrectTime for order %s: %v", order.ClOrderId, err)
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
 		}
-
-		// 解析FinalReturnTime
-		finalReturnTime, err := time.Parse(layout, order.FinalReturnTime)
-		if err != nil {
-			fmt.Printf("error parsing FinalReturnTime for order %s: %v", order.ClOrderId, err)
-			return fmt.Errorf("error parsing FinalReturnTime for order %s: %v", order.ClOrderId, err)
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
 		}
-
-		// 计算OmsCostTime1
-		order.OmsCostTime1 = fmt.Sprintf("%.6f", sendMatchTime.Sub(recvClientTime).Seconds())
-		// 计算MatchCostTime
-		order.MatchCostTime = fmt.Sprintf("%.6f", RecvMatchFillTime.Sub(sendMatchTime).Seconds())
-		// 计算OmsCostTime2
-		order.OmsCostTime2 = fmt.Sprintf("%.6f", finalReturnTime.Sub(RecvMatchCorrectTime).Seconds())
-		// 计算JnetCostTime
-		order.JnetCostTime = fmt.Sprintf("%.6f", RecvMatchCorrectTime.Sub(RecvMatchFillTime).Seconds())
-		// 更新TotalCostTime
-		order.TotalCostTime = fmt.Sprintf("%.6f", finalReturnTime.Sub(recvClientTime).Seconds())
-
-		// 更新map中的订单
-		orders[i] = order
+		positional = append(positional, rest[0])
+		args = rest[1:]
 	}
-
-	return nil
 }
 
-func exportCsv(orders map[string]JnetConfirmedOrder, csvFilename string) error {
-	file, err := os.Create(csvFilename)
-	if err != nil {
-		return fmt.Errorf("error creating CSV file: %v", err)
+func run(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return exitUsage
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
-
-	if err := writer.Write([]string{"Account", "ClientOrderID", "OmsCostTime1", "MatchCostTime", "OmsCostTime2", "JnetCostTime", "TotalCostTime"}); err != nil {
-		return fmt.Errorf("error writing header to CSV file: %v", err)
+	name := args[0]
+	switch strings.TrimLeft(name, "-") {
+	case "h", "help":
+		usage(os.Stdout)
+		return exitOK
+	case "version":
+		name = "version"
 	}
 
-	// 创建切片用于排序
-	orderSlice := make([]JnetConfirmedOrder, 0, len(orders))
-	for _, order := range orders {
-		orderSlice = append(orderSlice, order)
-	}
-
-	// 根据RecvTime排序订单
-	sort.Slice(orderSlice, func(i, j int) bool {
-		return orderSlice[i].RecvClientTime < orderSlice[j].RecvClientTime
-	})
-
-	// 假设writer是已经被初始化的csv.Writer
-	for _, order := range orderSlice {
-		// 准备要写入CSV的记录
-		record := []string{order.Account, order.ClOrderId}
-
-		// 需要转换和格式化的字段
-		timeFields := []string{order.OmsCostTime1, order.MatchCostTime, order.OmsCostTime2, order.JnetCostTime, order.TotalCostTime}
-
-		// 遍历每个时间字段进行处理
-		for _, field := range timeFields {
-			costTimeSeconds, err := strconv.ParseFloat(field, 64)
-			if err != nil {
-				return fmt.Errorf("error parsing time field to float: %v", err)
-			}
-			// 将秒转换为毫秒并格式化为字符串
-			costTimeMilliseconds := fmt.Sprintf("%.3f", costTimeSeconds*1000)
-			// 将处理后的时间添加到记录中
-			record = append(record, costTimeMilliseconds)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
 		}
-
-		// 写入一行CSV数据
-		if err := writer.Write(record); err != nil {
-			return fmt.Errorf("error writing record to CSV file: %v", err)
+		err := cmd.run(args[1:])
+		var usageErr usageError
+		var checkErr checkError
+		switch {
+		case err == nil, errors.Is(err, flag.ErrHelp):
+			return exitOK
+		case errors.As(err, &usageErr):
+			return exitUsage
+		case errors.As(err, &checkErr):
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitCheck
+		default:
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return exitError
 		}
 	}
 
-	// 确保所有的缓存数据都被写入文件
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		return fmt.Errorf("error flushing data to CSV file: %v", err)
-	}
-
-	return nil
+	fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", name)
+	usage(os.Stderr)
+	return exitUsage
 }
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Println("Usage: <program> <logFilePath> <outputCsvPath> \nVersion: 0.0.2")
-		return
-	}
-	logFilePath := os.Args[1]
-	outputCsvPath := os.Args[2]
-
-	// logFilePath := "/home/jicheng.tang/work/v8/oms_20240517.log"
-	// outputCsvPath := "./v8-20240517-3.csv"
-
-	orders, err := getAllJnetConfirmedOrder(logFilePath)
-	if err != nil {
-		fmt.Printf("Error getting orders: %v\n", err)
-		return
-	}
-
-	if fillSendTime(orders, logFilePath) != nil {
-		fmt.Printf("Error filling send time: %v\n", err)
-		return
-	}
-
-	if fillCostTime(orders) != nil {
-		fmt.Printf("Error filling cost time: %v\n", err)
-		return
-	}
-
-	if exportCsv(orders, outputCsvPath) != nil {
-		fmt.Printf("Error exporting to CSV: %v\n", err)
-		return
-	}
-	// if exportToJsonl(orders, "abc.jsonl") != nil {
-	// 	fmt.Printf("Error exportToJsonl: %v\n", err)
-	// 	return
-	// }
-
-	fmt.Println("Orders exported successfully to", outputCsvPath)
+	os.Exit(run(os.Args[1:]))
 }
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+
+	"v8/fixlog"
+	"v8/internal/metrics"
+)
+
+// 延迟直方图的桶上界(秒)，覆盖10us到1s
+var latencyBuckets = []float64{
+	0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005, 0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5, 1,
+}
+
+// 订单完成时按阶段记录耗时直方图，标签为account/symbol/session(客户端SenderCompID)
+type latencyMetrics struct {
+	fixlog.NopConsumer
+	registry *metrics.Registry
+	stages   []fixlog.Stage
+
+	latency     *metrics.Histogram
+	orphans     *metrics.Counter
+	lines       *metrics.Counter
+	parseErrors *metrics.Counter
+	evicted     *metrics.Counter
+}
+
+func newLatencyMetrics(stages []fixlog.Stage) *latencyMetrics {
+	registry := metrics.NewRegistry()
+	return &latencyMetrics{
+		registry:    registry,
+		stages:      stages,
+		latency:     registry.NewHistogram("oms_stage_latency_seconds", "Per-order OMS latency by stage.", latencyBuckets, "stage", "account", "symbol", "session"),
+		orphans:     registry.NewCounter("oms_orphan_orders", "JNET corrections from exch_sim never confirmed to the client.", "account", "symbol", "session"),
+		lines:       registry.NewCounter("oms_log_lines", "OMS log lines read."),
+		parseErrors: registry.NewCounter("oms_parse_errors", "Milestone lines missing the prefix time or the ClOrdID."),
+		evicted:     registry.NewCounter("oms_evicted_orders", "Orders dropped after the order timeout without completing."),
+	}
+}
+
+func (m *latencyMetrics) OnOrderComplete(order *fixlog.Order) error {
+	for _, stage := range m.stages {
+		if cost, ok := stage.Cost(order); ok {
+			m.latency.Observe(cost.Seconds(), stage.Name, order.Account, order.Symbol, order.ClientCompID)
+		}
+	}
+	return nil
+}
+
+func (m *latencyMetrics) OnOrphan(order *fixlog.Order) error {
+	m.orphans.Add(1, order.Account, order.Symbol, order.ClientCompID)
+	return nil
+}
+
+// 同步Analyzer的累计计数
+func (m *latencyMetrics) updateStats(stats fixlog.Stats) {
+	m.lines.Set(float64(stats.Lines))
+	m.parseErrors.Set(float64(stats.ParseErrors))
+	m.evicted.Set(float64(stats.Evicted))
+}
+
+// 在addr上提供/metrics，监听失败时返回错误
+func (m *latencyMetrics) serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.registry.Handler())
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			fmt.Fprintln(os.Stderr, "Error: metrics server:", err)
+		}
+	}()
+	fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics\n", listener.Addr())
+	return nil
+}
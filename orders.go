@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"v8/internal/logline"
+)
+
+// 从HRT会话收到的订单(原v9)
+type Order struct {
+	LogTime   string `fix:"time"`
+	OrderType string `fix:"35"`
+	ClOrderId string `fix:"11"`
+	Account   string `fix:"1"`
+	Symbol    string `fix:"55"`
+
+	Decoded   map[string]string `json:",omitempty"` // -decode时附加的报文类型名
+	Reference map[string]string `json:",omitempty"` // -accounts、-symbols时附加的参考数据属性
+}
+
+// 从HRT会话收到的带ClOrdID和Symbol的报文
+const orderFilter = "dir=recv and 49=HRT* and 11=* and 55=*"
+
+// 扫描日志中满足filter的报文，按ClOrdID去重(后出现的覆盖先出现的)
+func scanOrders[T any](filename string, filter *lineFilter, clOrderId func(T) string) (map[string]T, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	orders := make(map[string]T)
+	scanner := logline.NewScanner(file)
+
+	count := 0
+	for scanner.Scan() {
+		line := scanner.Text()
+		if logline.IsFix(line) && filter.match(line, scanner.LineNo()) {
+			count += 1
+			var order T
+			if err := logline.Unmarshal(line, &order); err != nil {
+				fmt.Printf("parse error: %v\n", err)
+				continue // 解析错误时跳过该行
+			}
+			orders[clOrderId(order)] = order
+		}
+	}
+
+	fmt.Println("Order Count: ", count)
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %v", err)
+	}
+
+	return orders, nil
+}
+
+// 将map转换为按日志时间排序的slice
+func sortByLogTime[T any](orders map[string]T, logTime func(T) string) []T {
+	ordersSlice := make([]T, 0, len(orders))
+	for _, order := range orders {
+		ordersSlice = append(ordersSlice, order)
+	}
+
+	sort.Slice(ordersSlice, func(i, j int) bool {
+		t1, err1 := logline.ParseTime(logTime(ordersSlice[i]))
+		t2, err2 := logline.ParseTime(logTime(ordersSlice[j]))
+		if err1 != nil || err2 != nil {
+			fmt.Printf("Error parsing time: %v, %v\n", err1, err2)
+			return false
+		}
+		return t1.Before(t2)
+	})
+	return ordersSlice
+}
+
+// 根据OrderType修改其值
+func orderTypeName(orderType string) string {
+	switch orderType {
+	case "D":
+		return "New"
+	case "F":
+		return "Cancel"
+	}
+	return orderType
+}
+
+func runOrders(args []string) error {
+	fs := newFlagSet("orders", "[options] <logFilePath> <outputJsonlPath>", "Export orders received from HRT sessions to JSONL, sorted by log time.")
+	parquetPath := fs.String("parquet", "", "also export the records to this Parquet file, with the log time as int64 nanoseconds")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to convert it to UTC in Parquet")
+	decode := fs.Bool("decode", false, "add a Decoded object with the message type name from the data dictionary to each JSONL record")
+	filterValue := fs.String("filter", "", lineFilterUsage+", in addition to the HRT order selection; Account./Symbol. attributes of -accounts/-symbols can be used too")
+	by := fs.String("by", "", "comma separated Account, Symbol or reference attributes, print the record count per value of each, e.g. Symbol.market")
+	dictPaths := addDictFlag(fs)
+	refFlags := addReferenceFlags(fs)
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <outputJsonlPath>")
+	}
+	logFilePath := positional[0]
+	outputJsonlPath := positional[1]
+	dict, err := dictPaths.load()
+	if err != nil {
+		return err
+	}
+	ref, err := refFlags.load()
+	if err != nil {
+		return err
+	}
+	groups := splitList(*by)
+	if err := checkRecordGroups(ref, groups); err != nil {
+		return usageErrorf(fs, "-by: %v", err)
+	}
+	filter, err := newLineFilter(dict, ref, orderFilter, *filterValue)
+	if err != nil {
+		return usageErrorf(fs, "-filter: %v", err)
+	}
+
+	orders, err := scanOrders(logFilePath, filter, func(order Order) string { return order.ClOrderId })
+	if err != nil {
+		return fmt.Errorf("error getting orders: %v", err)
+	}
+
+	ordersSlice := sortByLogTime(orders, func(order Order) string { return order.LogTime })
+	for i := range ordersSlice {
+		if *decode {
+			ordersSlice[i].Decoded = decodeValues(dict, nil, "", "35", ordersSlice[i].OrderType)
+		}
+		ordersSlice[i].OrderType = orderTypeName(ordersSlice[i].OrderType)
+		ordersSlice[i].Reference = ref.values(ordersSlice[i].Account, ordersSlice[i].Symbol)
+	}
+	if err := logline.WriteJsonl(outputJsonlPath, ordersSlice); err != nil {
+		return fmt.Errorf("error exporting to JSONL: %v", err)
+	}
+	if *parquetPath != "" {
+		loc, err := time.LoadLocation(*logTimeZone)
+		if err != nil {
+			return fmt.Errorf("error loading time zone: %v", err)
+		}
+		if err := exportOrdersParquet(ordersSlice, *parquetPath, loc, ref.attributes()); err != nil {
+			return fmt.Errorf("error exporting to Parquet: %v", err)
+		}
+	}
+
+	writeGroupCounts(os.Stdout, groups, len(ordersSlice), func(i int, name string) string {
+		return recordGroup(ref, name, ordersSlice[i].Account, ordersSlice[i].Symbol)
+	})
+	fmt.Println("Orders exported successfully to", outputJsonlPath)
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/otlp"
+)
+
+// 每批的订单数：写入文件时每批一行，推送时每批一次请求
+const otlpBatchOrders = 256
+
+// 订单生命周期中的阶段span，按发生顺序
+var otlpStages = []struct {
+	stage fixlog.Stage
+	name  string
+	kind  otlp.SpanKind
+}{
+	{fixlog.OmsCostTime1, "OMS inbound", otlp.SpanKindInternal},
+	{fixlog.MatchCostTime, "matching", otlp.SpanKindClient},
+	{fixlog.JnetCostTime, "JNET correction", otlp.SpanKindInternal},
+	{fixlog.OmsCostTime2, "OMS outbound", otlp.SpanKindInternal},
+}
+
+// 由订单确定trace ID，同一日志重复导出时ID不变，collector侧可去重
+func orderTraceID(order *fixlog.Order, recv time.Time) [16]byte {
+	var id [16]byte
+	sum := sha256.Sum256([]byte(order.ClOrdID + "|" + recv.UTC().Format(time.RFC3339Nano)))
+	copy(id[:], sum[:])
+	return id
+}
+
+func spanID(traceID [16]byte, name string) [8]byte {
+	var id [8]byte
+	sum := sha256.Sum256(append(traceID[:], name...))
+	copy(id[:], sum[:])
+	return id
+}
+
+// 值为空的属性不输出
+func appendAttributes(attributes []otlp.KeyValue, pairs ...string) []otlp.KeyValue {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			attributes = append(attributes, otlp.KeyValue{Key: pairs[i], Value: pairs[i+1]})
+		}
+	}
+	return attributes
+}
+
+// 一笔订单的根span(收到客户端订单到返回JNET确认)和各阶段的子span
+func orderSpans(order *fixlog.Order) []otlp.Span {
+	recv, ok := order.Milestone(fixlog.RecvClient)
+	if !ok {
+		return nil
+	}
+	final, ok := order.Milestone(fixlog.FinalReturn)
+	if !ok {
+		return nil
+	}
+
+	traceID := orderTraceID(order, recv.Time)
+	root := otlp.Span{TraceID: traceID, SpanID: spanID(traceID, "order"), Name: "order", Kind: otlp.SpanKindServer, Start: recv.Time, End: final.Time}
+	root.Attributes = appendAttributes(nil,
+		"fix.cl_ord_id", order.ClOrdID,
+		"fix.account", order.Account,
+		"fix.symbol", order.Symbol,
+		"fix.side", order.Side,
+		"fix.order_qty", order.OrderQty,
+		"fix.client_comp_id", order.ClientCompID)
+	if order.Final != nil {
+		root.Attributes = appendAttributes(root.Attributes, "fix.oms_comp_id", order.Final.SenderCompID)
+	}
+	spans := []otlp.Span{root}
+
+	for _, s := range otlpStages {
+		from, okFrom := order.Milestone(s.stage.From)
+		to, okTo := order.Milestone(s.stage.To)
+		if !okFrom || !okTo {
+			continue
+		}
+		span := otlp.Span{TraceID: traceID, SpanID: spanID(traceID, s.stage.Name), ParentSpanID: root.SpanID, Name: s.name, Kind: s.kind, Start: from.Time, End: to.Time}
+		span.Attributes = appendAttributes(nil, "v8.stage", s.stage.Name, "fix.cl_ord_id", order.ClOrdID)
+		// 与exch_sim之间的阶段附上路由会话
+		if (s.stage == fixlog.MatchCostTime || s.stage == fixlog.JnetCostTime) && order.Fill != nil {
+			span.Attributes = appendAttributes(span.Attributes,
+				"fix.match_cl_ord_id", order.MatchClOrdID,
+				"fix.sender_comp_id", order.Fill.TargetCompID,
+				"fix.target_comp_id", order.Fill.SenderCompID)
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// 每笔订单完成时生成span，攒够一批后写入文件或推送
+type otlpConsumer struct {
+	fixlog.NopConsumer
+	resource []otlp.KeyValue
+	send     func(body []byte) error
+	close    func() error
+	spans    []otlp.Span
+	orders   int
+	Exported int // 已导出的订单数
+}
+
+func newOtlpConsumer(service string, send func(body []byte) error, close func() error) *otlpConsumer {
+	resource := []otlp.KeyValue{{Key: "service.name", Value: service}}
+	return &otlpConsumer{resource: resource, send: send, close: close}
+}
+
+// 写入文件，每行一个请求，可由collector的otlpjsonfile receiver读取
+func newOtlpFileConsumer(filename string, service string) (*otlpConsumer, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	writer := bufio.NewWriter(file)
+	send := func(body []byte) error {
+		if _, err := writer.Write(append(body, '\n')); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+		return nil
+	}
+	close := func() error {
+		defer file.Close()
+		if err := writer.Flush(); err != nil {
+			return fmt.Errorf("error writing to file: %v", err)
+		}
+		return file.Close()
+	}
+	return newOtlpConsumer(service, send, close), nil
+}
+
+func newOtlpHttpConsumer(url string, service string) *otlpConsumer {
+	client := &http.Client{Timeout: 10 * time.Second}
+	send := func(body []byte) error { return otlp.Post(client, url, body) }
+	return newOtlpConsumer(service, send, func() error { return nil })
+}
+
+func (c *otlpConsumer) OnOrderComplete(order *fixlog.Order) error {
+	spans := orderSpans(order)
+	if len(spans) == 0 {
+		return nil
+	}
+	c.spans = append(c.spans, spans...)
+	c.orders += 1
+	if c.orders >= otlpBatchOrders {
+		return c.flush()
+	}
+	return nil
+}
+
+func (c *otlpConsumer) flush() error {
+	if c.orders == 0 {
+		return nil
+	}
+	body, err := otlp.MarshalRequest(c.resource, "v8", version, c.spans)
+	if err != nil {
+		return err
+	}
+	if err := c.send(body); err != nil {
+		return err
+	}
+	c.Exported += c.orders
+	c.spans, c.orders = c.spans[:0], 0
+	return nil
+}
+
+func (c *otlpConsumer) Close() error {
+	if err := c.flush(); err != nil {
+		c.close()
+		return err
+	}
+	return c.close()
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 订单C0的完整生命周期
+const otelLog = `D0411 04/11/2024 09:00:00.600000 1234 session.cpp:88] recv 8=FIX.4.2|9=95|35=D|49=HRT01|56=OMS|34=1|52=20240411-09:00:00.599905|11=C0|1=ACC3|55=7203|54=1|38=100|44=1000|10=000|
+D0411 04/11/2024 09:00:00.605100 1234 session.cpp:88] send 8=FIX.4.2|9=74|35=D|49=router_branch|56=exch_sim|11=R0|198=C0|1=ACC3|55=7203|54=1|38=100|10=000|
+D0411 04/11/2024 09:00:00.605598 1234 session.cpp:88] recv 8=FIX.4.2|9=146|35=8|49=exch_sim|56=router_branch|52=20240411-09:00:00.605590|60=20240411-09:00:00.605196|11=R0|198=C0|17=E0|37=O0|150=2|39=2|20=0|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.621642 1234 session.cpp:88] recv 8=FIX.4.2|9=120|35=8|49=exch_sim|56=router_branch|52=20240411-09:00:00.621638|11=R0|198=C0|17=E0c|19=E0|37=O0|150=G|39=2|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.625075 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|52=20240411-09:00:00.625075|11=C0|1=ACC3|55=7203|17=X0c|19=X0|37=O0|150=G|39=2|20=2|10=000|
+`
+
+// 导出文件中的一个span，只取需要检查的字段
+type otelTestSpan struct {
+	TraceID           string `json:"traceId"`
+	SpanID            string `json:"spanId"`
+	ParentSpanID      string `json:"parentSpanId"`
+	Name              string `json:"name"`
+	Kind              int    `json:"kind"`
+	StartTimeUnixNano string `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string `json:"endTimeUnixNano"`
+	Attributes        []struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	} `json:"attributes"`
+}
+
+func (s otelTestSpan) attributes() map[string]string {
+	attributes := make(map[string]string)
+	for _, attribute := range s.Attributes {
+		attributes[attribute.Key] = attribute.Value.StringValue
+	}
+	return attributes
+}
+
+func TestLatencyOtlpExport(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "oms.log")
+	otlpPath := filepath.Join(dir, "traces.jsonl")
+	if err := os.WriteFile(logPath, []byte(strings.ReplaceAll(otelLog, "|", "\x01")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runLatency([]string{"-logtz", "UTC", "-otlp", otlpPath, "-otlp-service", "oms-test", logPath, filepath.Join(dir, "out.csv")}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(otlpPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var request struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otelTestSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.Unmarshal(data, &request); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+	if !strings.Contains(string(data), `"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"oms-test"}}]}`) {
+		t.Errorf("no service.name resource attribute: %s", data)
+	}
+	if len(request.ResourceSpans) != 1 || len(request.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request: %s", data)
+	}
+	spans := request.ResourceSpans[0].ScopeSpans[0].Spans
+
+	// trace ID由ClOrdID和收到客户端订单的UTC时间确定，span ID由trace ID和阶段名确定
+	traceSum := sha256.Sum256([]byte("C0|2024-04-11T09:00:00.6Z"))
+	traceID := hex.EncodeToString(traceSum[:16])
+	spanID := func(name string) string {
+		sum := sha256.Sum256(append(traceSum[:16:16], name...))
+		return hex.EncodeToString(sum[:8])
+	}
+	rootID := spanID("order")
+
+	want := []struct {
+		name, id, parent string
+		kind             int
+		start, end       string
+	}{
+		{"order", rootID, "", 2, "1712826000600000000", "1712826000625075000"},
+		{"OMS inbound", spanID("OmsCostTime1"), rootID, 1, "1712826000600000000", "1712826000605100000"},
+		{"matching", spanID("MatchCostTime"), rootID, 3, "1712826000605100000", "1712826000605598000"},
+		{"JNET correction", spanID("JnetCostTime"), rootID, 1, "1712826000605598000", "1712826000621642000"},
+		{"OMS outbound", spanID("OmsCostTime2"), rootID, 1, "1712826000621642000", "1712826000625075000"},
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("%d spans, want %d: %s", len(spans), len(want), data)
+	}
+	for i, w := range want {
+		s := spans[i]
+		if s.Name != w.name || s.TraceID != traceID || s.SpanID != w.id || s.ParentSpanID != w.parent || s.Kind != w.kind {
+			t.Errorf("span %d = %s trace %s span %s parent %s kind %d, want %s trace %s span %s parent %s kind %d",
+				i, s.Name, s.TraceID, s.SpanID, s.ParentSpanID, s.Kind, w.name, traceID, w.id, w.parent, w.kind)
+		}
+		if s.StartTimeUnixNano != w.start || s.EndTimeUnixNano != w.end {
+			t.Errorf("%s: %s - %s, want %s - %s", s.Name, s.StartTimeUnixNano, s.EndTimeUnixNano, w.start, w.end)
+		}
+	}
+	// 时间戳按OTLP/JSON编码为字符串
+	if !strings.Contains(string(data), `"startTimeUnixNano":"1712826000600000000"`) {
+		t.Errorf("timestamps are not encoded as strings: %s", data)
+	}
+
+	wantAttributes := []map[string]string{
+		{"fix.cl_ord_id": "C0", "fix.account": "ACC3", "fix.symbol": "7203", "fix.side": "1", "fix.order_qty": "100", "fix.client_comp_id": "HRT01", "fix.oms_comp_id": "OMS"},
+		{"v8.stage": "OmsCostTime1", "fix.cl_ord_id": "C0"},
+		{"v8.stage": "MatchCostTime", "fix.cl_ord_id": "C0", "fix.match_cl_ord_id": "R0", "fix.sender_comp_id": "router_branch", "fix.target_comp_id": "exch_sim"},
+		{"v8.stage": "JnetCostTime", "fix.cl_ord_id": "C0", "fix.match_cl_ord_id": "R0", "fix.sender_comp_id": "router_branch", "fix.target_comp_id": "exch_sim"},
+		{"v8.stage": "OmsCostTime2", "fix.cl_ord_id": "C0"},
+	}
+	for i, want := range wantAttributes {
+		if got := spans[i].attributes(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s attributes = %v, want %v", spans[i].Name, got, want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/logline"
+	"v8/internal/parquet"
+)
+
+// Parquet文件及其Writer，Close时写出文件尾并关闭文件
+type parquetFile struct {
+	file   *os.File
+	writer *parquet.Writer
+}
+
+func createParquet(filename string, columns []parquet.Column) (*parquetFile, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error creating file: %v", err)
+	}
+	writer, err := parquet.NewWriter(file, columns, "v8 "+version)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &parquetFile{file: file, writer: writer}, nil
+}
+
+func (p *parquetFile) Close() error {
+	defer p.file.Close()
+	if err := p.writer.Close(); err != nil {
+		return err
+	}
+	return p.file.Close()
+}
+
+// 空字符串写为null
+func optionalString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// 数量等整数字段，无法解析时写为null
+func optionalInt(s string) any {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// 每笔订单完成时写入一行：订单字段、各时间点(Unix纳秒)、各阶段耗时(纳秒)和参考数据属性
+type parquetConsumer struct {
+	fixlog.NopConsumer
+	*parquetFile
+	milestones []fixlog.MilestoneKind
+	stages     []fixlog.Stage
+	ref        *referenceData
+}
+
+func newParquetConsumer(filename string, stages []fixlog.Stage, ref *referenceData) (*parquetConsumer, error) {
+	// 日志中的时间点加上导出的阶段用到的时间点
+	kinds := make(map[fixlog.MilestoneKind]bool)
+	for _, kind := range fixlog.LogMilestones {
+		kinds[kind] = true
+	}
+	for _, stage := range stages {
+		kinds[stage.From] = true
+		kinds[stage.To] = true
+	}
+	var milestones []fixlog.MilestoneKind
+	for kind := range kinds {
+		milestones = append(milestones, kind)
+	}
+	sort.Slice(milestones, func(i, j int) bool { return milestones[i] < milestones[j] })
+
+	columns := []parquet.Column{
+		{Name: "ClientOrderID", Type: parquet.String},
+		{Name: "Account", Type: parquet.DictString, Optional: true},
+		{Name: "Symbol", Type: parquet.DictString, Optional: true},
+		{Name: "Side", Type: parquet.DictString, Optional: true},
+		{Name: "OrderQty", Type: parquet.Int64, Optional: true},
+		{Name: "ClientCompID", Type: parquet.DictString, Optional: true},
+	}
+	for _, kind := range milestones {
+		columns = append(columns, parquet.Column{Name: kind.String(), Type: parquet.Timestamp, Optional: true})
+	}
+	for _, stage := range stages {
+		columns = append(columns, parquet.Column{Name: stage.Name, Type: parquet.Int64, Optional: true})
+	}
+	columns = append(columns, referenceColumns(ref.attributes())...)
+
+	file, err := createParquet(filename, columns)
+	if err != nil {
+		return nil, err
+	}
+	return &parquetConsumer{parquetFile: file, milestones: milestones, stages: stages, ref: ref}, nil
+}
+
+func (c *parquetConsumer) OnOrderComplete(order *fixlog.Order) error {
+	row := []any{
+		order.ClOrdID,
+		optionalString(order.Account),
+		optionalString(order.Symbol),
+		optionalString(order.Side),
+		optionalInt(order.OrderQty),
+		optionalString(order.ClientCompID),
+	}
+	for _, kind := range c.milestones {
+		if m, ok := order.Milestone(kind); ok {
+			row = append(row, m.Time.UnixNano())
+		} else {
+			row = append(row, nil)
+		}
+	}
+	for _, stage := range c.stages {
+		if cost, ok := stage.Cost(order); ok {
+			row = append(row, int64(cost))
+		} else {
+			row = append(row, nil)
+		}
+	}
+	row = referenceRow(row, c.ref.attributes(), c.ref.values(order.Account, order.Symbol))
+	return c.writer.Write(row...)
+}
+
+// 日志前缀时间换算为Unix纳秒，无法解析时写为null
+func logTimeNanos(value string, loc *time.Location) any {
+	t, err := time.ParseInLocation(logline.TimeLayout, value, loc)
+	if err != nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+// 参考数据属性列，可为空
+func referenceColumns(attributes []string) []parquet.Column {
+	var columns []parquet.Column
+	for _, name := range attributes {
+		columns = append(columns, parquet.Column{Name: name, Type: parquet.DictString, Optional: true})
+	}
+	return columns
+}
+
+// 按属性名依次追加取值，没有的写为null
+func referenceRow(row []any, attributes []string, values map[string]string) []any {
+	for _, name := range attributes {
+		row = append(row, optionalString(values[name]))
+	}
+	return row
+}
+
+func exportOrdersParquet(orders []Order, filename string, loc *time.Location, attributes []string) error {
+	file, err := createParquet(filename, append([]parquet.Column{
+		{Name: "LogTime", Type: parquet.Timestamp, Optional: true},
+		{Name: "OrderType", Type: parquet.DictString},
+		{Name: "ClOrderId", Type: parquet.String},
+		{Name: "Account", Type: parquet.DictString},
+		{Name: "Symbol", Type: parquet.DictString},
+	}, referenceColumns(attributes)...))
+	if err != nil {
+		return err
+	}
+	defer file.file.Close()
+	for _, order := range orders {
+		row := []any{logTimeNanos(order.LogTime, loc), order.OrderType, order.ClOrderId, order.Account, order.Symbol}
+		err := file.writer.Write(referenceRow(row, attributes, order.Reference)...)
+		if err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
+
+func exportCorrectionsParquet(orders []CorrectionOrder, filename string, loc *time.Location, attributes []string) error {
+	file, err := createParquet(filename, append([]parquet.Column{
+		{Name: "LogSendTime", Type: parquet.Timestamp, Optional: true},
+		{Name: "OrderType", Type: parquet.DictString},
+		{Name: "ClOrderId", Type: parquet.String},
+		{Name: "Account", Type: parquet.DictString},
+		{Name: "Symbol", Type: parquet.DictString},
+		{Name: "ExecID", Type: parquet.String},
+	}, referenceColumns(attributes)...))
+	if err != nil {
+		return err
+	}
+	defer file.file.Close()
+	for _, order := range orders {
+		row := []any{logTimeNanos(order.LogSendTime, loc), order.OrderType, order.ClOrderId, order.Account, order.Symbol, order.ExecID}
+		err := file.writer.Write(referenceRow(row, attributes, order.Reference)...)
+		if err != nil {
+			return err
+		}
+	}
+	return file.Close()
+}
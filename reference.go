@@ -0,0 +1,136 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"v8/internal/refdata"
+)
+
+// 参考数据属性名的前缀，如Account.desk、Symbol.market
+const (
+	accountPrefix = "Account."
+	symbolPrefix  = "Symbol."
+)
+
+// 账户和合约参考数据，属性在过滤、分组和导出中以Account.<列名>、Symbol.<列名>引用
+type referenceData struct {
+	accounts *refdata.Table // 以账户代码(1)为键
+	symbols  *refdata.Table // 以合约代码(55)为键
+}
+
+type referenceFlags struct {
+	accounts, symbols *string
+}
+
+func addReferenceFlags(fs *flag.FlagSet) *referenceFlags {
+	return &referenceFlags{
+		accounts: fs.String("accounts", "", "account reference data to join on Account(1), CSV or JSON keyed by Account, e.g. clientName, desk, strategy; its columns are Account.<column> in filters, -by and the exports"),
+		symbols:  fs.String("symbols", "", "symbol reference data to join on Symbol(55), CSV or JSON keyed by Symbol, e.g. market, sector, lotSize; its columns are Symbol.<column> in filters, -by and the exports"),
+	}
+}
+
+// 未指定的表为nil；不支持参考数据的命令传nil的*referenceData
+func (f *referenceFlags) load() (*referenceData, error) {
+	ref := &referenceData{}
+	var err error
+	if *f.accounts != "" {
+		if ref.accounts, err = refdata.Load(*f.accounts, "Account"); err != nil {
+			return nil, fmt.Errorf("error loading account reference data: %v", err)
+		}
+	}
+	if *f.symbols != "" {
+		if ref.symbols, err = refdata.Load(*f.symbols, "Symbol"); err != nil {
+			return nil, fmt.Errorf("error loading symbol reference data: %v", err)
+		}
+	}
+	return ref, nil
+}
+
+// 名称是否带参考数据属性的前缀
+func isReferenceAttribute(name string) bool {
+	return strings.HasPrefix(name, accountPrefix) || strings.HasPrefix(name, symbolPrefix)
+}
+
+// 按前缀取参考数据表及列名，不是参考数据属性时table为nil
+func (r *referenceData) resolve(name string) (table *refdata.Table, column string) {
+	if r == nil {
+		return nil, ""
+	}
+	if column, ok := strings.CutPrefix(name, accountPrefix); ok {
+		return r.accounts, column
+	}
+	if column, ok := strings.CutPrefix(name, symbolPrefix); ok {
+		return r.symbols, column
+	}
+	return nil, ""
+}
+
+// 是否为已加载的参考数据中的属性
+func (r *referenceData) hasAttribute(name string) bool {
+	table, column := r.resolve(name)
+	return table != nil && table.HasColumn(column)
+}
+
+// 按账户和合约代码取属性值
+func (r *referenceData) lookup(name string, account string, symbol string) (string, bool) {
+	table, column := r.resolve(name)
+	if table == nil {
+		return "", false
+	}
+	if strings.HasPrefix(name, accountPrefix) {
+		return table.Lookup(account, column)
+	}
+	return table.Lookup(symbol, column)
+}
+
+// 全部属性名，账户在前，各自按表中列的顺序
+func (r *referenceData) attributes() []string {
+	if r == nil {
+		return nil
+	}
+	var names []string
+	if r.accounts != nil {
+		for _, column := range r.accounts.Columns {
+			names = append(names, accountPrefix+column)
+		}
+	}
+	if r.symbols != nil {
+		for _, column := range r.symbols.Columns {
+			names = append(names, symbolPrefix+column)
+		}
+	}
+	return names
+}
+
+// 一笔订单的全部属性，用于JSONL中的Reference对象；没有属性时返回nil
+func (r *referenceData) values(account string, symbol string) map[string]string {
+	var values map[string]string
+	for _, name := range r.attributes() {
+		if value, ok := r.lookup(name, account, symbol); ok {
+			if values == nil {
+				values = make(map[string]string)
+			}
+			values[name] = value
+		}
+	}
+	return values
+}
+
+// 校验属性名，未加载对应参考数据或没有该列时报错；不是属性名或r为nil时不校验
+func (r *referenceData) check(name string) error {
+	if r == nil || !isReferenceAttribute(name) {
+		return nil
+	}
+	table, column := r.resolve(name)
+	switch {
+	case table == nil && strings.HasPrefix(name, accountPrefix):
+		return fmt.Errorf("%s needs -accounts", name)
+	case table == nil:
+		return fmt.Errorf("%s needs -symbols", name)
+	case !table.HasColumn(column):
+		return fmt.Errorf("unknown reference attribute %q, %s has %s", name, table.Key, strings.Join(table.Columns, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,465 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/stats"
+)
+
+// 报告中列出的孤儿订单上限
+const maxReportedOrphans = 100
+
+// 收集孤儿订单，分析完成后与已完成订单一起生成报告
+type reportConsumer struct {
+	fixlog.NopConsumer
+	orphans []*fixlog.Order
+}
+
+func (c *reportConsumer) OnOrphan(order *fixlog.Order) error {
+	c.orphans = append(c.orphans, order)
+	return nil
+}
+
+type stageReport struct {
+	Name                                string
+	Count, Missing                      int
+	HasCosts                            bool
+	Min, P50, P90, P99, P999, Max, Mean string
+	CDF, Histogram, TimeSeries          template.HTML
+	HistogramOverflow                   int    // 高于p99未画在直方图中的订单数
+	HistogramLimit                      string // 直方图的上限p99
+}
+
+// 按账户、交易时段或-by分组的一行
+type groupReport struct {
+	Name   string
+	Orders int
+	Costs  []string // 各阶段"p50 / p99"
+}
+
+func newGroupReport(name string, orders []*fixlog.Order, stages []fixlog.Stage) groupReport {
+	row := groupReport{Name: name, Orders: len(orders)}
+	for _, stage := range stages {
+		costs := stageCosts(orders, stage)
+		if len(costs) == 0 {
+			row.Costs = append(row.Costs, "-")
+			continue
+		}
+		row.Costs = append(row.Costs, formatMs(stats.Percentile(costs, 50))+" / "+formatMs(stats.Percentile(costs, 99)))
+	}
+	return row
+}
+
+// -by的一个分组键一张表
+type groupTable struct {
+	Name string
+	Rows []groupReport
+}
+
+func newGroupTable(name string, groups []orderGroup, stages []fixlog.Stage) groupTable {
+	table := groupTable{Name: name}
+	for _, group := range groups {
+		table.Rows = append(table.Rows, newGroupReport(group.name, group.orders, stages))
+	}
+	return table
+}
+
+type orphanReport struct {
+	ClOrdID, Account, Symbol, RecvMatchCorrectTime string
+}
+
+type reportData struct {
+	Source         string
+	Generated      string
+	Stats          fixlog.Stats
+	Completed      int
+	Incomplete     int
+	Orphans        []orphanReport
+	OrphansOmitted int
+	Stages         []stageReport
+	StageNames     []string
+	Accounts       []groupReport
+	Sessions       []groupReport // 指定了交易时段配置时
+	Groups         []groupTable  // 指定了-by时
+}
+
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.3f", ms(d))
+}
+
+func stageCosts(orders []*fixlog.Order, stage fixlog.Stage) []time.Duration {
+	var costs []time.Duration
+	for _, order := range orders {
+		if cost, ok := stage.Cost(order); ok {
+			costs = append(costs, cost)
+		}
+	}
+	stats.SortDurations(costs)
+	return costs
+}
+
+// CDF按分位数采样最多500个点，订单很多时报告大小也有上限
+func cdfPlot(sorted []time.Duration) template.HTML {
+	const points = 500
+	min, max := ms(sorted[0]), ms(sorted[len(sorted)-1])
+	// 耗时跨越多个数量级时用对数刻度，时钟偏移等导致出现非正数时只能用线性刻度
+	logX := min > 0 && max/min >= 20
+
+	p := newPlot(min, max, 0, 1, logX)
+	xTicks := linearTicks(min, max, 6)
+	if logX {
+		xTicks = logTicks(min, max)
+	}
+	p.axes(xTicks, linearTicks(0, 1, 5), "latency (ms)", "fraction of orders")
+
+	var xs, ys []float64
+	n := len(sorted)
+	last := -1
+	for q := 0; q <= points; q++ {
+		i := q * (n - 1) / points
+		if i == last {
+			continue
+		}
+		last = i
+		xs = append(xs, ms(sorted[i]))
+		ys = append(ys, float64(i+1)/float64(n))
+	}
+	p.polyline(xs, ys, "cdf")
+	return p.svg()
+}
+
+// 直方图只画到p99，超出的订单数另行说明，避免长尾压缩主体
+func histogramPlot(sorted []time.Duration) (template.HTML, int, time.Duration) {
+	const bins = 40
+	limit := stats.Percentile(sorted, 99)
+	min := sorted[0]
+	width := float64(limit-min) / bins
+	if width <= 0 {
+		width = float64(time.Microsecond)
+	}
+
+	counts := make([]int, bins)
+	overflow := 0
+	for _, cost := range sorted {
+		if cost > limit {
+			overflow += 1
+			continue
+		}
+		bin := int(float64(cost-min) / width)
+		if bin >= bins {
+			bin = bins - 1
+		}
+		counts[bin] += 1
+	}
+	maxCount := 0
+	for _, count := range counts {
+		if count > maxCount {
+			maxCount = count
+		}
+	}
+
+	lo, hi := ms(min), ms(min)+width*bins/1e6
+	p := newPlot(lo, hi, 0, float64(maxCount), false)
+	p.axes(linearTicks(lo, hi, 6), linearTicks(0, float64(maxCount), 4), "latency (ms)", "orders")
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		x0 := lo + width*float64(i)/1e6
+		x1 := x0 + width/1e6
+		p.bar(x0, x1, float64(count), fmt.Sprintf("%.3f-%.3f ms: %d orders", x0, x1, count))
+	}
+	return p.svg(), overflow, limit
+}
+
+// 按收到客户端订单的时间分桶，画出每桶的p50和p99；桶宽取使桶数不超过120的最小常用宽度。
+// 没有订单带收到客户端订单的时间(如日志缺少客户端的35=D)时返回空
+func timeSeriesPlot(orders []*fixlog.Order, stage fixlog.Stage) template.HTML {
+	type point struct {
+		at   time.Time
+		cost time.Duration
+	}
+	var points []point
+	for _, order := range orders {
+		recv, ok := order.Milestone(fixlog.RecvClient)
+		if !ok {
+			continue
+		}
+		if cost, ok := stage.Cost(order); ok {
+			points = append(points, point{recv.Time, cost})
+		}
+	}
+	if len(points) == 0 {
+		return ""
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].at.Before(points[j].at) })
+	start, end := points[0].at, points[len(points)-1].at
+
+	bucket := time.Hour
+	for _, width := range []time.Duration{time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+		time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour} {
+		if end.Sub(start)/width < 120 {
+			bucket = width
+			break
+		}
+	}
+
+	var xs, p50s, p99s []float64
+	var counts []int
+	for i := 0; i < len(points); {
+		at := points[i].at.Truncate(bucket)
+		var costs []time.Duration
+		for ; i < len(points) && points[i].at.Truncate(bucket).Equal(at); i++ {
+			costs = append(costs, points[i].cost)
+		}
+		stats.SortDurations(costs)
+		xs = append(xs, float64(at.Add(bucket/2).UnixNano())/1e9)
+		p50s = append(p50s, ms(stats.Percentile(costs, 50)))
+		p99s = append(p99s, ms(stats.Percentile(costs, 99)))
+		counts = append(counts, len(costs))
+	}
+
+	ymin, ymax := math.Min(0, floatsMin(p50s)), floatsMax(p99s)
+	xmin, xmax := float64(start.Truncate(bucket).UnixNano())/1e9, float64(end.Truncate(bucket).Add(bucket).UnixNano())/1e9
+	p := newPlot(xmin, xmax, ymin, ymax*1.1, false)
+	p.axes(timeTicks(time.Unix(0, int64(xmin*1e9)).In(start.Location()), time.Unix(0, int64(xmax*1e9)).In(start.Location()), 7),
+		linearTicks(ymin, ymax*1.1, 5), fmt.Sprintf("time received from client (%v buckets)", bucket), "latency (ms)")
+	p.polyline(xs, p50s, "p50")
+	p.polyline(xs, p99s, "p99")
+	if len(xs) <= 200 {
+		for i, x := range xs {
+			label := time.Unix(0, int64(x*1e9)).In(start.Location()).Format("15:04:05")
+			p.point(x, p50s[i], "p50", fmt.Sprintf("%s p50 %.3f ms (%d orders)", label, p50s[i], counts[i]))
+			p.point(x, p99s[i], "p99", fmt.Sprintf("%s p99 %.3f ms (%d orders)", label, p99s[i], counts[i]))
+		}
+	}
+	p.legend([2]string{"p50", "p50"}, [2]string{"p99", "p99"})
+	return p.svg()
+}
+
+func floatsMin(values []float64) float64 {
+	min := values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+	}
+	return min
+}
+
+func floatsMax(values []float64) float64 {
+	max := values[0]
+	for _, v := range values {
+		max = math.Max(max, v)
+	}
+	return max
+}
+
+func newStageReport(orders []*fixlog.Order, stage fixlog.Stage) stageReport {
+	costs := stageCosts(orders, stage)
+	report := stageReport{Name: stage.Name}
+	report.Count = len(costs)
+	report.Missing = len(orders) - len(costs)
+	if len(costs) == 0 {
+		return report
+	}
+
+	var sum time.Duration
+	for _, cost := range costs {
+		sum += cost
+	}
+	report.HasCosts = true
+	report.Min = formatMs(costs[0])
+	report.P50 = formatMs(stats.Percentile(costs, 50))
+	report.P90 = formatMs(stats.Percentile(costs, 90))
+	report.P99 = formatMs(stats.Percentile(costs, 99))
+	report.P999 = formatMs(stats.Percentile(costs, 99.9))
+	report.Max = formatMs(costs[len(costs)-1])
+	report.Mean = formatMs(sum / time.Duration(len(costs)))
+	report.CDF = cdfPlot(costs)
+	var limit time.Duration
+	report.Histogram, report.HistogramOverflow, limit = histogramPlot(costs)
+	report.HistogramLimit = formatMs(limit)
+	report.TimeSeries = timeSeriesPlot(orders, stage)
+	return report
+}
+
+func newReportData(source string, orders []*fixlog.Order, allOrders int, orphans []*fixlog.Order, analyzed fixlog.Stats, stages []fixlog.Stage, calendar *fixlog.TradingCalendar, groups []groupTable) reportData {
+	data := reportData{
+		Source:     source,
+		Generated:  time.Now().Format("2006-01-02 15:04:05 MST"),
+		Stats:      analyzed,
+		Completed:  len(orders),
+		Incomplete: allOrders - len(orders),
+		Groups:     groups,
+	}
+	for i, order := range orphans {
+		if i == maxReportedOrphans {
+			data.OrphansOmitted = len(orphans) - maxReportedOrphans
+			break
+		}
+		correct, _ := order.Milestone(fixlog.RecvMatchCorrect)
+		data.Orphans = append(data.Orphans, orphanReport{order.ClOrdID, order.Account, order.Symbol, correct.Time.Format("15:04:05.000000")})
+	}
+
+	for _, stage := range stages {
+		data.Stages = append(data.Stages, newStageReport(orders, stage))
+		data.StageNames = append(data.StageNames, stage.Name)
+	}
+
+	byAccount := make(map[string][]*fixlog.Order)
+	for _, order := range orders {
+		byAccount[order.Account] = append(byAccount[order.Account], order)
+	}
+	accounts := make([]string, 0, len(byAccount))
+	for account := range byAccount {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	for _, account := range accounts {
+		data.Accounts = append(data.Accounts, newGroupReport(account, byAccount[account], stages))
+	}
+	if calendar != nil {
+		for _, group := range groupBySession(calendar, orders) {
+			data.Sessions = append(data.Sessions, newGroupReport(group.name, group.orders, stages))
+		}
+	}
+	return data
+}
+
+func writeHtmlReport(filename string, data reportData) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating file: %v", err)
+	}
+	defer file.Close()
+	if err := reportTemplate.Execute(file, data); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+	return file.Close()
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>OMS latency report - {{.Source}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 22px; } h2 { font-size: 18px; margin-top: 32px; border-bottom: 1px solid #ddd; } h3 { font-size: 15px; }
+table { border-collapse: collapse; margin: 8px 0; font-size: 13px; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+th { background: #f4f4f4; }
+.scroll { overflow-x: auto; }
+.charts { display: flex; flex-wrap: wrap; gap: 12px; }
+.note { color: #666; font-size: 12px; }
+svg { font-size: 11px; background: #fff; }
+svg .grid { stroke: #eee; }
+svg .frame { fill: none; stroke: #999; }
+svg .cdf { fill: none; stroke: #1f77b4; stroke-width: 1.5; }
+svg .bar { fill: #1f77b4; }
+svg polyline.p50, svg line.p50 { fill: none; stroke: #1f77b4; stroke-width: 1.5; }
+svg polyline.p99, svg line.p99 { fill: none; stroke: #d62728; stroke-width: 1.5; }
+svg circle.p50 { fill: #1f77b4; } svg circle.p99 { fill: #d62728; }
+</style>
+</head>
+<body>
+<h1>OMS latency report</h1>
+<p>Log: <code>{{.Source}}</code><br>Generated: {{.Generated}}<br>All latencies are in milliseconds.</p>
+
+<h2>Data quality</h2>
+<table>
+<tr><td>Log lines</td><td>{{.Stats.Lines}}</td></tr>
+<tr><td>FIX messages</td><td>{{.Stats.FixMessages}}</td></tr>
+<tr><td>JNET confirmation messages (35=8, 20=2, 39=2)</td><td>{{.Stats.ConfirmedMessages}}</td></tr>
+<tr><td>Completed orders</td><td>{{.Completed}}</td></tr>
+<tr><td>Orders not completed</td><td>{{.Incomplete}}</td></tr>
+<tr><td>Orphans (JNET correction never confirmed to the client)</td><td>{{.Stats.Orphans}}</td></tr>
+<tr><td>Parse errors</td><td>{{.Stats.ParseErrors}}</td></tr>
+<tr><td>Session events</td><td>{{.Stats.SessionEvents}}</td></tr>
+{{- if .Stats.MatchEngineLinked}}
+<tr><td>Orders linked to the matching engine log</td><td>{{.Stats.MatchEngineLinked}}</td></tr>
+{{- end}}
+{{- if .Stats.WireMessages}}
+<tr><td>FIX messages in the capture</td><td>{{.Stats.WireMessages}}</td></tr>
+<tr><td>Orders linked to the capture</td><td>{{.Stats.WireLinked}}</td></tr>
+{{- end}}
+</table>
+<table>
+<tr><th>Stage</th><th>Orders with the stage</th><th>Completed orders missing it</th></tr>
+{{- range .Stages}}
+<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Missing}}</td></tr>
+{{- end}}
+</table>
+{{- if .Orphans}}
+<h3>Orphans</h3>
+<table>
+<tr><th>ClientOrderID</th><th>Account</th><th>Symbol</th><th>RecvMatchCorrectTime</th></tr>
+{{- range .Orphans}}
+<tr><td>{{.ClOrdID}}</td><td>{{.Account}}</td><td>{{.Symbol}}</td><td>{{.RecvMatchCorrectTime}}</td></tr>
+{{- end}}
+</table>
+{{- if .OrphansOmitted}}<p class="note">{{.OrphansOmitted}} more not listed.</p>{{end}}
+{{- end}}
+
+<h2>Percentiles</h2>
+<div class="scroll">
+<table>
+<tr><th>Stage</th><th>Orders</th><th>min</th><th>p50</th><th>p90</th><th>p99</th><th>p99.9</th><th>max</th><th>mean</th></tr>
+{{- range .Stages}}{{if .HasCosts}}
+<tr><td>{{.Name}}</td><td>{{.Count}}</td><td>{{.Min}}</td><td>{{.P50}}</td><td>{{.P90}}</td><td>{{.P99}}</td><td>{{.P999}}</td><td>{{.Max}}</td><td>{{.Mean}}</td></tr>
+{{- end}}{{end}}
+</table>
+</div>
+
+<h2>Per account (p50 / p99)</h2>
+<div class="scroll">
+<table>
+<tr><th>Account</th><th>Orders</th>{{range .StageNames}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Accounts}}
+<tr><td>{{.Name}}</td><td>{{.Orders}}</td>{{range .Costs}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+</div>
+{{- if .Sessions}}
+
+<h2>Per trading session (p50 / p99)</h2>
+<div class="scroll">
+<table>
+<tr><th>Session</th><th>Orders</th>{{range .StageNames}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Sessions}}
+<tr><td>{{.Name}}</td><td>{{.Orders}}</td>{{range .Costs}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+</div>
+{{- end}}
+{{- range .Groups}}
+
+<h2>Per {{.Name}} (p50 / p99)</h2>
+<div class="scroll">
+<table>
+<tr><th>{{.Name}}</th><th>Orders</th>{{range $.StageNames}}<th>{{.}}</th>{{end}}</tr>
+{{- range .Rows}}
+<tr><td>{{.Name}}</td><td>{{.Orders}}</td>{{range .Costs}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+</table>
+</div>
+{{- end}}
+
+{{- range .Stages}}{{if .HasCosts}}
+<h2>{{.Name}}</h2>
+<div class="charts">
+<div><h3>CDF</h3>{{.CDF}}</div>
+<div><h3>Histogram</h3>{{.Histogram}}
+{{- if .HistogramOverflow}}<p class="note">{{.HistogramOverflow}} orders above p99 ({{.HistogramLimit}} ms) not shown.</p>{{end}}</div>
+<div><h3>Time of day</h3>{{if .TimeSeries}}{{.TimeSeries}}{{else}}<p class="note">No order with this stage has RecvClientTime.</p>{{end}}</div>
+</div>
+{{- end}}{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 缺少客户端35=D的订单：只有发往exch_sim之后的时间点，没有RecvClientTime
+const logWithoutClientOrder = `D0411 04/11/2024 09:00:00.605100 1234 session.cpp:88] send 8=FIX.4.2|9=74|35=D|49=router_branch|56=exch_sim|11=R0|198=C0|1=ACC3|55=7203|54=1|38=100|10=000|
+D0411 04/11/2024 09:00:00.605598 1234 session.cpp:88] recv 8=FIX.4.2|9=146|35=8|49=exch_sim|56=router_branch|52=20240411-00:00:00.605590|60=20240411-00:00:00.605196|11=R0|198=C0|17=E0|37=O0|150=2|39=2|20=0|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.621642 1234 session.cpp:88] recv 8=FIX.4.2|9=120|35=8|49=exch_sim|56=router_branch|52=20240411-00:00:00.621638|11=R0|198=C0|17=E0c|19=E0|37=O0|150=G|39=2|1=ACC3|55=7203|10=000|
+D0411 04/11/2024 09:00:00.625075 1234 session.cpp:88] send 8=FIX.4.2|9=105|35=8|49=OMS|56=HRT01|52=20240411-00:00:00.625075|11=C0|1=ACC3|55=7203|17=X0c|19=X0|37=O0|150=G|39=2|20=2|10=000|
+`
+
+func TestLatencyReportWithoutRecvClient(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "oms.log")
+	htmlPath := filepath.Join(dir, "report.html")
+	csvPath := filepath.Join(dir, "out.csv")
+	if err := os.WriteFile(logPath, []byte(strings.ReplaceAll(logWithoutClientOrder, "|", "\x01")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runLatency([]string{"-html", htmlPath, logPath, csvPath}); err != nil {
+		t.Fatal(err)
+	}
+	html, err := os.ReadFile(htmlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MatchCostTime等不依赖RecvClientTime的阶段照常出图，只省略按时刻的图
+	for _, want := range []string{"<h2>MatchCostTime</h2>", "<h2>OmsCostTime2</h2>", "No order with this stage has RecvClientTime."} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("report does not contain %q", want)
+		}
+	}
+	if strings.Contains(string(html), "<h2>TotalCostTime</h2>") {
+		t.Errorf("report has a TotalCostTime section without RecvClientTime")
+	}
+}
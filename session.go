@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"v8/fixlog"
+)
+
+// 不在任何交易时段内的订单在统计中的分组名
+const outsideSessions = "(outside sessions)"
+
+func loadTradingCalendar(filename string) (*fixlog.TradingCalendar, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	return fixlog.LoadTradingCalendar(file)
+}
+
+// 收到客户端订单的时间在窗口内
+func windowOrderFilter(window timeWindow) func(order *fixlog.Order) bool {
+	return func(order *fixlog.Order) bool {
+		m, ok := order.Milestone(fixlog.RecvClient)
+		return ok && window.contains(m.Time)
+	}
+}
+
+// 收到客户端订单的时间在指定的交易时段内
+func sessionOrderFilter(calendar *fixlog.TradingCalendar, names []string) (func(order *fixlog.Order) bool, error) {
+	selected := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := calendar.Session(name); !ok {
+			var known []string
+			for _, s := range calendar.Sessions {
+				known = append(known, s.Name)
+			}
+			return nil, fmt.Errorf("unknown session %q, expected one of %s", name, strings.Join(known, ", "))
+		}
+		selected[name] = true
+	}
+	return func(order *fixlog.Order) bool {
+		session, ok := calendar.OrderSession(order)
+		return ok && selected[session.Name]
+	}, nil
+}
+
+// 组合多个订单过滤条件，全部满足时保留；忽略nil，没有条件时返回nil
+func allOrderFilters(filters ...func(order *fixlog.Order) bool) func(order *fixlog.Order) bool {
+	var active []func(order *fixlog.Order) bool
+	for _, filter := range filters {
+		if filter != nil {
+			active = append(active, filter)
+		}
+	}
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+	return func(order *fixlog.Order) bool {
+		for _, filter := range active {
+			if !filter(order) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// 按交易时段分组，顺序同配置，最后为不在任何时段内的订单(没有时省略)
+func groupBySession(calendar *fixlog.TradingCalendar, orders []*fixlog.Order) []orderGroup {
+	groups := make([]orderGroup, len(calendar.Sessions))
+	index := make(map[string]int)
+	for i, s := range calendar.Sessions {
+		groups[i].name = s.Name
+		index[s.Name] = i
+	}
+	outside := orderGroup{name: outsideSessions}
+	for _, order := range orders {
+		session, ok := calendar.OrderSession(order)
+		if !ok {
+			outside.orders = append(outside.orders, order)
+			continue
+		}
+		group := &groups[index[session.Name]]
+		group.orders = append(group.orders, order)
+		if calendar.InWarmup(order) {
+			group.warmup += 1
+		}
+	}
+	if len(outside.orders) > 0 {
+		groups = append(groups, outside)
+	}
+	return groups
+}
+
+// 每个交易时段一行：订单数、预热期内的订单数及各阶段的p50/p99(毫秒)
+func writeSessionStats(w io.Writer, calendar *fixlog.TradingCalendar, orders []*fixlog.Order, stages []fixlog.Stage) {
+	writeGroupStats(w, "Session", groupBySession(calendar, orders), stages, true)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"v8/fixlog"
+)
+
+// 标准输出中每条规则最多列出的违规订单数，完整列表用-sla-violations导出
+const maxListedViolators = 20
+
+func loadSlaConfig(filename string) (fixlog.SLAConfig, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return fixlog.SLAConfig{}, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+	return fixlog.LoadSLAConfig(file)
+}
+
+// 按交易时段评估时附上时段名
+func slaRuleLabel(result fixlog.SLAResult) string {
+	if result.Rule.PerSession {
+		return result.Rule.String() + " session=" + result.Session
+	}
+	return result.Rule.String()
+}
+
+func writeSlaReport(w io.Writer, results []fixlog.SLAResult) {
+	for _, result := range results {
+		status := "OK      "
+		if result.Violated {
+			status = "VIOLATED"
+		}
+		rule := slaRuleLabel(result)
+		if result.Rule.PerAccount {
+			rule += " account=" + result.Account
+		}
+		fmt.Fprintf(w, "SLA %s %s: %s=%.3fms over %d orders\n", status, rule, result.Rule.Stat, result.Value.Seconds()*1000, result.Orders)
+
+		stage, _ := fixlog.StageByName(result.Rule.Stage)
+		for i, order := range result.Violators {
+			if i == maxListedViolators {
+				fmt.Fprintf(w, "    ... %d more\n", len(result.Violators)-maxListedViolators)
+				break
+			}
+			fmt.Fprintf(w, "    ClientOrderID=%s Account=%s %s=%sms\n", order.ClOrdID, order.Account, stage.Name, formatCost(stage, order))
+		}
+	}
+}
+
+func exportSlaViolations(results []fixlog.SLAResult, csvFilename string) error {
+	file, err := os.Create(csvFilename)
+	if err != nil {
+		return fmt.Errorf("error creating CSV file: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"Rule", "Account", "ClientOrderID", "Stage", "CostTime", "Threshold"}); err != nil {
+		return fmt.Errorf("error writing header to CSV file: %v", err)
+	}
+	for _, result := range results {
+		stage, _ := fixlog.StageByName(result.Rule.Stage)
+		threshold := fmt.Sprintf("%.3f", time.Duration(result.Rule.LessThan).Seconds()*1000)
+		for _, order := range result.Violators {
+			record := []string{slaRuleLabel(result), order.Account, order.ClOrdID, stage.Name, formatCost(stage, order), threshold}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("error writing record to CSV file: %v", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error flushing data to CSV file: %v", err)
+	}
+	return file.Close()
+}
+
+// 评估SLA并输出结果，有规则未达标时返回checkError
+func checkSla(config fixlog.SLAConfig, orders []*fixlog.Order, calendar *fixlog.TradingCalendar, violationsFilename string) error {
+	results := fixlog.EvaluateSLA(config, orders, calendar)
+	writeSlaReport(os.Stdout, results)
+	if violationsFilename != "" {
+		if err := exportSlaViolations(results, violationsFilename); err != nil {
+			return fmt.Errorf("error exporting SLA violations: %v", err)
+		}
+	}
+
+	violated := 0
+	for _, result := range results {
+		if result.Violated {
+			violated += 1
+		}
+	}
+	if violated > 0 {
+		return checkError{fmt.Sprintf("SLA violated: %d of %d checks failed", violated, len(results))}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/stats"
+)
+
+// 交易日格式，也是历史库中每日目录的名称
+const tradeDateLayout = "2006-01-02"
+
+// 历史耗时库，每个交易日一个目录：
+//
+//	<dir>/2024-04-11/orders.csv    每笔订单各阶段耗时，格式同latency导出的CSV
+//	<dir>/2024-04-11/summary.json  各阶段按账户、合约的耗时直方图，可直接用于compare
+//	<dir>/2024-04-11/day.json      当日各阶段汇总统计，trend只读该文件
+type latencyStore struct {
+	dir string
+}
+
+// 一个交易日的汇总
+type dayAggregate struct {
+	Date   string           `json:"date"`
+	Source string           `json:"source"` // 分析的日志文件
+	Orders int              `json:"orders"`
+	Stages []stageAggregate `json:"stages"`
+}
+
+type stageAggregate struct {
+	Stage string          `json:"stage"`
+	Count int             `json:"count"`
+	P50   fixlog.Duration `json:"p50"`
+	P90   fixlog.Duration `json:"p90"`
+	P99   fixlog.Duration `json:"p99"`
+	Max   fixlog.Duration `json:"max"`
+	Mean  fixlog.Duration `json:"mean"`
+}
+
+func (d dayAggregate) stage(name string) (stageAggregate, bool) {
+	for _, stage := range d.Stages {
+		if stage.Stage == name {
+			return stage, true
+		}
+	}
+	return stageAggregate{}, false
+}
+
+func aggregateDay(date string, source string, orders []*fixlog.Order, stages []fixlog.Stage) dayAggregate {
+	day := dayAggregate{Date: date, Source: source, Orders: len(orders)}
+	for _, stage := range stages {
+		var costs []time.Duration
+		var sum time.Duration
+		for _, order := range orders {
+			if cost, ok := stage.Cost(order); ok {
+				costs = append(costs, cost)
+				sum += cost
+			}
+		}
+		if len(costs) == 0 {
+			continue
+		}
+		stats.SortDurations(costs)
+		day.Stages = append(day.Stages, stageAggregate{
+			Stage: stage.Name,
+			Count: len(costs),
+			P50:   fixlog.Duration(stats.Percentile(costs, 50)),
+			P90:   fixlog.Duration(stats.Percentile(costs, 90)),
+			P99:   fixlog.Duration(stats.Percentile(costs, 99)),
+			Max:   fixlog.Duration(costs[len(costs)-1]),
+			Mean:  fixlog.Duration(sum / time.Duration(len(costs))),
+		})
+	}
+	return day
+}
+
+// 交易日取最早一笔订单收到客户端委托的日期，没有完成的订单时返回空
+func tradeDateOf(orders []*fixlog.Order) string {
+	var first time.Time
+	for _, order := range orders {
+		recv, ok := order.Milestone(fixlog.RecvClient)
+		if ok && (first.IsZero() || recv.Time.Before(first)) {
+			first = recv.Time
+		}
+	}
+	if first.IsZero() {
+		return ""
+	}
+	return first.Format(tradeDateLayout)
+}
+
+// 写入一个交易日，已有的同日数据整体替换。先写到临时目录再改名，避免留下写了一半的交易日
+func (s latencyStore) put(date string, source string, orders []*fixlog.Order, stages []fixlog.Stage) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("error creating store directory: %v", err)
+	}
+	tmp, err := os.MkdirTemp(s.dir, "."+date+".*")
+	if err != nil {
+		return fmt.Errorf("error creating store directory: %v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	csvOut, err := newCsvConsumer(filepath.Join(tmp, "orders.csv"), stages, nil)
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if err := csvOut.OnOrderComplete(order); err != nil {
+			csvOut.Close()
+			return err
+		}
+	}
+	if err := csvOut.Close(); err != nil {
+		return err
+	}
+	if err := samplesFromOrders(orders, stages).writeSummary(filepath.Join(tmp, "summary.json")); err != nil {
+		return err
+	}
+
+	dayBytes, err := json.MarshalIndent(aggregateDay(date, source, orders, stages), "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling to JSON: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "day.json"), append(dayBytes, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing day file: %v", err)
+	}
+
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return fmt.Errorf("error writing store: %v", err)
+	}
+	target := filepath.Join(s.dir, date)
+	if err := os.RemoveAll(target); err != nil {
+		return fmt.Errorf("error replacing %s in store: %v", date, err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		return fmt.Errorf("error replacing %s in store: %v", date, err)
+	}
+	return nil
+}
+
+// 按日期升序读取[from, to]内的交易日汇总，from或to为空时不限
+func (s latencyStore) days(from string, to string) ([]dayAggregate, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading store directory: %v", err)
+	}
+
+	var days []dayAggregate
+	for _, entry := range entries {
+		date := entry.Name()
+		if !entry.IsDir() {
+			continue
+		}
+		// 跳过写入中的临时目录等非交易日目录
+		if _, err := time.Parse(tradeDateLayout, date); err != nil {
+			continue
+		}
+		if (from != "" && date < from) || (to != "" && date > to) {
+			continue
+		}
+
+		dayBytes, err := os.ReadFile(filepath.Join(s.dir, date, "day.json"))
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from store: %v", date, err)
+		}
+		var day dayAggregate
+		if err := json.Unmarshal(dayBytes, &day); err != nil {
+			return nil, fmt.Errorf("error parsing %s from store: %v", date, err)
+		}
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"v8/fixlog"
+)
+
+// 一组耗时样本：阶段 x 账户 x 合约
+type sampleKey struct {
+	Stage   string
+	Account string
+	Symbol  string
+}
+
+// 一次运行的全部耗时样本
+type latencySamples map[sampleKey][]time.Duration
+
+func samplesFromOrders(orders []*fixlog.Order, stages []fixlog.Stage) latencySamples {
+	samples := make(latencySamples)
+	for _, order := range orders {
+		for _, stage := range stages {
+			if cost, ok := stage.Cost(order); ok {
+				key := sampleKey{stage.Name, order.Account, order.Symbol}
+				samples[key] = append(samples[key], cost)
+			}
+		}
+	}
+	return samples
+}
+
+// 合并满足match的分组，返回升序排序的样本
+func (s latencySamples) merge(match func(key sampleKey) bool) []time.Duration {
+	var merged []time.Duration
+	for key, costs := range s {
+		if match(key) {
+			merged = append(merged, costs...)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i] < merged[j] })
+	return merged
+}
+
+// 按CSV列的顺序返回样本中出现的阶段
+func (s latencySamples) stages() []string {
+	present := make(map[string]bool)
+	for key := range s {
+		present[key.Stage] = true
+	}
+	var names []string
+	for _, stages := range [][]fixlog.Stage{fixlog.CoreStages, fixlog.FixTimeStages, fixlog.MatchEngineStages, fixlog.WireStages} {
+		for _, stage := range stages {
+			if present[stage.Name] {
+				names = append(names, stage.Name)
+			}
+		}
+	}
+	return names
+}
+
+// 样本是否按合约分组；latency导出的CSV中没有合约
+func (s latencySamples) hasSymbols() bool {
+	for key := range s {
+		if key.Symbol != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// 汇总文件：每个分组保存微秒精度的稀疏直方图(耗时微秒数 -> 订单数)。
+// 日志时间精度为微秒，因此直方图与原始样本等价，可用于分位数和显著性检验
+type latencySummary struct {
+	Version int            `json:"version"`
+	Groups  []summaryGroup `json:"groups"`
+}
+
+type summaryGroup struct {
+	Stage   string        `json:"stage"`
+	Account string        `json:"account"`
+	Symbol  string        `json:"symbol"`
+	Micros  map[int64]int `json:"micros"`
+}
+
+const summaryVersion = 1
+
+func (s latencySamples) writeSummary(filename string) error {
+	summary := latencySummary{Version: summaryVersion}
+	for key, costs := range s {
+		group := summaryGroup{Stage: key.Stage, Account: key.Account, Symbol: key.Symbol, Micros: make(map[int64]int)}
+		for _, cost := range costs {
+			group.Micros[int64(math.Round(float64(cost)/float64(time.Microsecond)))] += 1
+		}
+		summary.Groups = append(summary.Groups, group)
+	}
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		a, b := summary.Groups[i], summary.Groups[j]
+		if a.Stage != b.Stage {
+			return a.Stage < b.Stage
+		}
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		return a.Symbol < b.Symbol
+	})
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("error creating summary file: %v", err)
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(summary); err != nil {
+		return fmt.Errorf("error writing summary file: %v", err)
+	}
+	return file.Close()
+}
+
+func readSummary(r io.Reader) (latencySamples, error) {
+	var summary latencySummary
+	if err := json.NewDecoder(r).Decode(&summary); err != nil {
+		return nil, fmt.Errorf("error parsing summary: %v", err)
+	}
+	if summary.Version != summaryVersion {
+		return nil, fmt.Errorf("unsupported summary version %d", summary.Version)
+	}
+
+	samples := make(latencySamples)
+	for _, group := range summary.Groups {
+		key := sampleKey{group.Stage, group.Account, group.Symbol}
+		for micros, count := range group.Micros {
+			for i := 0; i < count; i++ {
+				samples[key] = append(samples[key], time.Duration(micros)*time.Microsecond)
+			}
+		}
+	}
+	return samples, nil
+}
+
+// 读取latency导出的CSV(毫秒)，CSV中没有合约，按空合约分组
+func readLatencyCsv(r io.Reader) (latencySamples, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV: %v", err)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "Account" {
+		return nil, fmt.Errorf("not a latency CSV: expected header Account,ClientOrderID,<stages>")
+	}
+
+	header := records[0]
+	samples := make(latencySamples)
+	for _, record := range records[1:] {
+		for i := 2; i < len(record) && i < len(header); i++ {
+			// -accounts、-symbols附加的参考数据属性列不是耗时
+			if record[i] == "" || isReferenceAttribute(header[i]) {
+				continue
+			}
+			ms, err := strconv.ParseFloat(record[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing %s of order %s: %v", header[i], record[1], err)
+			}
+			key := sampleKey{header[i], record[0], ""}
+			samples[key] = append(samples[key], time.Duration(math.Round(ms*1000))*time.Microsecond)
+		}
+	}
+	return samples, nil
+}
+
+// 按扩展名读取一次运行：.json为汇总文件，.csv为latency导出的CSV，其余视为OMS日志。
+// filter不为nil时只取满足条件的订单，仅适用于OMS日志
+func loadSamples(filename string, loc *time.Location, filter func(order *fixlog.Order) bool) (latencySamples, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if filter != nil && (ext == ".json" || ext == ".csv") {
+		return nil, fmt.Errorf("-filter needs an OMS log, %s is a %s file", filename, ext)
+	}
+	switch ext {
+	case ".json":
+		return readSummary(file)
+	case ".csv":
+		return readLatencyCsv(file)
+	}
+
+	opts := []fixlog.Option{fixlog.WithLocation(loc)}
+	if filter != nil {
+		opts = append(opts, fixlog.WithOrderFilter(filter))
+	}
+	analyzer := fixlog.NewAnalyzer(opts...)
+	if err := analyzer.Analyze(file); err != nil {
+		return nil, err
+	}
+	if err := analyzer.Flush(); err != nil {
+		return nil, err
+	}
+	return samplesFromOrders(analyzer.CompletedOrders(), fixlog.CoreStages), nil
+}
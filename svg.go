@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// 报告中的图表尺寸(像素)
+const (
+	plotWidth    = 560
+	plotHeight   = 260
+	marginLeft   = 60
+	marginRight  = 16
+	marginTop    = 12
+	marginBottom = 40
+)
+
+type tick struct {
+	value float64
+	label string
+}
+
+// 生成内联SVG的简单坐标图，x轴可为对数刻度
+type plot struct {
+	b                      strings.Builder
+	xmin, xmax, ymin, ymax float64
+	logX                   bool
+	left                   float64 // 左边距，y轴刻度为较长的文字时加大
+}
+
+func newPlot(xmin, xmax, ymin, ymax float64, logX bool) *plot {
+	if logX {
+		xmin, xmax = math.Log10(xmin), math.Log10(xmax)
+	}
+	// 只有一个取值时扩展范围，避免除0
+	if xmax <= xmin {
+		xmin, xmax = xmin-1, xmax+1
+	}
+	if ymax <= ymin {
+		ymin, ymax = ymin-1, ymax+1
+	}
+	p := &plot{xmin: xmin, xmax: xmax, ymin: ymin, ymax: ymax, logX: logX, left: marginLeft}
+	fmt.Fprintf(&p.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`, plotWidth, plotHeight, plotWidth, plotHeight)
+	return p
+}
+
+func (p *plot) px(x float64) float64 {
+	if p.logX {
+		x = math.Log10(x)
+	}
+	return p.left + (x-p.xmin)/(p.xmax-p.xmin)*(plotWidth-p.left-marginRight)
+}
+
+func (p *plot) py(y float64) float64 {
+	return plotHeight - marginBottom - (y-p.ymin)/(p.ymax-p.ymin)*(plotHeight-marginTop-marginBottom)
+}
+
+func (p *plot) axes(xTicks []tick, yTicks []tick, xLabel string, yLabel string) {
+	left, right := p.left, float64(plotWidth-marginRight)
+	top, bottom := float64(marginTop), float64(plotHeight-marginBottom)
+	for _, t := range yTicks {
+		y := p.py(t.value)
+		fmt.Fprintf(&p.b, `<line class="grid" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`, left, y, right, y)
+		fmt.Fprintf(&p.b, `<text x="%.1f" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`, left-4, y, html.EscapeString(t.label))
+	}
+	for _, t := range xTicks {
+		x := p.px(t.value)
+		fmt.Fprintf(&p.b, `<line class="grid" x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f"/>`, x, top, x, bottom)
+		fmt.Fprintf(&p.b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, x, bottom+14, html.EscapeString(t.label))
+	}
+	fmt.Fprintf(&p.b, `<rect class="frame" x="%.1f" y="%.1f" width="%.1f" height="%.1f"/>`, left, top, right-left, bottom-top)
+	fmt.Fprintf(&p.b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`, (left+right)/2, float64(plotHeight-6), html.EscapeString(xLabel))
+	fmt.Fprintf(&p.b, `<text x="12" y="%.1f" text-anchor="middle" transform="rotate(-90 12 %.1f)">%s</text>`, (top+bottom)/2, (top+bottom)/2, html.EscapeString(yLabel))
+}
+
+func (p *plot) polyline(xs []float64, ys []float64, class string) {
+	p.b.WriteString(`<polyline class="` + class + `" points="`)
+	for i := range xs {
+		fmt.Fprintf(&p.b, "%.1f,%.1f ", p.px(xs[i]), p.py(ys[i]))
+	}
+	p.b.WriteString(`"/>`)
+}
+
+func (p *plot) point(x float64, y float64, class string, title string) {
+	fmt.Fprintf(&p.b, `<circle class="%s" cx="%.1f" cy="%.1f" r="2.5"><title>%s</title></circle>`, class, p.px(x), p.py(y), html.EscapeString(title))
+}
+
+// 矩形覆盖[x0, x1) x [y0, y1)
+func (p *plot) span(x0 float64, x1 float64, y0 float64, y1 float64, class string, title string) {
+	left, right := p.px(x0), p.px(x1)
+	top, bottom := p.py(y1), p.py(y0)
+	fmt.Fprintf(&p.b, `<rect class="%s" x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s</title></rect>`,
+		class, left, top, math.Max(right-left, 1), bottom-top, html.EscapeString(title))
+}
+
+func (p *plot) label(x float64, y float64, text string) {
+	fmt.Fprintf(&p.b, `<text x="%.1f" y="%.1f" dominant-baseline="middle">%s</text>`, p.px(x)+4, p.py(y), html.EscapeString(text))
+}
+
+// 柱子覆盖[x0, x1)，高度为y
+func (p *plot) bar(x0 float64, x1 float64, y float64, title string) {
+	left, right := p.px(x0), p.px(x1)
+	top, bottom := p.py(y), p.py(p.ymin)
+	fmt.Fprintf(&p.b, `<rect class="bar" x="%.1f" y="%.1f" width="%.1f" height="%.1f"><title>%s</title></rect>`,
+		left, top, math.Max(right-left-1, 1), bottom-top, html.EscapeString(title))
+}
+
+func (p *plot) legend(entries ...[2]string) {
+	x := p.left + 8
+	for _, entry := range entries {
+		class, label := entry[0], entry[1]
+		fmt.Fprintf(&p.b, `<line class="%s" x1="%.1f" y1="%d" x2="%.1f" y2="%d"/>`, class, x, marginTop+12, x+16, marginTop+12)
+		fmt.Fprintf(&p.b, `<text x="%.1f" y="%d" dominant-baseline="middle">%s</text>`, x+20, marginTop+12, html.EscapeString(label))
+		x += 28 + float64(len(label))*7
+	}
+}
+
+func (p *plot) svg() template.HTML {
+	p.b.WriteString(`</svg>`)
+	return template.HTML(p.b.String())
+}
+
+// 取1、2、5乘以10的幂作为刻度间隔，生成约n个刻度
+func linearTicks(min float64, max float64, n int) []tick {
+	if max <= min {
+		return []tick{{min, formatTickValue(min)}}
+	}
+	raw := (max - min) / float64(n)
+	magnitude := math.Pow(10, math.Floor(math.Log10(raw)))
+	step := magnitude
+	for _, m := range []float64{1, 2, 5, 10} {
+		if step = m * magnitude; step >= raw {
+			break
+		}
+	}
+
+	var ticks []tick
+	for v := math.Ceil(min/step) * step; v <= max+step*1e-9; v += step {
+		// 消除浮点累加误差，如0.30000000000000004
+		v = math.Round(v/step) * step
+		ticks = append(ticks, tick{v, formatTickValue(v)})
+	}
+	return ticks
+}
+
+// 对数刻度取每个数量级的1、2、5
+func logTicks(min float64, max float64) []tick {
+	var ticks []tick
+	for k := math.Floor(math.Log10(min)); k <= math.Ceil(math.Log10(max)); k++ {
+		for _, m := range []float64{1, 2, 5} {
+			v := m * math.Pow(10, k)
+			if v >= min && v <= max {
+				ticks = append(ticks, tick{v, formatTickValue(v)})
+			}
+		}
+	}
+	return ticks
+}
+
+func formatTickValue(v float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.6g", v), ".0")
+}
+
+// 时间轴刻度，间隔取能让刻度数不超过n的最小的常用间隔
+func timeTicks(start time.Time, end time.Time, n int) []tick {
+	steps := []time.Duration{time.Second, 5 * time.Second, 10 * time.Second, 30 * time.Second,
+		time.Minute, 5 * time.Minute, 10 * time.Minute, 15 * time.Minute, 30 * time.Minute, time.Hour, 2 * time.Hour}
+	step := steps[len(steps)-1]
+	for _, s := range steps {
+		if end.Sub(start)/s <= time.Duration(n) {
+			step = s
+			break
+		}
+	}
+	layout := "15:04:05"
+	if step >= time.Minute {
+		layout = "15:04"
+	}
+
+	var ticks []tick
+	for t := start.Truncate(step); !t.After(end); t = t.Add(step) {
+		if !t.Before(start) {
+			ticks = append(ticks, tick{float64(t.UnixNano()) / 1e9, t.Format(layout)})
+		}
+	}
+	return ticks
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"v8/fixlog"
+	"v8/internal/fixdict"
+	"v8/internal/logline"
+)
+
+// 关联同一订单的报文的标签：ClOrdID、OrigClOrdID、SecondaryOrderID(发往exch_sim时的客户端11)、OrderID、ExecID、ExecRefID
+var traceIDTags = []string{"11", "41", "198", "37", "17", "19"}
+
+// 未分配标识时填的占位值，如拒绝的订单37=NONE、17=0；不作为关联的依据，否则会把无关的订单串在一起
+var traceIDPlaceholders = map[string]bool{"": true, "0": true, "NONE": true, "N/A": true, "NA": true, "UNKNOWN": true}
+
+func isTraceID(value string) bool {
+	return !traceIDPlaceholders[strings.ToUpper(value)]
+}
+
+// 时间线中展示的关键字段，字段名及取值的含义取自数据字典
+var traceFields = []string{"11", "41", "198", "37", "17", "19", "150", "39", "1", "55", "54", "38", "44", "32", "31", "58"}
+
+// 时间线中的一条报文
+type hop struct {
+	lineNo    int
+	time      time.Time
+	direction string
+	sender    string
+	target    string
+	message   string // 报文类型及类型名，如8(ExecutionReport)
+	fields    []string
+	milestone string // 该报文对应的时间点，如RecvClientTime
+}
+
+func (h hop) describe() string {
+	return fmt.Sprintf("%-4s %s -> %s %s  %s", h.direction, h.sender, h.target, h.message, strings.Join(h.fields, " "))
+}
+
+func parseHop(line string, lineNo int, loc *time.Location, dict *fixdict.Dictionary) (hop, bool) {
+	value, ok := logline.Time(line)
+	if !ok {
+		return hop{}, false
+	}
+	t, err := time.ParseInLocation(logline.TimeLayout, value, loc)
+	if err != nil {
+		return hop{}, false
+	}
+	h := hop{lineNo: lineNo, time: t, direction: logline.Direction(line)}
+	h.sender, _ = logline.Tag(line, "49")
+	h.target, _ = logline.Tag(line, "56")
+	msgType, _ := logline.Tag(line, "35")
+	h.message = describeMsgType(dict, msgType)
+	for _, tag := range traceFields {
+		if value, ok := logline.Tag(line, tag); ok {
+			h.fields = append(h.fields, dict.FieldName(tag)+"="+describeValue(dict, tag, value))
+		}
+	}
+	return h, true
+}
+
+// 报文中是否有已知的订单标识，有则把报文中的其他标识也加入；占位值不参与关联
+func matchIDs(line string, ids map[string]bool) bool {
+	matched := false
+	for _, tag := range traceIDTags {
+		if value, ok := logline.Tag(line, tag); ok && isTraceID(value) && ids[value] {
+			matched = true
+			break
+		}
+	}
+	if matched {
+		for _, tag := range traceIDTags {
+			if value, ok := logline.Tag(line, tag); ok && isTraceID(value) {
+				ids[value] = true
+			}
+		}
+	}
+	return matched
+}
+
+func scanLog(filename string, handle func(line string, lineNo int) error) error {
+	return openFile(filename, func(r io.Reader) error {
+		scanner := logline.NewScanner(r)
+		for scanner.Scan() {
+			if err := handle(scanner.Text(), scanner.LineNo()); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+		return nil
+	})
+}
+
+func writeTimeline(w io.Writer, hops []hop) {
+	fmt.Fprintf(w, "%-15s %10s %7s  %s\n", "Time", "Gap(ms)", "Line", "Message")
+	for i, h := range hops {
+		gap := ""
+		if i > 0 {
+			gap = fmt.Sprintf("+%.3f", ms(h.time.Sub(hops[i-1].time)))
+		}
+		milestone := ""
+		if h.milestone != "" {
+			milestone = "  [" + h.milestone + "]"
+		}
+		fmt.Fprintf(w, "%-15s %10s %7d  %s%s\n", h.time.Format("15:04:05.000000"), gap, h.lineNo, h.describe(), milestone)
+	}
+}
+
+// 各阶段按开始时间排列的瀑布图(开始时间相同时保持stages中的顺序)，横轴为距收到客户端订单的毫秒数
+func waterfallSvg(order *fixlog.Order, stages []fixlog.Stage) (string, error) {
+	start, ok := order.Milestone(fixlog.RecvClient)
+	if !ok {
+		return "", fmt.Errorf("order %s has no %s", order.ClOrdID, fixlog.RecvClient)
+	}
+	type row struct {
+		name     string
+		from, to float64
+	}
+	var rows []row
+	end := 0.0
+	for _, stage := range stages {
+		from, okFrom := order.Milestone(stage.From)
+		to, okTo := order.Milestone(stage.To)
+		if !okFrom || !okTo {
+			continue
+		}
+		r := row{stage.Name, ms(from.Time.Sub(start.Time)), ms(to.Time.Sub(start.Time))}
+		rows = append(rows, r)
+		if r.to > end {
+			end = r.to
+		}
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("order %s has no complete stage", order.ClOrdID)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].from < rows[j].from })
+
+	n := float64(len(rows))
+	p := newPlot(0, end*1.15, 0, n, false)
+	p.left = 130
+	fmt.Fprint(&p.b, `<style>text { font: 11px sans-serif; } .grid { stroke: #eee; } .frame { fill: none; stroke: #999; } .stage { fill: #1f77b4; }</style>`)
+	var yTicks []tick
+	for i, r := range rows {
+		yTicks = append(yTicks, tick{n - float64(i) - 0.5, r.name})
+	}
+	p.axes(linearTicks(0, end*1.15, 6), yTicks, "ms since "+fixlog.RecvClient.String()+" "+start.Time.Format("15:04:05.000000"), "")
+	for i, r := range rows {
+		y := n - float64(i) - 1
+		title := fmt.Sprintf("%s %.3f ms", r.name, r.to-r.from)
+		p.span(r.from, r.to, y+0.2, y+0.8, "stage", title)
+		p.label(r.to, y+0.5, fmt.Sprintf("%.3f", r.to-r.from))
+	}
+	return string(p.svg()), nil
+}
+
+func runTrace(args []string) error {
+	fs := newFlagSet("trace", "[options] <logFilePath> <ClOrdID>",
+		"Print the chronological timeline of every message of one order, linked by ClOrdID(11), OrigClOrdID(41), SecondaryOrderID(198), OrderID(37), ExecID(17) and ExecRefID(19).")
+	svgPath := fs.String("svg", "", "also write an SVG waterfall of the order's stages to this file")
+	meLogPath := fs.String("me", "", "matching engine log, adds the matching engine stages to the waterfall")
+	logTimeZone := fs.String("logtz", "Local", "time zone of the log line prefix time, used to compare with UTC FIX timestamps")
+	dictPaths := addDictFlag(fs)
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return usageErrorf(fs, "expected <logFilePath> <ClOrdID>")
+	}
+	logFilePath, clOrdID := positional[0], positional[1]
+	dict, err := dictPaths.load()
+	if err != nil {
+		return err
+	}
+
+	loc, err := time.LoadLocation(*logTimeZone)
+	if err != nil {
+		return fmt.Errorf("error loading time zone: %v", err)
+	}
+	analyzer := fixlog.NewAnalyzer(fixlog.WithLocation(loc))
+	if *meLogPath != "" {
+		if err := openFile(*meLogPath, analyzer.LoadMatchEngine); err != nil {
+			return fmt.Errorf("error loading matching engine log: %v", err)
+		}
+	}
+
+	// 第一遍：分析订单的时间点，并按日志顺序沿标识收集同一订单的全部标识
+	ids := map[string]bool{clOrdID: true}
+	err = scanLog(logFilePath, func(line string, lineNo int) error {
+		matchIDs(line, ids)
+		return analyzer.Feed(line, lineNo)
+	})
+	if err != nil {
+		return fmt.Errorf("error analyzing log: %v", err)
+	}
+	if err := analyzer.Flush(); err != nil {
+		return fmt.Errorf("error analyzing log: %v", err)
+	}
+
+	milestones := make(map[int]string)
+	order, found := analyzer.Order(clOrdID)
+	if found {
+		for _, kind := range fixlog.LogMilestones {
+			if m, ok := order.Milestone(kind); ok {
+				milestones[m.LineNo] = kind.String()
+			}
+		}
+	}
+
+	// 第二遍：按完整的标识集合取出报文，包括标识出现之前的报文
+	var hops []hop
+	err = scanLog(logFilePath, func(line string, lineNo int) error {
+		if !logline.IsFix(line) || !matchIDs(line, ids) {
+			return nil
+		}
+		if h, ok := parseHop(line, lineNo, loc, dict); ok {
+			h.milestone = milestones[lineNo]
+			hops = append(hops, h)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error reading log: %v", err)
+	}
+	if len(hops) == 0 {
+		return fmt.Errorf("order %s not found in %s", clOrdID, logFilePath)
+	}
+
+	fmt.Printf("Order %s: %d messages\n", clOrdID, len(hops))
+	writeTimeline(os.Stdout, hops)
+
+	stages := append([]fixlog.Stage{}, fixlog.CoreStages...)
+	if *meLogPath != "" {
+		stages = append(stages, fixlog.MatchEngineStages...)
+	}
+	if !found {
+		fmt.Println("\nNo order lifecycle starts with this ClOrdID, stages not available")
+		return nil
+	}
+	fmt.Println()
+	for _, stage := range stages {
+		cost := formatCost(stage, order)
+		if cost == "" {
+			cost = "-"
+		}
+		fmt.Printf("%-20s %10s ms\n", stage.Name, cost)
+	}
+
+	if *svgPath != "" {
+		// 总耗时放在第一行
+		waterfall := []fixlog.Stage{fixlog.TotalCostTime}
+		for _, stage := range stages {
+			if stage != fixlog.TotalCostTime {
+				waterfall = append(waterfall, stage)
+			}
+		}
+		svg, err := waterfallSvg(order, waterfall)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*svgPath, []byte(svg+"\n"), 0644); err != nil {
+			return fmt.Errorf("error writing SVG: %v", err)
+		}
+		fmt.Println("Waterfall written to", *svgPath)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestMatchIDs(t *testing.T) {
+	prefix := "D0411 04/11/2024 09:00:00.600000 1234 session.cpp:88] "
+	// C0被拒绝的回报和无关订单C5的回报都带占位的37=NONE、17=0
+	lines := []struct {
+		message string
+		matched bool
+	}{
+		{"recv 8=FIX.4.2|35=D|49=HRT01|56=OMS|11=C0|1=ACC3|55=7203|", true},
+		{"send 8=FIX.4.2|35=D|49=router_branch|56=exch_sim|11=R0|198=C0|", true},
+		{"recv 8=FIX.4.2|35=8|49=exch_sim|56=router_branch|11=R0|198=C0|37=NONE|17=0|150=8|39=8|", true},
+		{"recv 8=FIX.4.2|35=D|49=HRT02|56=OMS|11=C5|1=ACC1|55=6758|", false},
+		{"send 8=FIX.4.2|35=8|49=OMS|56=HRT02|11=C5|37=NONE|17=0|150=8|39=8|", false},
+		{"send 8=FIX.4.2|35=8|49=OMS|56=HRT02|11=C6|37=none|17=|150=8|39=8|", false},
+		// 经由真实的OrderID关联
+		{"recv 8=FIX.4.2|35=8|49=exch_sim|56=router_branch|11=R0|37=O0|17=E0|150=0|39=0|", true},
+		{"send 8=FIX.4.2|35=8|49=OMS|56=HRT01|37=O0|17=X0|150=0|39=0|", true},
+	}
+	ids := map[string]bool{"C0": true}
+	for _, tt := range lines {
+		if got := matchIDs(prefix+tt.message, ids); got != tt.matched {
+			t.Errorf("%s: matched %v, want %v", tt.message, got, tt.matched)
+		}
+	}
+	for _, id := range []string{"C0", "R0", "O0", "E0", "X0"} {
+		if !ids[id] {
+			t.Errorf("%s not collected", id)
+		}
+	}
+	for _, id := range []string{"NONE", "0", "C5", "C6", ""} {
+		if ids[id] {
+			t.Errorf("%q collected", id)
+		}
+	}
+}
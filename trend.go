@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"v8/fixlog"
+)
+
+func writeTrend(w io.Writer, days []dayAggregate, stages []string) {
+	fmt.Fprintf(w, "%-10s %6s", "Date", "Orders")
+	for _, stage := range stages {
+		fmt.Fprintf(w, " %21s", stage+" p50/p99")
+	}
+	fmt.Fprintln(w)
+
+	for _, day := range days {
+		fmt.Fprintf(w, "%-10s %6d", day.Date, day.Orders)
+		for _, name := range stages {
+			stage, ok := day.stage(name)
+			if !ok {
+				fmt.Fprintf(w, " %21s", "-")
+				continue
+			}
+			p50 := time.Duration(stage.P50).Seconds() * 1000
+			p99 := time.Duration(stage.P99).Seconds() * 1000
+			fmt.Fprintf(w, " %21s", fmt.Sprintf("%.3f/%.3f", p50, p99))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// 校验并规范化-from/-to日期
+func parseTradeDate(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	date, err := time.Parse(tradeDateLayout, value)
+	if err != nil {
+		return "", fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return date.Format(tradeDateLayout), nil
+}
+
+func runTrend(args []string) error {
+	fs := newFlagSet("trend", "[options] <storeDir>", "Report daily order volume and per-stage p50/p99 (milliseconds) from a store written by latency -store.")
+	from := fs.String("from", "", "first trade date to report, YYYY-MM-DD (default the earliest in the store)")
+	to := fs.String("to", "", "last trade date to report, YYYY-MM-DD (default the latest in the store)")
+	stageNames := fs.String("stages", "", "comma separated stages to report (default the core stages)")
+
+	positional, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageErrorf(fs, "expected <storeDir>")
+	}
+	fromDate, err := parseTradeDate(*from)
+	if err != nil {
+		return usageErrorf(fs, "-from: %v", err)
+	}
+	toDate, err := parseTradeDate(*to)
+	if err != nil {
+		return usageErrorf(fs, "-to: %v", err)
+	}
+
+	var stages []string
+	if *stageNames == "" {
+		for _, stage := range fixlog.CoreStages {
+			stages = append(stages, stage.Name)
+		}
+	} else {
+		for _, name := range strings.Split(*stageNames, ",") {
+			stage, ok := fixlog.StageByName(strings.TrimSpace(name))
+			if !ok {
+				return usageErrorf(fs, "unknown stage %q", name)
+			}
+			stages = append(stages, stage.Name)
+		}
+	}
+
+	days, err := latencyStore{positional[0]}.days(fromDate, toDate)
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		return fmt.Errorf("no trade dates in %s between %q and %q", positional[0], fromDate, toDate)
+	}
+	writeTrend(os.Stdout, days, stages)
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"v8/internal/logline"
+)
+
+// -from/-to的取值：日期时间，或只有时间(日期取日志第一行的日期)
+type windowTime struct {
+	value     time.Time
+	timeOnly  bool
+	specified bool
+}
+
+var windowTimeLayouts = []string{"2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "15:04:05.999999999"}
+
+func parseWindowTime(value string, loc *time.Location) (windowTime, error) {
+	if value == "" {
+		return windowTime{}, nil
+	}
+	for i, layout := range windowTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return windowTime{value: t, timeOnly: i == len(windowTimeLayouts)-1, specified: true}, nil
+		}
+	}
+	return windowTime{}, fmt.Errorf("invalid time %q, expected HH:MM:SS[.ffffff] or YYYY-MM-DD HH:MM:SS[.ffffff]", value)
+}
+
+// 只有时间时取日志的日期
+func (w *windowTime) resolve(logTime time.Time) {
+	if w.timeOnly {
+		year, month, day := logTime.Date()
+		w.value = time.Date(year, month, day, w.value.Hour(), w.value.Minute(), w.value.Second(), w.value.Nanosecond(), logTime.Location())
+		w.timeOnly = false
+	}
+}
+
+// 日志时间窗口[from, to)，未指定的一端不限
+type timeWindow struct {
+	from, to windowTime
+	resolved bool
+}
+
+func parseTimeWindow(fromValue string, toValue string, loc *time.Location) (timeWindow, error) {
+	from, err := parseWindowTime(fromValue, loc)
+	if err != nil {
+		return timeWindow{}, fmt.Errorf("-from: %v", err)
+	}
+	to, err := parseWindowTime(toValue, loc)
+	if err != nil {
+		return timeWindow{}, fmt.Errorf("-to: %v", err)
+	}
+	if from.specified && to.specified && from.timeOnly == to.timeOnly && !to.value.After(from.value) {
+		return timeWindow{}, fmt.Errorf("-to must be after -from")
+	}
+	return timeWindow{from: from, to: to}, nil
+}
+
+// 以日志第一条带前缀时间的行的日期确定只有时间的-from/-to，需在读取日志之前调用：
+// 否则日期取自第一个经过窗口判断的时间，而它可能已被其他条件过滤到了后一天
+func (w *timeWindow) resolveDate(filename string, loc *time.Location) error {
+	if !w.from.timeOnly && !w.to.timeOnly {
+		return nil
+	}
+	t, ok, err := firstLogTime(filename, loc)
+	if err != nil {
+		return err
+	}
+	if ok {
+		w.from.resolve(t)
+		w.to.resolve(t)
+		w.resolved = true
+	}
+	return nil
+}
+
+// 日志中第一条带前缀时间的行的时间，没有时ok为false
+func firstLogTime(filename string, loc *time.Location) (time.Time, bool, error) {
+	var first time.Time
+	found := false
+	err := openFile(filename, func(r io.Reader) error {
+		scanner := logline.NewScanner(r)
+		for scanner.Scan() {
+			if t, ok := lineTime(scanner.Text(), loc); ok {
+				first, found = t, true
+				return nil
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("error reading file: %v", err)
+		}
+		return nil
+	})
+	return first, found, err
+}
+
+// 是否在窗口内；未调用resolveDate(或日志没有带时间的行)时，第一次调用以该时间的日期确定只有时间的-from/-to
+func (w *timeWindow) contains(t time.Time) bool {
+	if !w.resolved {
+		w.from.resolve(t)
+		w.to.resolve(t)
+		w.resolved = true
+	}
+	if w.from.specified && t.Before(w.from.value) {
+		return false
+	}
+	if w.to.specified && !t.Before(w.to.value) {
+		return false
+	}
+	return true
+}
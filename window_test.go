@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 夜盘日志跨过零点：第一行为4月11日，HRT01的报文在4月12日零点之后
+const overnightLog = `D0411 04/11/2024 23:59:30.000000 1234 session.cpp:88] recv 8=FIX.4.2|9=60|35=D|49=HRT02|56=OMS|11=C0|1=ACC2|55=7203|54=1|38=100|10=000|
+D0412 04/12/2024 00:00:10.000000 1234 session.cpp:88] recv 8=FIX.4.2|9=60|35=D|49=HRT01|56=OMS|11=C1|1=ACC1|55=7203|54=1|38=100|10=000|
+D0412 04/12/2024 00:00:20.000000 1234 session.cpp:88] recv 8=FIX.4.2|9=60|35=D|49=HRT01|56=OMS|11=C2|1=ACC1|55=7203|54=1|38=100|10=000|
+`
+
+func TestTimeWindowDateFromFirstLine(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "oms.log")
+	if err := os.WriteFile(logPath, []byte(strings.ReplaceAll(overnightLog, "|", "\x01")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	window, err := parseTimeWindow("23:59:00", "", time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := window.resolveDate(logPath, time.UTC); err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2024, 4, 11, 23, 59, 0, 0, time.UTC); !window.from.value.Equal(want) {
+		t.Errorf("-from = %v, want %v", window.from.value, want)
+	}
+
+	// 只保留HRT01时，第一条经过窗口判断的报文已是4月12日，日期仍取日志第一行的4月11日
+	outputPath := filepath.Join(dir, "out.jsonl")
+	if err := runFixJson([]string{"-logtz", "UTC", "-session", "HRT01", "-from", "23:59:00", logPath, outputPath}); err != nil {
+		t.Fatal(err)
+	}
+	output, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(output), "\n"); lines != 2 {
+		t.Errorf("%d messages exported, want 2:\n%s", lines, output)
+	}
+}